@@ -4,11 +4,15 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/nisimpson/piper"
 	"github.com/nisimpson/piper/aws/awsddb"
 	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
 )
 
 type MockDynamoDB struct {
@@ -384,3 +388,97 @@ func TestPut(t *testing.T) {
 		}
 	})
 }
+
+// SpanPutter records the span of the context of each put request.
+type SpanPutter struct {
+	MockDynamoDB
+	spans chan pipeline.Span
+}
+
+func (p SpanPutter) PutItem(ctx context.Context, in *dynamodb.PutItemInput, opts ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	p.spans <- pipeline.SpanFromContext(ctx)
+	return p.MockDynamoDB.PutItem(ctx, in, opts...)
+}
+
+// FakeSpan is a span of the trace started by the first span of a [FakeTracer].
+type FakeSpan struct{ sc pipeline.SpanContext }
+
+func (s *FakeSpan) SpanContext() pipeline.SpanContext { return s.sc }
+func (s *FakeSpan) SetAttribute(string, any)          {}
+func (s *FakeSpan) RecordError(error)                 {}
+func (s *FakeSpan) End()                              {}
+
+// FakeTracer starts child spans of the span in the context, if any, numbering them sequentially.
+type FakeTracer struct {
+	mu sync.Mutex
+	n  byte
+}
+
+func (t *FakeTracer) Start(ctx context.Context, _ string, _ ...func(*pipeline.SpanOptions)) (context.Context, pipeline.Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.n++
+	span := &FakeSpan{}
+	span.sc.SpanID[7] = t.n
+	if parent := pipeline.SpanFromContext(ctx); parent != nil {
+		span.sc.TraceID = parent.SpanContext().TraceID
+	} else {
+		span.sc.TraceID[15] = t.n
+	}
+	return pipeline.ContextWithSpan(ctx, span), span
+}
+
+func TestPutTracing(t *testing.T) {
+	var (
+		ddb    = SpanPutter{spans: make(chan pipeline.Span, 1)}
+		tracer = &FakeTracer{}
+		sink   = pipeline.ToSlice[*dynamodb.PutItemOutput]()
+	)
+
+	pipeline.FromSlice("foo").
+		WithTracer(tracer).
+		Thru(awsddb.Put(ddb, context.TODO(), ddb.mapPut)).
+		To(sink)
+
+	if got := sink.Slice(); len(got) != 1 {
+		t.Fatalf("got %v, want one output", got)
+	}
+
+	span := <-ddb.spans
+	if span == nil {
+		t.Fatal("expected the put request to carry the span of the item")
+	}
+	// the first span starts the trace followed by the item through each stage
+	if got := span.SpanContext(); got.TraceID[15] != 1 || got.SpanID[7] == 1 {
+		t.Errorf("got span %v, want a child span in the trace of the item", got)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	var (
+		ddb   = MockDynamoDB{}
+		input = func(o *pipetest.ConformanceOptions) {
+			o.Input = func(i int) any { return strconv.Itoa(i) }
+			// each request sends its response downstream
+			o.Output = func(n int) int { return n }
+		}
+	)
+
+	tests := []struct {
+		name string
+		pipe func(ctx context.Context) piper.Pipe
+	}{
+		{name: "Put", pipe: func(ctx context.Context) piper.Pipe { return awsddb.Put(ddb, ctx, ddb.mapPut) }},
+		{name: "Get", pipe: func(ctx context.Context) piper.Pipe { return awsddb.Get(ddb, ctx, ddb.mapGet) }},
+		{name: "Scan", pipe: func(ctx context.Context) piper.Pipe { return awsddb.Scan(ddb, ctx, ddb.mapScan) }},
+		{name: "Query", pipe: func(ctx context.Context) piper.Pipe { return awsddb.Query(ddb, ctx, ddb.mapQuery) }},
+		{name: "Update", pipe: func(ctx context.Context) piper.Pipe { return awsddb.Update(ddb, ctx, ddb.mapUpdate) }},
+		{name: "Delete", pipe: func(ctx context.Context) piper.Pipe { return awsddb.Delete(ddb, ctx, ddb.mapDelete) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipetest.Conformance(t, tt.pipe, input)
+		})
+	}
+}
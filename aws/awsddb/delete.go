@@ -30,7 +30,7 @@ func mapToDeleteInput[In any](mapfn MapDeleteFunction[In]) piper.Pipe {
 // against DynamoDB using the provided Deleter interface. It handles any errors
 // through the options error handler and returns the operation output.
 func sendDelete(d Deleter, ctx context.Context, opts *Options) piper.Pipe {
-	return pipeline.Map(func(in *dynamodb.DeleteItemInput) *dynamodb.DeleteItemOutput {
+	return pipeline.MapContext(ctx, func(ctx context.Context, in *dynamodb.DeleteItemInput) *dynamodb.DeleteItemOutput {
 		out, err := d.DeleteItem(ctx, in, opts.DynamoDBOptions...)
 		if err != nil {
 			opts.HandleError(err)
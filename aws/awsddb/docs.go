@@ -2,4 +2,7 @@
 // It implements pipeline components for common DynamoDB operations including Put, Get,
 // Query, Scan, Update, and Delete operations. The package allows for seamless integration
 // of DynamoDB operations within data processing pipelines.
+//
+// When the [pipeline.Flow] is traced, each DynamoDB call is made with the trace context of
+// its item, so that a traced client continues the trace of the item.
 package awsddb
@@ -17,7 +17,7 @@ type Getter interface {
 // against DynamoDB using the provided Getter interface. It handles any errors
 // through the options error handler and returns the operation output.
 func sendGet(g Getter, ctx context.Context, opts *Options) piper.Pipe {
-	return pipeline.Map(func(input *dynamodb.GetItemInput) *dynamodb.GetItemOutput {
+	return pipeline.MapContext(ctx, func(ctx context.Context, input *dynamodb.GetItemInput) *dynamodb.GetItemOutput {
 		output, err := g.GetItem(ctx, input, opts.DynamoDBOptions...)
 		if err != nil {
 			opts.HandleError(err)
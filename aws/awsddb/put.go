@@ -30,7 +30,7 @@ func mapToPut[In any](mapfn MapPutFunction[In]) piper.Pipe {
 // against DynamoDB using the provided [Putter] interface. It handles any errors
 // through the options error handler and returns the operation output.
 func sendPut(p Putter, ctx context.Context, opts *Options) piper.Pipe {
-	return pipeline.Map(func(in *dynamodb.PutItemInput) *dynamodb.PutItemOutput {
+	return pipeline.MapContext(ctx, func(ctx context.Context, in *dynamodb.PutItemInput) *dynamodb.PutItemOutput {
 		out, err := p.PutItem(ctx, in, opts.DynamoDBOptions...)
 		if err != nil {
 			opts.HandleError(err)
@@ -17,7 +17,7 @@ type Querier interface {
 // against DynamoDB using the provided Querier interface. It handles any errors
 // through the options error handler and returns the operation output.
 func sendQuery(q Querier, ctx context.Context, opts *Options) piper.Pipe {
-	return pipeline.Map(func(input *dynamodb.QueryInput) *dynamodb.QueryOutput {
+	return pipeline.MapContext(ctx, func(ctx context.Context, input *dynamodb.QueryInput) *dynamodb.QueryOutput {
 		output, err := q.Query(ctx, input, opts.DynamoDBOptions...)
 		if err != nil {
 			opts.HandleError(err)
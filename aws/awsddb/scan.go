@@ -17,7 +17,7 @@ type Scanner interface {
 // against DynamoDB using the provided Scanner interface. It handles any errors
 // through the options error handler and returns the operation output.
 func sendScan(s Scanner, ctx context.Context, opts *Options) piper.Pipe {
-	return pipeline.Map(func(input *dynamodb.ScanInput) *dynamodb.ScanOutput {
+	return pipeline.MapContext(ctx, func(ctx context.Context, input *dynamodb.ScanInput) *dynamodb.ScanOutput {
 		output, err := s.Scan(ctx, input, opts.DynamoDBOptions...)
 		if err != nil {
 			opts.HandleError(err)
@@ -17,7 +17,7 @@ type Updater interface {
 // against DynamoDB using the provided Updater interface. It handles any errors
 // through the options error handler and returns the operation output.
 func sendUpdate(u Updater, ctx context.Context, opts *Options) piper.Pipe {
-	return pipeline.Map(func(input *dynamodb.UpdateItemInput) *dynamodb.UpdateItemOutput {
+	return pipeline.MapContext(ctx, func(ctx context.Context, input *dynamodb.UpdateItemInput) *dynamodb.UpdateItemOutput {
 		output, err := u.UpdateItem(ctx, input, opts.DynamoDBOptions...)
 		if err != nil {
 			opts.HandleError(err)
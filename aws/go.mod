@@ -4,7 +4,7 @@ go 1.23.2
 
 require (
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.39.2
-	github.com/nisimpson/piper v0.0.0-20250107220201-027173a48de0
+	github.com/nisimpson/piper v0.3.0
 )
 
 require (
@@ -16,3 +16,7 @@ require (
 	github.com/aws/smithy-go v1.22.1 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 )
+
+// The required releases are tagged in one series by `make release`. Build against the modules
+// of this repository, so that a checkout builds as a whole.
+replace github.com/nisimpson/piper => ..
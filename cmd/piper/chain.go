@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/throttle"
+	"golang.org/x/time/rate"
+)
+
+// cli builds the components of a pipeline from their command-line description.
+type cli struct {
+	// ctx is done when the pipeline should stop reading its input.
+	ctx context.Context
+	// dryRun builds the pipeline to print its topology without running it: sources send no
+	// items, and no file is opened or created.
+	dryRun bool
+	// stdin, stdout and stderr are the standard streams of the command.
+	stdin          io.Reader
+	stdout, stderr io.Writer
+	// closers are the files opened by the components, closed when the command exits.
+	closers []io.Closer
+	// failed is set once an error is reported, failing the command.
+	failed atomic.Bool
+}
+
+// close closes the files opened by the components.
+func (c *cli) close() {
+	for _, closer := range c.closers {
+		closer.Close()
+	}
+}
+
+// open opens the file for reading, unless path is stdin.
+func (c *cli) open(path string) (io.Reader, error) {
+	if path == "stdin" {
+		return c.stdin, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	c.closers = append(c.closers, f)
+	return f, nil
+}
+
+// create creates the file for writing, unless path is stdout. Nothing is written by a dry run.
+func (c *cli) create(path string) (io.Writer, error) {
+	if c.dryRun {
+		return io.Discard, nil
+	}
+	if path == "stdout" {
+		return c.stdout, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	c.closers = append(c.closers, f)
+	return f, nil
+}
+
+// source builds the flow described by a --from value.
+func (c *cli) source(value string) (pipeline.Flow, error) {
+	kind, arg, _ := strings.Cut(value, ":")
+	switch kind {
+	case "lines", "jsonl":
+		if c.dryRun {
+			return pipeline.From(idleSource("reader")), nil
+		}
+		r, err := c.open(arg)
+		if err != nil {
+			return pipeline.Flow{}, err
+		}
+		if kind == "jsonl" {
+			return c.lines(r).Thru(pipeline.DecodeJSONLines[any](func(o *pipeline.JSONLinesOptions) {
+				o.HandleError = c.report
+			})), nil
+		}
+		return c.lines(r), nil
+	case "cmd":
+		if arg == "" {
+			return pipeline.Flow{}, fmt.Errorf("source %q: missing command", value)
+		}
+		if c.dryRun {
+			return pipeline.From(idleSource("command")).Thru(pipeline.FlatMap(splitLines)), nil
+		}
+		// unlike shell stages, a failing source command has nothing to filter and fails the run
+		return pipeline.FromCmd(c.shell(arg), func(o *pipeline.CommandPipeOptions[string]) {
+			o.HandleError = func(err error) { c.report(fmt.Errorf("source %q: %w", value, err)) }
+		}).Thru(pipeline.FlatMap(splitLines)), nil
+	case "http":
+		if arg == "" {
+			return pipeline.Flow{}, fmt.Errorf("source %q: missing url", value)
+		}
+		if c.dryRun {
+			return pipeline.From(idleSource(pipeline.HttpName(http.MethodGet, arg))), nil
+		}
+		return pipeline.FromHTTP(http.MethodGet, arg, nil, c.readBody), nil
+	default:
+		return pipeline.Flow{}, fmt.Errorf("unknown source %q", value)
+	}
+}
+
+// stage builds the pipe described by a --thru value.
+func (c *cli) stage(value string) (piper.Pipe, error) {
+	kind, arg, _ := strings.Cut(value, ":")
+	switch kind {
+	case "shell":
+		if arg == "" {
+			return nil, fmt.Errorf("stage %q: missing command", value)
+		}
+		return pipeline.ExecCmd(c.shell(arg), func(o *pipeline.CommandPipeOptions[string]) {
+			o.HandleError = func(err error) {
+				if !errors.Is(err, errFailed) {
+					c.report(err)
+				}
+			}
+		}), nil
+	case "batch":
+		if size, err := strconv.Atoi(arg); err == nil && size > 0 {
+			return pipeline.BatchN[any](size), nil
+		}
+		if d, err := time.ParseDuration(arg); err == nil && d > 0 {
+			return pipeline.BatchEvery[any](d), nil
+		}
+		return nil, fmt.Errorf("stage %q: expected a positive size or duration", value)
+	case "take", "drop":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("stage %q: expected a count", value)
+		}
+		if kind == "drop" {
+			return pipeline.DropN(n), nil
+		}
+		return pipeline.TakeN(n), nil
+	case "unique":
+		return pipeline.Unique[any](), nil
+	case "window":
+		size, step, err := parseWindow(arg)
+		if err != nil {
+			return nil, fmt.Errorf("stage %q: %w", value, err)
+		}
+		return pipeline.SlidingWindow[any](func(so *pipeline.SlidingWindowOptions) {
+			so.WindowSize = size
+			so.StepSize = step
+		}), nil
+	case "limit":
+		r, err := strconv.ParseFloat(arg, 64)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("stage %q: expected a positive rate", value)
+		}
+		limiter := rate.NewLimiter(rate.Limit(r), 1)
+		// the limiter keeps draining the items in flight on interrupt, until its input closes
+		return pipeline.Named("limit("+arg+"/s)", throttle.Limit(context.WithoutCancel(c.ctx), limiter)), nil
+	case "http":
+		method, url, ok := strings.Cut(arg, " ")
+		if !ok {
+			method, url = http.MethodPost, arg
+		}
+		if url == "" {
+			return nil, fmt.Errorf("stage %q: missing url", value)
+		}
+		return pipeline.SendHTTP(method, url, c.readBody), nil
+	default:
+		return nil, fmt.Errorf("unknown stage %q", value)
+	}
+}
+
+// sink builds the sink described by a --to value, along with a function waiting for it to finish
+// and returning its error, if any.
+func (c *cli) sink(value string) (piper.Sink, func() error, error) {
+	kind, arg, _ := strings.Cut(value, ":")
+	switch kind {
+	case "lines", "jsonl":
+		w, err := c.create(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		encode := encodeLine
+		if kind == "jsonl" {
+			encode = encodeJSON
+		}
+		sink := pipeline.ToWriter(w, encode, func(o *pipeline.WriterOptions) {
+			o.HandleError = c.report
+		})
+		return namedSink{sink, value}, sink.Wait, nil
+	case "null":
+		sink := pipeline.ToNull()
+		return sink, func() error {
+			sink.Wait()
+			return nil
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown sink %q", value)
+	}
+}
+
+// idleSource is a [piper.Source] named after the source it replaces in a dry run, sending no items.
+type idleSource string
+
+func (s idleSource) Out() <-chan any {
+	out := make(chan any)
+	close(out)
+	return out
+}
+
+// Name implements [pipeline.Namer].
+func (s idleSource) Name() string { return string(s) }
+
+// report writes the error to stderr, failing the command.
+func (c *cli) report(err error) {
+	c.failed.Store(true)
+	fmt.Fprintf(c.stderr, "piper: %v\n", err)
+}
+
+// shell returns a [pipeline.Command] running the command line with sh, writing each item
+// to its standard input as a line. Commands exiting with a non-zero status fail silently,
+// so that commands such as grep act as filters.
+func (c *cli) shell(line string) pipeline.Command[any, string] {
+	return pipeline.CommandFunc(func(input any) (string, int, error) {
+		stdin, err := encodeLine(input)
+		if err != nil {
+			return "", 0, err
+		}
+		cmd := exec.Command("sh", "-c", line)
+		cmd.Stdin = bytes.NewReader(append(stdin, '\n'))
+		cmd.Stderr = c.stderr
+		out, err := cmd.Output()
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return "", exit.ExitCode(), errFailed
+		}
+		return strings.TrimSuffix(string(out), "\n"), 0, err
+	})
+}
+
+// errFailed is returned by shell commands exiting with a non-zero status.
+var errFailed = errors.New("command failed")
+
+// readBody is a [pipeline.HttpPipeOptions] function sending the body of each
+// response downstream as a string.
+func (c *cli) readBody(o *pipeline.HttpPipeOptions) {
+	o.HandleError = c.report
+	o.HandleResponse = func(res *http.Response) (any, error) {
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return strings.TrimSuffix(string(body), "\n"), err
+	}
+}
+
+// parseWindow parses the size and optional step of a sliding window.
+func parseWindow(arg string) (size int, step int, err error) {
+	sizeArg, stepArg, hasStep := strings.Cut(arg, ",")
+	if size, err = strconv.Atoi(sizeArg); err != nil || size < 1 {
+		return 0, 0, errors.New("expected a positive size")
+	}
+	step = 1
+	if hasStep {
+		if step, err = strconv.Atoi(stepArg); err != nil || step < 1 {
+			return 0, 0, errors.New("expected a positive step")
+		}
+	}
+	return size, step, nil
+}
+
+// splitLines splits the output of a command into its lines.
+func splitLines(out string) []string {
+	if out == "" {
+		return nil
+	}
+	return strings.Split(out, "\n")
+}
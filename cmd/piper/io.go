@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// encodeLine encodes the item as a line, without its newline. Strings are written as is,
+// and other items as JSON values.
+func encodeLine(item any) ([]byte, error) {
+	if s, ok := item.(string); ok {
+		return []byte(s), nil
+	}
+	return encodeJSON(item)
+}
+
+// encodeJSON encodes the item as a JSON value, without its newline.
+func encodeJSON(item any) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(item); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// lines returns a flow of the lines read from r, as strings. Reading stops at the end of
+// the input, or once the context of the command is done.
+func (c *cli) lines(r io.Reader) pipeline.Flow {
+	source := pipeline.FromReader(r, nil, func(o *pipeline.ReaderOptions) {
+		o.MaxTokenSize = 1 << 20
+		o.HandleError = c.report
+	})
+	// bind the context to the reader alone, so that the items in flight are drained
+	// rather than discarded once it is done
+	return pipeline.From(readerSource{source.WithContext(c.ctx)})
+}
+
+// readerSource is the [piper.Source] of the lines read by the command.
+type readerSource struct {
+	piper.Source
+}
+
+// Name implements [pipeline.Namer].
+func (readerSource) Name() string { return "reader" }
+
+// namedSink is a [piper.Sink] named after its --to value.
+type namedSink struct {
+	piper.Sink
+	name string
+}
+
+// Name implements [pipeline.Namer].
+func (s namedSink) Name() string { return s.name }
+
+// lockedWriter serializes writes to w, shared by the concurrent stages of a pipeline.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
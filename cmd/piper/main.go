@@ -0,0 +1,225 @@
+// Command piper runs data pipelines over stdin and stdout, either from a declarative
+// pipeline spec or from an inline chain of stages:
+//
+//	piper --from lines:stdin --thru 'shell:grep foo' --thru batch:100 --to jsonl:stdout
+//	piper --spec pipeline.yaml
+//
+// On interrupt, piper stops reading its input and drains the items in flight before exiting;
+// a second interrupt exits immediately. piper exits with status 1 if any error is reported
+// while running the pipeline. Run piper -h for the supported sources, stages and sinks.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/spec"
+)
+
+const usage = `Usage:
+  piper --spec FILE [--graph]
+  piper --from SOURCE [--thru STAGE]... [--to SINK] [--graph]
+
+Sources:
+  lines:stdin, lines:FILE   read each line as a string
+  jsonl:stdin, jsonl:FILE   read each line as a JSON value
+  cmd:COMMAND               run the shell command, reading each line of its output
+  http:URL                  send a GET request, reading the response body
+
+Stages:
+  shell:COMMAND             run the shell command with each item as its input; items
+                            for which the command fails are dropped
+  batch:SIZE, batch:DURATION
+                            group items by count, or by time interval
+  take:N, drop:N            keep or drop the first N items
+  unique                    drop duplicate items
+  window:SIZE[,STEP]        group items in a sliding window
+  limit:RATE                limit to RATE items per second
+  http:[METHOD ]URL         send each item as a request body (POST by default),
+                            sending the response body downstream
+
+Sinks:
+  lines:stdout, lines:FILE  write each item on its own line (default lines:stdout)
+  jsonl:stdout, jsonl:FILE  write each item as a JSON value on its own line
+  null                      discard items
+
+Flags:
+`
+
+// stages is a repeatable flag collecting the --thru stages.
+type stages []string
+
+func (s *stages) String() string { return strings.Join(*s, " ") }
+
+func (s *stages) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func main() {
+	ctx, stop := drainOnSignal()
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// drainOnSignal returns a context done on the first interrupt or termination signal.
+// A second signal exits immediately.
+func drainOnSignal() (context.Context, func()) {
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		signals     = make(chan os.Signal, 2)
+	)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+		<-signals
+		os.Exit(130)
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// run runs the command with the arguments, returning its exit code. Input is no longer
+// read once ctx is done.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var (
+		flags       = flag.NewFlagSet("piper", flag.ContinueOnError)
+		specFile    = flags.String("spec", "", "run the pipeline spec in `FILE` (YAML or JSON)")
+		from        = flags.String("from", "", "read items from `SOURCE`")
+		to          = flags.String("to", "lines:stdout", "write items to `SINK`")
+		graph       = flags.Bool("graph", false, "print the topology of the pipeline instead of running it")
+		graphFormat = flags.String("graph-format", "dot", "print the topology in `FORMAT`: dot or mermaid")
+		thru        stages
+	)
+
+	flags.Var(&thru, "thru", "process items with `STAGE`; may be repeated")
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if (*specFile == "") == (*from == "") {
+		fmt.Fprintln(stderr, "piper: exactly one of --spec or --from is required")
+		flags.Usage()
+		return 2
+	}
+	if *graphFormat != "dot" && *graphFormat != "mermaid" {
+		fmt.Fprintf(stderr, "piper: unknown graph format %q\n", *graphFormat)
+		return 2
+	}
+
+	var (
+		c    = &cli{ctx: ctx, dryRun: *graph, stdin: stdin, stdout: stdout, stderr: &lockedWriter{w: stderr}}
+		flow pipeline.Flow
+		wait func() error
+		err  error
+	)
+
+	defer c.close()
+
+	if *specFile != "" {
+		flow, wait, err = c.spec(*specFile)
+	} else {
+		flow, wait, err = c.chain(*from, thru, *to)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "piper: %v\n", err)
+		return 1
+	}
+
+	// errors handled by the components are reported as they occur
+	if err := wait(); err != nil && !c.failed.Load() {
+		c.report(err)
+	}
+	if *graph {
+		if *graphFormat == "mermaid" {
+			fmt.Fprint(stdout, flow.Graph().Mermaid())
+		} else {
+			fmt.Fprint(stdout, flow.Graph().DOT())
+		}
+	}
+	if c.failed.Load() {
+		return 1
+	}
+	return 0
+}
+
+// spec builds the pipeline spec in the file. Specs may read lines from stdin
+// with a channel source named "stdin".
+func (c *cli) spec(path string) (pipeline.Flow, func() error, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pipeline.Flow{}, nil, err
+	}
+
+	registry := spec.NewRegistry()
+	// a dry run leaves stdin unread
+	input := make(chan any)
+	if !c.dryRun {
+		go func() {
+			defer close(input)
+			for line := range c.lines(c.stdin).Out() {
+				input <- line
+			}
+		}()
+	}
+	spec.RegisterChannel(registry, "stdin", input)
+
+	p, err := spec.Load(data, registry, func(bo *spec.BuildOptions) {
+		bo.Stop = c.ctx
+		bo.Stdout = c.stdout
+		bo.Stderr = c.stderr
+		bo.HandleError = c.report
+		bo.DryRun = c.dryRun
+	})
+	if err != nil {
+		return pipeline.Flow{}, nil, fmt.Errorf("%s:\n%w", path, err)
+	}
+	return p.Flow(), func() error {
+		p.Wait()
+		return nil
+	}, nil
+}
+
+// chain builds the pipeline from the inline source, stages and sink.
+func (c *cli) chain(from string, thru []string, to string) (pipeline.Flow, func() error, error) {
+	flow, err := c.source(from)
+	if err != nil {
+		return flow, nil, err
+	}
+
+	for _, value := range thru {
+		stage, err := c.stage(value)
+		if err != nil {
+			return flow, nil, err
+		}
+		flow = flow.Thru(stage)
+	}
+
+	sink, wait, err := c.sink(to)
+	if err != nil {
+		return flow, nil, err
+	}
+
+	flow.To(sink)
+	return flow, wait, nil
+}
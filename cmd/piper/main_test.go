@@ -0,0 +1,402 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Run runs the command with the arguments and input, returning its exit code and outputs.
+func Run(ctx context.Context, input string, args ...string) (code int, stdout string, stderr string) {
+	var out, errs bytes.Buffer
+	code = run(ctx, args, strings.NewReader(input), &out, &errs)
+	return code, out.String(), errs.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		args  []string
+		want  string
+	}{
+		{
+			name:  "lines to jsonl batches",
+			input: "a\nb\nc\n",
+			args:  []string{"--from", "lines:stdin", "--thru", "batch:2", "--to", "jsonl:stdout"},
+			want:  "[\"a\",\"b\"]\n[\"c\"]\n",
+		},
+		{
+			name:  "jsonl to lines",
+			input: "{\"id\":1}\n\"two\"\n3\n",
+			args:  []string{"--from", "jsonl:stdin"},
+			want:  "{\"id\":1}\ntwo\n3\n",
+		},
+		{
+			name:  "counting stages",
+			input: "a\na\nb\nc\nd\ne\n",
+			args:  []string{"--from", "lines:stdin", "--thru", "unique", "--thru", "drop:1", "--thru", "take:3", "--thru", "limit:1000"},
+			want:  "b\nc\nd\n",
+		},
+		{
+			name:  "sliding window",
+			input: "1\n2\n3\n4\n",
+			args:  []string{"--from", "lines:stdin", "--thru", "window:3,1"},
+			want:  "[\"1\",\"2\",\"3\"]\n[\"2\",\"3\",\"4\"]\n",
+		},
+		{
+			name:  "null sink",
+			input: "a\n",
+			args:  []string{"--from", "lines:stdin", "--to", "null"},
+			want:  "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := Run(context.Background(), tt.input, tt.args...)
+			if code != 0 {
+				t.Fatalf("got exit code %d: %s", code, stderr)
+			}
+			if stdout != tt.want {
+				t.Errorf("got %q, want %q", stdout, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunShell(t *testing.T) {
+	if os.Getenv("SKIP_SHELL_TESTS") != "" {
+		t.Skip("skipping shell tests")
+	}
+
+	t.Parallel()
+
+	t.Run("filters with shell commands", func(t *testing.T) {
+		code, stdout, stderr := Run(context.Background(), "foo\nbar\nfood\n",
+			"--from", "lines:stdin", "--thru", "shell:grep foo", "--thru", "shell:tr a-z A-Z")
+		if code != 0 {
+			t.Fatalf("got exit code %d: %s", code, stderr)
+		}
+		if want := "FOO\nFOOD\n"; stdout != want {
+			t.Errorf("got %q, want %q", stdout, want)
+		}
+	})
+
+	t.Run("reads command output", func(t *testing.T) {
+		code, stdout, stderr := Run(context.Background(), "", "--from", "cmd:printf 'a\\nb\\n'")
+		if code != 0 {
+			t.Fatalf("got exit code %d: %s", code, stderr)
+		}
+		if want := "a\nb\n"; stdout != want {
+			t.Errorf("got %q, want %q", stdout, want)
+		}
+	})
+}
+
+func TestRunHTTP(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.Method, body)
+	}))
+	defer server.Close()
+
+	code, stdout, stderr := Run(context.Background(), "", "--from", "http:"+server.URL, "--thru", "http:PUT "+server.URL)
+	if code != 0 {
+		t.Fatalf("got exit code %d: %s", code, stderr)
+	}
+	if want := "PUT \"GET \"\n"; stdout != want {
+		t.Errorf("got %q, want %q", stdout, want)
+	}
+}
+
+func TestRunSpec(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "pipeline.yaml")
+	err := os.WriteFile(path, []byte(`
+source: {type: channel, name: stdin}
+stages:
+  - {type: batch, size: 2}
+sink: {type: stdout}
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("runs the spec", func(t *testing.T) {
+		code, stdout, stderr := Run(context.Background(), "a\nb\nc\n", "--spec", path)
+		if code != 0 {
+			t.Fatalf("got exit code %d: %s", code, stderr)
+		}
+		if want := "[a b]\n[c]\n"; stdout != want {
+			t.Errorf("got %q, want %q", stdout, want)
+		}
+	})
+
+	t.Run("prints the graph", func(t *testing.T) {
+		code, stdout, stderr := Run(context.Background(), "", "--spec", path, "--graph", "--graph-format", "mermaid")
+		if code != 0 {
+			t.Fatalf("got exit code %d: %s", code, stderr)
+		}
+		want := "flowchart LR\n" +
+			"\tn0([\"channel\"])\n" +
+			"\tn1[\"batch(2)\"]\n" +
+			"\tn2[(\"stdout\")]\n" +
+			"\tn0 --> n1\n" +
+			"\tn1 --> n2\n"
+		if stdout != want {
+			t.Errorf("got %q, want %q", stdout, want)
+		}
+	})
+}
+
+func TestRunGraph(t *testing.T) {
+	t.Parallel()
+
+	t.Run("prints the topology", func(t *testing.T) {
+		code, stdout, stderr := Run(context.Background(), "", "--from", "lines:stdin", "--thru", "take:1", "--to", "jsonl:stdout", "--graph")
+		if code != 0 {
+			t.Fatalf("got exit code %d: %s", code, stderr)
+		}
+		want := "digraph pipeline {\n" +
+			"\trankdir=LR;\n" +
+			"\tn0 [label=\"reader\", shape=invhouse];\n" +
+			"\tn1 [label=\"take(1)\", shape=box];\n" +
+			"\tn2 [label=\"jsonl:stdout\", shape=house];\n" +
+			"\tn0 -> n1;\n" +
+			"\tn1 -> n2;\n" +
+			"}\n"
+		if stdout != want {
+			t.Errorf("got %q, want %q", stdout, want)
+		}
+	})
+
+	t.Run("does not run the pipeline", func(t *testing.T) {
+		var (
+			requests atomic.Int32
+			server   = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+			}))
+			path  = filepath.Join(t.TempDir(), "out.jsonl")
+			input = &syncBuffer{}
+		)
+		defer server.Close()
+		fmt.Fprintln(input, "a")
+
+		for _, args := range [][]string{
+			{"--from", "http:" + server.URL + "/items", "--thru", "http:" + server.URL, "--to", "jsonl:" + path},
+			{"--from", "lines:stdin", "--thru", "http:" + server.URL, "--to", "jsonl:" + path},
+		} {
+			var out, errs bytes.Buffer
+			if code := run(context.Background(), append(args, "--graph"), input, &out, &errs); code != 0 {
+				t.Fatalf("got exit code %d: %s", code, errs.String())
+			}
+			if !strings.Contains(out.String(), "digraph") {
+				t.Errorf("got %q, want a graph", out.String())
+			}
+		}
+
+		if requests.Load() != 0 {
+			t.Errorf("got %d requests, want none", requests.Load())
+		}
+		if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("got %v, want the sink file not to be created", err)
+		}
+		if got := input.String(); got != "a\n" {
+			t.Errorf("got stdin %q, want it unread", got)
+		}
+	})
+}
+
+func TestRunDrain(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		input, feed = io.Pipe()
+		stdout      = &syncBuffer{}
+		done        = make(chan int)
+	)
+
+	defer feed.Close()
+
+	go func() {
+		done <- run(ctx, []string{"--from", "lines:stdin"}, input, stdout, io.Discard)
+	}()
+
+	fmt.Fprintln(feed, "a")
+	deadline := time.Now().Add(time.Second)
+	for stdout.String() != "a\n" {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q before deadline", stdout.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// stop reading while the input remains open
+	cancel()
+
+	select {
+	case code := <-done:
+		if code != 0 {
+			t.Errorf("got exit code %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pipeline did not drain")
+	}
+}
+
+func TestRunLimit(t *testing.T) {
+	t.Parallel()
+
+	var (
+		ctx, cancel = context.WithCancel(context.Background())
+		stdout      = &syncBuffer{}
+		done        = make(chan int)
+	)
+
+	go func() {
+		// command sources are not stopped on interrupt, leaving items in flight behind the limiter
+		args := []string{"--from", `cmd:printf 'a\nb\nc\n'`, "--thru", "limit:20"}
+		done <- run(ctx, args, strings.NewReader(""), stdout, io.Discard)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for stdout.String() == "" {
+		if time.Now().After(deadline) {
+			t.Fatal("no output before deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+
+	select {
+	case code := <-done:
+		if code != 0 {
+			t.Errorf("got exit code %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("limiter did not drain")
+	}
+	if got, want := stdout.String(), "a\nb\nc\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fails when the sink fails", func(t *testing.T) {
+		var errs bytes.Buffer
+		code := run(context.Background(), []string{"--from", "lines:stdin"}, strings.NewReader("a\n"), FailingWriter{}, &errs)
+		if code != 1 {
+			t.Errorf("got exit code %d, want 1", code)
+		}
+		if want := "disk full"; !strings.Contains(errs.String(), want) {
+			t.Errorf("got %q, want it to contain %q", errs.String(), want)
+		}
+	})
+
+	t.Run("fails when a stage reports an error", func(t *testing.T) {
+		code, stdout, stderr := Run(context.Background(), "{\"id\":1}\n{bad\n", "--from", "jsonl:stdin")
+		if code != 1 {
+			t.Errorf("got exit code %d, want 1", code)
+		}
+		if want := "{\"id\":1}\n"; stdout != want {
+			t.Errorf("got %q, want %q", stdout, want)
+		}
+		if stderr == "" {
+			t.Error("expected the error to be reported")
+		}
+	})
+
+	t.Run("fails when the source command fails", func(t *testing.T) {
+		code, stdout, stderr := Run(context.Background(), "", "--from", "cmd:false")
+		if code != 1 {
+			t.Errorf("got exit code %d, want 1", code)
+		}
+		if stdout != "" {
+			t.Errorf("got %q, want no output", stdout)
+		}
+		if want := `source "cmd:false": command failed`; !strings.Contains(stderr, want) {
+			t.Errorf("got %q, want it to contain %q", stderr, want)
+		}
+	})
+}
+
+// FailingWriter fails every write.
+type FailingWriter struct{}
+
+func (FailingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestRunUsage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{name: "missing source", args: nil, code: 2, want: "exactly one of --spec or --from is required"},
+		{name: "unknown source", args: []string{"--from", "tcp:80"}, code: 1, want: `unknown source "tcp:80"`},
+		{name: "unknown stage", args: []string{"--from", "lines:stdin", "--thru", "zip"}, code: 1, want: `unknown stage "zip"`},
+		{name: "invalid stage", args: []string{"--from", "lines:stdin", "--thru", "batch:x"}, code: 1, want: "expected a positive size or duration"},
+		{name: "unknown sink", args: []string{"--from", "lines:stdin", "--to", "tcp:80"}, code: 1, want: `unknown sink "tcp:80"`},
+		{name: "unknown graph format", args: []string{"--from", "lines:stdin", "--graph-format", "png"}, code: 2, want: `unknown graph format "png"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := Run(context.Background(), "", tt.args...)
+			if code != tt.code {
+				t.Errorf("got exit code %d, want %d", code, tt.code)
+			}
+			if !strings.Contains(stderr, tt.want) {
+				t.Errorf("got %q, want it to contain %q", stderr, tt.want)
+			}
+		})
+	}
+}
+
+// syncBuffer is a [bytes.Buffer] safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Read(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -1,3 +1,3 @@
 module github.com/nisimpson/piper
 
-go 1.22.5
+go 1.23.2
@@ -0,0 +1,16 @@
+/*
+Package otel adapts OpenTelemetry tracers to the [pipeline.Tracer] interface, so the spans
+started by the stages of a [pipeline.Flow] are exported by an OpenTelemetry SDK.
+
+Usage:
+
+	// Trace each item with a tracer from an OpenTelemetry tracer provider
+	tracer := otel.NewTracer(provider.Tracer("my-service"))
+
+	pipeline.
+		From(source).
+		WithTracer(tracer).
+		Thru(transform).
+		To(destination)
+*/
+package otel
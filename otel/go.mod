@@ -0,0 +1,22 @@
+module github.com/nisimpson/piper/otel
+
+go 1.23.2
+
+require (
+	github.com/nisimpson/piper v0.3.0
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/sdk v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+	golang.org/x/sys v0.21.0 // indirect
+)
+
+// The required releases are tagged in one series by `make release`. Build against the modules
+// of this repository, so that a checkout builds as a whole.
+replace github.com/nisimpson/piper => ..
@@ -0,0 +1,107 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nisimpson/piper/pipeline"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer is a [pipeline.Tracer] that starts OpenTelemetry spans.
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates a new [pipeline.Tracer] starting its spans with the OpenTelemetry tracer.
+// Spans are parented using the OpenTelemetry context propagation, so items traced by a
+// [pipeline.Flow] continue any trace found in the context of the [pipeline.Message] they arrive in.
+func NewTracer(t trace.Tracer) pipeline.Tracer {
+	return tracer{tracer: t}
+}
+
+// Start implements [pipeline.Tracer].
+func (t tracer) Start(ctx context.Context, name string, opts ...func(*pipeline.SpanOptions)) (context.Context, pipeline.Span) {
+	options := pipeline.SpanOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	links := make([]trace.Link, 0, len(options.Links))
+	for _, link := range options.Links {
+		links = append(links, trace.Link{SpanContext: toSpanContext(link)})
+	}
+
+	ctx, s := t.tracer.Start(ctx, name, trace.WithLinks(links...))
+	return ctx, span{span: s}
+}
+
+// span is a [pipeline.Span] backed by an OpenTelemetry span.
+type span struct {
+	span trace.Span
+}
+
+// SpanContext implements [pipeline.Span].
+func (s span) SpanContext() pipeline.SpanContext {
+	return fromSpanContext(s.span.SpanContext())
+}
+
+// SetAttribute implements [pipeline.Span]. Values without an OpenTelemetry attribute type
+// are recorded in their default string format.
+func (s span) SetAttribute(key string, value any) {
+	s.span.SetAttributes(toAttribute(key, value))
+}
+
+// RecordError implements [pipeline.Span], setting the span status to [codes.Error].
+func (s span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End implements [pipeline.Span].
+func (s span) End() {
+	s.span.End()
+}
+
+// toSpanContext converts the span context to its OpenTelemetry equivalent.
+func toSpanContext(sc pipeline.SpanContext) trace.SpanContext {
+	config := trace.SpanContextConfig{
+		TraceID: trace.TraceID(sc.TraceID),
+		SpanID:  trace.SpanID(sc.SpanID),
+	}
+	if sc.Sampled {
+		config.TraceFlags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(config)
+}
+
+// fromSpanContext converts the OpenTelemetry span context to a [pipeline.SpanContext].
+func fromSpanContext(sc trace.SpanContext) pipeline.SpanContext {
+	return pipeline.SpanContext{
+		TraceID: sc.TraceID(),
+		SpanID:  sc.SpanID(),
+		Sampled: sc.IsSampled(),
+	}
+}
+
+// toAttribute converts the key-value pair to an OpenTelemetry attribute.
+func toAttribute(key string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case fmt.Stringer:
+		return attribute.Stringer(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,97 @@
+package otel_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nisimpson/piper/otel"
+	"github.com/nisimpson/piper/pipeline"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestNewTracer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("exports a span per item per stage", func(t *testing.T) {
+		var (
+			recorder = tracetest.NewSpanRecorder()
+			provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			tracer   = otel.NewTracer(provider.Tracer("test"))
+			sink     = pipeline.ToSlice[int]()
+		)
+
+		pipeline.FromSlice(1).
+			WithTracer(tracer).
+			Thru(pipeline.Map(func(i int) int { return i + 1 })).
+			To(sink)
+
+		sink.Slice()
+
+		spans := recorder.Ended()
+		if len(spans) != 2 {
+			t.Fatalf("got %d spans, want 2", len(spans))
+		}
+
+		// spans end in any order, as the sink may finish before the map span is ended.
+		mapped, sunk := spans[0], spans[1]
+		if mapped.Name() != "map" {
+			mapped, sunk = sunk, mapped
+		}
+		if mapped.Name() != "map" || sunk.Name() != "slice" {
+			t.Fatalf("got spans %q and %q", mapped.Name(), sunk.Name())
+		}
+		if sunk.Parent().SpanID() != mapped.SpanContext().SpanID() {
+			t.Errorf("slice span is not a child of the map span")
+		}
+		if sunk.SpanContext().TraceID() != mapped.SpanContext().TraceID() {
+			t.Errorf("spans do not share a trace")
+		}
+	})
+
+	t.Run("links batches to their members", func(t *testing.T) {
+		var (
+			recorder = tracetest.NewSpanRecorder()
+			provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			tracer   = otel.NewTracer(provider.Tracer("test"))
+			sink     = pipeline.ToSlice[[]int]()
+		)
+
+		pipeline.FromSlice(1, 2).
+			WithTracer(tracer).
+			Thru(pipeline.BatchN[int](2)).
+			To(sink)
+
+		sink.Slice()
+
+		for _, span := range recorder.Ended() {
+			if span.Name() == "batch(2)" && len(span.Links()) == 2 {
+				return
+			}
+		}
+		t.Errorf("expected a batch span linked to both members")
+	})
+
+	t.Run("records errors", func(t *testing.T) {
+		var (
+			recorder = tracetest.NewSpanRecorder()
+			provider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			tracer   = otel.NewTracer(provider.Tracer("test"))
+			_, span  = tracer.Start(context.Background(), "stage")
+		)
+
+		span.SetAttribute("count", 1)
+		span.RecordError(errors.New("an error"))
+		span.End()
+
+		got := recorder.Ended()[0]
+		if got.Status().Code != codes.Error {
+			t.Errorf("got status %v, want %v", got.Status().Code, codes.Error)
+		}
+		if attrs := got.Attributes(); len(attrs) != 1 || attrs[0].Value.AsInt64() != 1 {
+			t.Errorf("got attributes %v", attrs)
+		}
+	})
+}
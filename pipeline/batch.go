@@ -1,6 +1,8 @@
 package pipeline
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/nisimpson/piper"
@@ -12,13 +14,31 @@ type BatcherOptions struct {
 	// For an unbounded size, set MaxSize to any value less than 1.
 	MaxSize int
 	// Interval is the maximum time to wait before sending a batch, even if MaxSize hasn't been reached.
-	// To disable, set Interval to a zero duration.
+	// The wait restarts whenever an item is received. To disable, set Interval to a zero duration.
 	Interval time.Duration
+	// Clock schedules the interval. Defaults to [SystemClock].
+	Clock Clock
+}
+
+// name returns the default name of a batcher configured with these options,
+// such as "batch(10)" or "batch(10, 1s)".
+func (o BatcherOptions) name() string {
+	switch {
+	case o.MaxSize > 0 && o.Interval > 0:
+		return fmt.Sprintf("batch(%d, %s)", o.MaxSize, o.Interval)
+	case o.MaxSize > 0:
+		return fmt.Sprintf("batch(%d)", o.MaxSize)
+	case o.Interval > 0:
+		return fmt.Sprintf("batch(%s)", o.Interval)
+	default:
+		return "batch"
+	}
 }
 
 // batcher implements a pipeline component that groups incoming items into batches
 // based on size and/or time constraints.
 type batcher[In any] struct {
+	*stage
 	// in receives individual items to be batched
 	in chan any
 	// out sends completed batches
@@ -32,11 +52,16 @@ type batcher[In any] struct {
 func Batch[In any](opts ...func(*BatcherOptions)) piper.Pipe {
 	options := BatcherOptions{
 		MaxSize: 1,
+		Clock:   SystemClock(),
 	}
 	for _, opt := range opts {
 		opt(&options)
 	}
+	if options.Clock == nil {
+		options.Clock = SystemClock()
+	}
 	pipe := batcher[In]{
+		stage:   newStage(options.name()),
 		in:      make(chan any),
 		out:     make(chan any),
 		options: options,
@@ -56,12 +81,13 @@ func BatchN[In any](size int) piper.Pipe {
 
 // BatchEvery creates a new batcher that sends batches at regular time intervals.
 // Any items received during the interval will be included in the next batch. This is a
-// convenience wrapper around [Batch] that leaves the maximum size unbounded.
-func BatchEvery[In any](d time.Duration) piper.Pipe {
-	return Batch[In](func(bo *BatcherOptions) {
+// convenience wrapper around [Batch] that leaves the maximum size unbounded; further options,
+// such as the [Clock], may be provided.
+func BatchEvery[In any](d time.Duration, opts ...func(*BatcherOptions)) piper.Pipe {
+	return Batch[In](append([]func(*BatcherOptions){func(bo *BatcherOptions) {
 		bo.Interval = d
 		bo.MaxSize = -1
-	})
+	}}, opts...)...)
 }
 
 func (b batcher[In]) In() chan<- any  { return b.in }
@@ -70,48 +96,90 @@ func (b batcher[In]) Out() <-chan any { return b.out }
 // start begins the batching process, collecting items and sending batches based on the configured options.
 // It handles both size-based and time-based batching strategies.
 func (b batcher[In]) start() {
-	batch := b.newSlice()
+	var (
+		batch   = b.newSlice()
+		members = make([]member, 0, cap(batch)) // members holds the received items, including any envelopes
+	)
 	defer close(b.out)
+	defer b.finish()
 
-	if b.options.Interval == 0 {
+	if b.options.Interval <= 0 {
 		for {
 			input, next := <-b.in
 			if !next {
-				b.send(batch)
+				b.send(batch, members)
 				return
 			}
-			batch = append(batch, input.(In))
+			members = append(members, member{item: input, receipt: b.receive(input)})
+			batch = append(batch, unwrap(input).(In))
 			if len(batch) == b.options.MaxSize {
-				batch = b.send(batch)
+				batch, members = b.send(batch, members)
 			}
 		}
 	}
 
+	timer := b.options.Clock.NewTimer(b.options.Interval)
+	defer timer.Stop()
+
 	for {
 		select {
 		case input, next := <-b.in:
 			if !next {
-				b.send(batch)
+				b.send(batch, members)
 				return
 			}
-			batch = append(batch, input.(In))
+			members = append(members, member{item: input, receipt: b.receive(input)})
+			batch = append(batch, unwrap(input).(In))
 			if len(batch) == b.options.MaxSize {
-				batch = b.send(batch)
+				batch, members = b.send(batch, members)
 			}
-		case <-time.After(b.options.Interval):
-			batch = b.send(batch)
+		case <-timer.C():
+			batch, members = b.send(batch, members)
+		}
+		if restart(timer, b.options.Interval) {
+			batch, members = b.send(batch, members)
 		}
 	}
 }
 
+// member is an item received by a stage that groups items together, along with its receipt.
+type member struct {
+	item    any
+	receipt receipt
+}
+
 // send emits the current batch downstream and initializes a new empty batch.
 // If the current batch is empty, it is returned as-is without sending.
-func (b batcher[In]) send(batch []In) []In {
+// Acknowledging the emitted batch acknowledges each of its members.
+func (b batcher[In]) send(batch []In, members []member) ([]In, []member) {
 	if len(batch) == 0 {
-		return batch
+		return batch, members
+	}
+	var (
+		items     = make([]any, len(members))
+		links     = make([]SpanContext, 0, len(members))
+		latencies = make([]time.Duration, len(members))
+	)
+	for i, m := range members {
+		items[i] = m.item
+		latencies[i] = m.receipt.elapsed()
+		if m.receipt.span != nil {
+			links = append(links, m.receipt.span.SpanContext())
+		}
+	}
+
+	// the batch is traced by a new span, linked to the span of each member
+	output := receipt{}
+	if tracer := b.tracer(); tracer != nil {
+		output.ctx, output.span = tracer.Start(context.Background(), b.Name(), WithLinks(links...))
+	}
+
+	b.push(b.out, output.carry(merge(items, batch)))
+	output.end(nil)
+	for i, m := range members {
+		b.emitted(m.item, m.receipt, latencies[i])
 	}
-	b.out <- batch
-	return b.newSlice()
+	return b.newSlice(), make([]member, 0, len(members))
 }
 
 // newSlice creates a new empty slice to hold the next batch of items.
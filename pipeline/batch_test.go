@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
 )
 
 func TestBatchN(t *testing.T) {
@@ -47,29 +48,45 @@ func TestBatchEvery(t *testing.T) {
 
 	t.Run("flushes during each duration", func(t *testing.T) {
 		var (
-			in     = make(chan int)
-			source = pipeline.
-				FromChannel(in).
-				Thru(pipeline.BatchEvery[int](time.Millisecond))
+			clock = pipetest.NewFakeClock(time.Time{})
+			h     = pipetest.NewHarness(t, pipeline.BatchEvery[int](time.Millisecond, func(bo *pipeline.BatcherOptions) {
+				bo.Clock = clock
+			}))
 		)
 
-		go func() {
-			defer close(in)
-			in <- 1
-			in <- 2
-			time.Sleep(2 * time.Millisecond)
-			in <- 3
-			in <- 4
-		}()
+		clock.BlockUntil(1)
+		h.Send(1, 2)
+		clock.Advance(time.Millisecond)
+		h.Expect([]int{1, 2})
 
+		h.Send(3, 4)
+		clock.BlockUntilDue(clock.Now().Add(time.Millisecond))
+		clock.Advance(time.Millisecond)
+		h.Expect([]int{3, 4})
+		h.Close()
+	})
+
+	t.Run("waits for the duration after each item", func(t *testing.T) {
 		var (
-			want = [][]int{{1, 2}, {3, 4}}
-			got  = Consume[[]int](source)
+			clock = pipetest.NewFakeClock(time.Time{})
+			h     = pipetest.NewHarness(t, pipeline.BatchEvery[int](time.Second, func(bo *pipeline.BatcherOptions) {
+				bo.Clock = clock
+			}))
 		)
 
-		if !reflect.DeepEqual(want, got) {
-			t.Errorf("wanted %#v, got %#v", want, got)
+		clock.BlockUntil(1)
+		h.Send(1)
+		clock.Advance(500 * time.Millisecond)
+		h.Send(2)
+		// the timer restarts with the second item, so the batch is not sent a second after the first
+		clock.BlockUntilDue(clock.Now().Add(time.Second))
+		clock.Advance(500 * time.Millisecond)
+		if got := clock.Waiters(); got != 1 {
+			t.Fatalf("got %d timers, want the batch timer still scheduled", got)
 		}
+		clock.Advance(time.Second)
+		h.Expect([]int{1, 2})
+		h.Close()
 	})
 
 	t.Run("flushes when the input channel closes", func(t *testing.T) {
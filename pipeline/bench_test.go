@@ -0,0 +1,138 @@
+package pipeline_test
+
+import (
+	"testing"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// benchItems is the number of items sent through each benchmarked pipeline.
+const benchItems = 1000
+
+// items returns the integers from 0 to n-1.
+func items(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+// runFlow sends the items through the flow built by chain, discarding its output.
+func runFlow(b *testing.B, chain func(pipeline.Flow) pipeline.Flow) {
+	b.Helper()
+	input := items(benchItems)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sink := pipeline.ToNull()
+		chain(pipeline.FromSlice(input...)).To(sink)
+		sink.Wait()
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*benchItems), "ns/item")
+}
+
+func BenchmarkFlow(b *testing.B) {
+	var (
+		double = func(i int) int { return i * 2 }
+		even   = func(i int) bool { return i%2 == 0 }
+	)
+
+	b.Run("source to sink", func(b *testing.B) {
+		runFlow(b, func(f pipeline.Flow) pipeline.Flow { return f })
+	})
+
+	b.Run("map", func(b *testing.B) {
+		runFlow(b, func(f pipeline.Flow) pipeline.Flow {
+			return f.Thru(pipeline.Map(double))
+		})
+	})
+
+	b.Run("map filter map", func(b *testing.B) {
+		runFlow(b, func(f pipeline.Flow) pipeline.Flow {
+			return f.Thru(pipeline.Map(double), pipeline.KeepIf(even), pipeline.Map(double))
+		})
+	})
+
+	b.Run("ten maps", func(b *testing.B) {
+		runFlow(b, func(f pipeline.Flow) pipeline.Flow {
+			for i := 0; i < 10; i++ {
+				f = f.Thru(pipeline.Map(double))
+			}
+			return f
+		})
+	})
+
+	b.Run("ten fused maps", func(b *testing.B) {
+		runFlow(b, func(f pipeline.Flow) pipeline.Flow {
+			pipes := make([]piper.Pipe, 10)
+			for i := range pipes {
+				pipes[i] = pipeline.Map(double)
+			}
+			return f.Thru(pipes...)
+		})
+	})
+
+	b.Run("ten fused maps of pointers", func(b *testing.B) {
+		// pointers are not boxed when sent as items, so any allocations per item are the
+		// pipeline's own; allocs/op should not grow with benchItems
+		input := make([]*int, benchItems)
+		for i := range input {
+			input[i] = &i
+		}
+		next := func(p *int) *int { return p }
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			pipes := make([]piper.Pipe, 10)
+			for i := range pipes {
+				pipes[i] = pipeline.Map(next)
+			}
+			sink := pipeline.ToNull()
+			pipeline.FromSlice(input...).Thru(pipes...).To(sink)
+			sink.Wait()
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*benchItems), "ns/item")
+	})
+
+	b.Run("ten maps with vectors", func(b *testing.B) {
+		runFlow(b, func(f pipeline.Flow) pipeline.Flow {
+			f = f.WithVectors(64)
+			for i := 0; i < 10; i++ {
+				f = f.Thru(pipeline.Map(double))
+			}
+			return f
+		})
+	})
+
+	b.Run("map batch flatten", func(b *testing.B) {
+		runFlow(b, func(f pipeline.Flow) pipeline.Flow {
+			return f.Thru(pipeline.Map(double), pipeline.BatchN[int](100), pipeline.Flatten[[]int]())
+		})
+	})
+
+	b.Run("tee", func(b *testing.B) {
+		input := items(benchItems)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var (
+				sink1, sink2 = pipeline.ToNull(), pipeline.ToNull()
+				left, right  = pipeline.FromSlice(input...).Tee(pipeline.Map(double), pipeline.KeepIf(even))
+			)
+			left.To(sink1)
+			right.To(sink2)
+			sink1.Wait()
+			sink2.Wait()
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*benchItems), "ns/item")
+	})
+
+	b.Run("map with collector", func(b *testing.B) {
+		collector := pipeline.NewCollector()
+		runFlow(b, func(f pipeline.Flow) pipeline.Flow {
+			return f.WithObserver(collector).Thru(pipeline.Map(double), pipeline.KeepIf(even))
+		})
+	})
+}
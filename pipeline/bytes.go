@@ -0,0 +1,285 @@
+package pipeline
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/internal/must"
+)
+
+// Compression is a compression format, used by [Compress] and [Decompress].
+type Compression int
+
+const (
+	// Gzip is the gzip format, as implemented by [compress/gzip].
+	Gzip Compression = iota + 1
+	// Deflate is the raw DEFLATE format, as implemented by [compress/flate].
+	Deflate
+	// Zlib is the zlib format, as implemented by [compress/zlib].
+	Zlib
+)
+
+// String returns the name of the format.
+func (c Compression) String() string {
+	switch c {
+	case Gzip:
+		return "gzip"
+	case Deflate:
+		return "deflate"
+	case Zlib:
+		return "zlib"
+	default:
+		return fmt.Sprintf("Compression(%d)", int(c))
+	}
+}
+
+// BytesOptions configure the stages converting byte items, such as [Compress] and [DecodeBase64].
+type BytesOptions struct {
+	// Level is the compression level used by [Compress], such as [gzip.BestSpeed].
+	// Defaults to [gzip.DefaultCompression].
+	Level int
+	// MaxSize is the maximum size of each item decompressed by [Decompress], in bytes; larger items
+	// fail with [ErrTooLarge]. Zero disables the limit.
+	MaxSize int64
+	// HandleError is called with the error of each item that cannot be converted.
+	HandleError func(error)
+}
+
+// ErrTooLarge is the error of items decompressed past [BytesOptions.MaxSize].
+var ErrTooLarge = errors.New("decompressed item too large")
+
+// newBytesOptions returns the default options, configured with the provided option functions.
+func newBytesOptions(opts ...func(*BytesOptions)) BytesOptions {
+	options := BytesOptions{
+		Level:       gzip.DefaultCompression,
+		HandleError: must.IgnoreError,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// newBytesConverter creates a new converter with the given name, converting the bytes of each item,
+// a []byte or string.
+func newBytesConverter(name string, options BytesOptions, convert func([]byte) ([]byte, error)) piper.Pipe {
+	return newConverter(name, options.HandleError, nil, func(item any) (any, error) {
+		data, err := bytesOf(item)
+		if err != nil {
+			return nil, err
+		}
+		return convert(data)
+	})
+}
+
+// bytesOf returns the bytes of a []byte or string item.
+func bytesOf(item any) ([]byte, error) {
+	switch v := item.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("unexpected %T, want []byte or string", item)
+	}
+}
+
+// Compress creates a new [piper.Pipe] that compresses each item, a []byte or string, in the format,
+// sending the compressed []byte. Each item is compressed as a complete stream, such as a gzip file.
+// Items that cannot be compressed are negatively acknowledged; see [BytesOptions] for details.
+// Compress panics if the format or the compression level is invalid.
+func Compress(format Compression, opts ...func(*BytesOptions)) piper.Pipe {
+	var (
+		options = newBytesOptions(opts...)
+		// writers are reset for each item, as compressors are costly to allocate
+		writers = sync.Pool{New: func() any {
+			return must.Return(newCompressor(format, io.Discard, options.Level))
+		}}
+	)
+	// create a writer upfront, panicking if the format or level is invalid
+	writers.Put(writers.New())
+
+	return newBytesConverter(format.String()+" compress", options, func(data []byte) ([]byte, error) {
+		var (
+			buf bytes.Buffer
+			w   = writers.Get().(compressor)
+		)
+		defer writers.Put(w)
+		w.Reset(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	})
+}
+
+// Decompress creates a new [piper.Pipe] that decompresses each item, a []byte or string compressed
+// in the format, sending the decompressed []byte. Items that cannot be decompressed are negatively
+// acknowledged; see [BytesOptions] for details. Decompress panics if the format is invalid.
+func Decompress(format Compression, opts ...func(*BytesOptions)) piper.Pipe {
+	if format < Gzip || format > Zlib {
+		panic(fmt.Sprintf("pipeline: invalid compression %v", format))
+	}
+	options := newBytesOptions(opts...)
+	return newBytesConverter(format.String()+" decompress", options, func(data []byte) ([]byte, error) {
+		r, err := newDecompressor(format, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		if options.MaxSize <= 0 {
+			return io.ReadAll(r)
+		}
+		data, err = io.ReadAll(io.LimitReader(r, options.MaxSize+1))
+		if err == nil && int64(len(data)) > options.MaxSize {
+			err = ErrTooLarge
+		}
+		return data, err
+	})
+}
+
+// compressor is implemented by the writers of each compression format.
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// newCompressor returns a writer compressing to w in the format, at the level.
+func newCompressor(format Compression, w io.Writer, level int) (compressor, error) {
+	switch format {
+	case Gzip:
+		return gzip.NewWriterLevel(w, level)
+	case Deflate:
+		return flate.NewWriter(w, level)
+	case Zlib:
+		return zlib.NewWriterLevel(w, level)
+	default:
+		return nil, fmt.Errorf("pipeline: invalid compression %v", format)
+	}
+}
+
+// newDecompressor returns a reader decompressing r in the format.
+func newDecompressor(format Compression, r io.Reader) (io.ReadCloser, error) {
+	switch format {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Deflate:
+		return flate.NewReader(r), nil
+	default:
+		return zlib.NewReader(r)
+	}
+}
+
+// EncodeBase64 creates a new [piper.Pipe] that encodes each item, a []byte or string, with the
+// base64 encoding, sending the encoded []byte. A nil encoding defaults to [base64.StdEncoding].
+// Items of other types are negatively acknowledged; see [BytesOptions] for details.
+func EncodeBase64(enc *base64.Encoding, opts ...func(*BytesOptions)) piper.Pipe {
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	return newBytesConverter("base64 encode", newBytesOptions(opts...), func(data []byte) ([]byte, error) {
+		return enc.AppendEncode(nil, data), nil
+	})
+}
+
+// DecodeBase64 creates a new [piper.Pipe] that decodes each item, a []byte or string, with the
+// base64 encoding, sending the decoded []byte. A nil encoding defaults to [base64.StdEncoding].
+// Items that cannot be decoded are negatively acknowledged; see [BytesOptions] for details.
+func DecodeBase64(enc *base64.Encoding, opts ...func(*BytesOptions)) piper.Pipe {
+	if enc == nil {
+		enc = base64.StdEncoding
+	}
+	return newBytesConverter("base64 decode", newBytesOptions(opts...), func(data []byte) ([]byte, error) {
+		return enc.AppendDecode(nil, data)
+	})
+}
+
+// EncodeHex creates a new [piper.Pipe] that encodes each item, a []byte or string, in hexadecimal,
+// sending the encoded []byte. Items of other types are negatively acknowledged; see [BytesOptions]
+// for details.
+func EncodeHex(opts ...func(*BytesOptions)) piper.Pipe {
+	return newBytesConverter("hex encode", newBytesOptions(opts...), func(data []byte) ([]byte, error) {
+		return hex.AppendEncode(nil, data), nil
+	})
+}
+
+// DecodeHex creates a new [piper.Pipe] that decodes each item, a []byte or string in hexadecimal,
+// sending the decoded []byte. Items that cannot be decoded are negatively acknowledged; see
+// [BytesOptions] for details.
+func DecodeHex(opts ...func(*BytesOptions)) piper.Pipe {
+	return newBytesConverter("hex decode", newBytesOptions(opts...), func(data []byte) ([]byte, error) {
+		return hex.AppendDecode(nil, data)
+	})
+}
+
+// byteChunker implements a pipeline component that splits byte items into chunks.
+type byteChunker struct {
+	*stage
+	// size is the maximum size of each chunk.
+	size int
+	// options configure error handling.
+	options BytesOptions
+}
+
+// ChunkBytes creates a new [piper.Pipe] that splits each item, a []byte or string, into []byte
+// chunks of size bytes, the last chunk holding the remaining bytes, and sends them individually.
+// Empty items are dropped, and the chunks of []byte items share their memory. The input is
+// acknowledged once all of its chunks are acknowledged, and items of other types are negatively
+// acknowledged; see [BytesOptions] for details. To read a large stream in chunks rather than
+// splitting items, use [FromReader] with [ScanChunks].
+// ChunkBytes panics if the size is less than 1.
+func ChunkBytes(size int, opts ...func(*BytesOptions)) piper.Pipe {
+	if size < 1 {
+		panic("chunk size must be greater than 0")
+	}
+	pipe := byteChunker{
+		stage:   newStage("chunk bytes"),
+		size:    size,
+		options: newBytesOptions(opts...),
+	}
+	fuse(pipe)
+	return pipe
+}
+
+func (c byteChunker) In() chan<- any  { return c.launch().in }
+func (c byteChunker) Out() <-chan any { return c.launch().out }
+
+// process implements processor, passing each chunk of the input item downstream.
+func (c byteChunker) process(input any, next func(any)) {
+	rec := c.receive(input)
+	data, err := bytesOf(unwrap(input))
+	if err != nil {
+		c.options.HandleError(err)
+		c.fail(input, rec, err)
+		return
+	}
+	if len(data) == 0 {
+		c.drop(input, rec)
+		return
+	}
+
+	chunks := make([][]byte, 0, (len(data)+c.size-1)/c.size)
+	for len(data) > c.size {
+		chunks = append(chunks, data[:c.size:c.size])
+		data = data[c.size:]
+	}
+	chunks = append(chunks, data)
+	latency := rec.elapsed()
+	// the input is acknowledged once all of its chunks are acknowledged
+	for _, chunk := range split(input, chunks) {
+		next(rec.carry(chunk))
+	}
+	c.emitted(input, rec, latency)
+}
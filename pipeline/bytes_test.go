@@ -0,0 +1,202 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+)
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	input := []any{"hello", []byte(strings.Repeat("piper ", 100)), ""}
+	for _, format := range []pipeline.Compression{pipeline.Gzip, pipeline.Deflate, pipeline.Zlib} {
+		t.Run(format.String(), func(t *testing.T) {
+			flow := pipeline.FromSlice(input...).
+				Thru(pipeline.Compress(format, func(o *pipeline.BytesOptions) { o.Level = gzip.BestSpeed })).
+				Thru(pipeline.Decompress(format))
+
+			got := Consume[[]byte](flow)
+			want := [][]byte{[]byte("hello"), []byte(strings.Repeat("piper ", 100)), {}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+
+	t.Run("writes gzip files", func(t *testing.T) {
+		got := Consume[[]byte](pipeline.FromSlice("hello").Thru(pipeline.Compress(pipeline.Gzip)))
+		r, err := gzip.NewReader(bytes.NewReader(got[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(r); err != nil || buf.String() != "hello" {
+			t.Errorf("got %q, %v, want %q", buf.String(), err, "hello")
+		}
+	})
+
+	t.Run("panics on invalid levels", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		pipeline.Compress(pipeline.Gzip, func(o *pipeline.BytesOptions) { o.Level = 42 })
+	})
+}
+
+func TestDecompress(t *testing.T) {
+	t.Parallel()
+
+	compressed := Consume[[]byte](pipeline.FromSlice("hello world").Thru(pipeline.Compress(pipeline.Zlib)))
+
+	tests := []struct {
+		name   string
+		input  any
+		opts   func(*pipeline.BytesOptions)
+		failed bool
+		err    error
+	}{
+		{name: "decompresses items", input: compressed[0], opts: func(*pipeline.BytesOptions) {}},
+		{name: "rejects corrupt items", input: "not zlib", opts: func(*pipeline.BytesOptions) {}, failed: true},
+		{name: "rejects other types", input: 42, opts: func(*pipeline.BytesOptions) {}, failed: true},
+		{
+			name:   "limits the decompressed size",
+			input:  compressed[0],
+			opts:   func(o *pipeline.BytesOptions) { o.MaxSize = 5 },
+			failed: true,
+			err:    pipeline.ErrTooLarge,
+		},
+		{name: "allows items of the maximum size", input: compressed[0], opts: func(o *pipeline.BytesOptions) { o.MaxSize = 11 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				deliveries = &Deliveries{}
+				errs       []error
+				pipe       = pipeline.Decompress(pipeline.Zlib, tt.opts, func(o *pipeline.BytesOptions) {
+					o.HandleError = func(err error) { errs = append(errs, err) }
+				})
+				got = Consume[[]byte](pipeline.FromSlice(deliveries.Message(1, tt.input)).Thru(pipe))
+			)
+
+			if tt.failed {
+				if len(got) != 0 || len(errs) != 1 || !reflect.DeepEqual(deliveries.Nacked(), []int{1}) {
+					t.Fatalf("got %q and errors %v, want the item to fail", got, errs)
+				}
+				if tt.err != nil && !errors.Is(errs[0], tt.err) {
+					t.Errorf("got error %v, want %v", errs[0], tt.err)
+				}
+				return
+			}
+			if want := [][]byte{[]byte("hello world")}; !reflect.DeepEqual(got, want) || len(errs) != 0 {
+				t.Errorf("got %q and errors %v, want %q", got, errs, want)
+			}
+		})
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		encode  piper.Pipe
+		decode  func(...func(*pipeline.BytesOptions)) piper.Pipe
+		encoded string
+		invalid string
+	}{
+		{
+			name:    "base64",
+			encode:  pipeline.EncodeBase64(nil),
+			decode:  func(opts ...func(*pipeline.BytesOptions)) piper.Pipe { return pipeline.DecodeBase64(nil, opts...) },
+			encoded: "aGk/Pz8=",
+			invalid: "%%%",
+		},
+		{
+			name:   "base64 url",
+			encode: pipeline.EncodeBase64(base64.RawURLEncoding),
+			decode: func(opts ...func(*pipeline.BytesOptions)) piper.Pipe {
+				return pipeline.DecodeBase64(base64.RawURLEncoding, opts...)
+			},
+			encoded: "aGk_Pz8",
+			invalid: "aGk/Pz8=",
+		},
+		{
+			name:    "hex",
+			encode:  pipeline.EncodeHex(),
+			decode:  pipeline.DecodeHex,
+			encoded: "68693f3f3f",
+			invalid: "6g",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := Consume[[]byte](pipeline.FromSlice("hi???").Thru(tt.encode))
+			if got := string(encoded[0]); got != tt.encoded {
+				t.Errorf("got %q, want %q", got, tt.encoded)
+			}
+
+			var errs []error
+			decoded := Consume[[]byte](pipeline.FromSlice[any](encoded[0], tt.invalid).
+				Thru(tt.decode(func(o *pipeline.BytesOptions) { o.HandleError = func(err error) { errs = append(errs, err) } })))
+			if want := [][]byte{[]byte("hi???")}; !reflect.DeepEqual(decoded, want) {
+				t.Errorf("got %q, want %q", decoded, want)
+			}
+			if len(errs) != 1 {
+				t.Errorf("got errors %v, want one for the invalid item", errs)
+			}
+		})
+	}
+}
+
+func TestChunkBytes(t *testing.T) {
+	t.Parallel()
+
+	t.Run("splits items", func(t *testing.T) {
+		got := Consume[[]byte](pipeline.FromSlice[any]("abcdefg", []byte("hi"), "").Thru(pipeline.ChunkBytes(3)))
+		want := [][]byte{[]byte("abc"), []byte("def"), []byte("g"), []byte("hi")}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("acknowledges items once their chunks are", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			flow       = pipeline.FromSlice(deliveries.Message(1, "abcd"), deliveries.Message(2, "")).
+					Thru(pipeline.ChunkBytes(2))
+			chunks []pipeline.Message
+		)
+		for item := range flow.Out() {
+			chunks = append(chunks, item.(pipeline.Message))
+		}
+		if len(chunks) != 2 {
+			t.Fatalf("got %d chunks, want 2", len(chunks))
+		}
+		Eventually(t, func() bool { return reflect.DeepEqual(deliveries.Acked(), []int{2}) })
+
+		chunks[0].Ack()
+		chunks[1].Ack()
+		if got, want := deliveries.Acked(), []int{2, 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got acked %v, want %v", got, want)
+		}
+	})
+
+	t.Run("streams readers in chunks", func(t *testing.T) {
+		got := Consume[string](pipeline.FromReader(strings.NewReader("abcdefg"), pipeline.ScanChunks(3)))
+		if want := []string{"abc", "def", "g"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
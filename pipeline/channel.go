@@ -5,6 +5,7 @@ import "github.com/nisimpson/piper"
 // channelSource adapts a typed input channel to serve as a pipeline source.
 // It converts the typed channel into the pipeline's generic any-typed channel system.
 type channelSource[T any] struct {
+	*stage
 	// in is the external typed channel from which data is read
 	in <-chan T
 	// out is the internal pipeline channel to which data is forwarded
@@ -15,8 +16,9 @@ type channelSource[T any] struct {
 // It allows existing channel-based code to be used as the input for a pipeline.
 func FromChannel[T any](ch <-chan T) Flow {
 	source := channelSource[T]{
-		in:  ch,
-		out: make(chan any),
+		stage: newStage("channel"),
+		in:    ch,
+		out:   make(chan any),
 	}
 	go source.start()
 	return From(source)
@@ -28,14 +30,16 @@ func (c channelSource[T]) Out() <-chan any { return c.out }
 // It handles type conversion from T to any and ensures proper cleanup.
 func (c channelSource[T]) start() {
 	defer close(c.out)
+	defer c.finish()
 	for input := range c.in {
-		c.out <- input
+		c.emit(c.out, input, input, c.receive(input))
 	}
 }
 
 // channelSink adapts a typed output channel to serve as a pipeline sink.
 // It converts from the pipeline's generic any-typed system back to a typed channel.
 type channelSink[T any] struct {
+	*stage
 	// in is the internal pipeline channel from which data is read
 	in chan any
 	// out is the external typed channel to which data is forwarded
@@ -46,8 +50,9 @@ type channelSink[T any] struct {
 // It allows pipeline output to be connected to existing channel-based code.
 func ToChannel[T any](ch chan<- T) piper.Sink {
 	sink := channelSink[T]{
-		in:  make(chan any),
-		out: ch,
+		stage: newStage("channel"),
+		in:    make(chan any),
+		out:   ch,
 	}
 	go sink.start()
 	return sink
@@ -59,7 +64,12 @@ func (c channelSink[T]) In() chan<- any { return c.in }
 // It handles type assertion from any to T and ensures proper cleanup.
 func (c channelSink[T]) start() {
 	defer close(c.out)
+	defer c.finish()
 	for input := range c.in {
-		c.out <- input.(T)
+		r := c.receive(input)
+		latency := r.elapsed()
+		c.out <- unwrap(input).(T)
+		c.emitted(input, r, latency)
+		ack(input)
 	}
 }
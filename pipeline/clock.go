@@ -0,0 +1,69 @@
+package pipeline
+
+import "time"
+
+// Clock tells the time and schedules the timers of time-based stages, such as [Batch] and
+// [SlidingWindow]. Stages read the wall clock through [SystemClock] by default; substitute
+// a fake clock, such as pipetest.FakeClock, to test them without waiting on the wall clock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel receiving the current time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+	// NewTimer creates a new [Timer] firing once d has elapsed.
+	NewTimer(d time.Duration) Timer
+	// NewTicker creates a new [Ticker] firing every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a single event scheduled by a [Clock], such as a [time.Timer].
+type Timer interface {
+	// C returns the channel receiving the time when the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, reporting whether it was active.
+	Stop() bool
+	// Reset changes the timer to fire once d has elapsed, reporting whether it was active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a recurring event scheduled by a [Clock], such as a [time.Ticker].
+type Ticker interface {
+	// C returns the channel receiving the time of each tick.
+	C() <-chan time.Time
+	// Stop turns off the ticker.
+	Stop()
+	// Reset stops the ticker and resets its period to d.
+	Reset(d time.Duration)
+}
+
+// SystemClock returns the [Clock] reading the wall clock, backed by the time package.
+func SystemClock() Clock { return systemClock{} }
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (systemClock) NewTimer(d time.Duration) Timer         { return systemTimer{time.NewTimer(d)} }
+func (systemClock) NewTicker(d time.Duration) Ticker       { return systemTicker{time.NewTicker(d)} }
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// restart resets the timer to fire once d has elapsed, reporting whether it fired since it was last
+// received from, such as while a stage handled an item.
+func restart(t Timer, d time.Duration) (fired bool) {
+	if !t.Stop() {
+		select {
+		case <-t.C():
+			fired = true
+		default:
+		}
+	}
+	t.Reset(d)
+	return fired
+}
@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/xml"
+	"errors"
+	"io"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/internal/must"
+)
+
+// Codec encodes values of type T as bytes, and decodes them back. Codecs are used by the [Encode]
+// and [Decode] stages, the [FromEncoded] source and the request bodies of [SendHTTP]; the Encode
+// method of a codec is also an [EncodeFunction], accepted by sinks such as [ToWriter] and
+// [ToRotatingFile]. Codecs must be safe for concurrent use.
+type Codec[T any] interface {
+	// Encode encodes the value.
+	Encode(value T) ([]byte, error)
+	// Decode decodes a value from the data.
+	Decode(data []byte) (T, error)
+}
+
+// NewCodec creates a new [Codec] calling the functions, such as to adapt custom marshalers.
+func NewCodec[T any](encode EncodeFunction[T], decode func([]byte) (T, error)) Codec[T] {
+	return funcCodec[T]{encode: encode, decode: decode}
+}
+
+// funcCodec is a [Codec] calling its functions.
+type funcCodec[T any] struct {
+	encode EncodeFunction[T]
+	decode func([]byte) (T, error)
+}
+
+func (c funcCodec[T]) Encode(value T) ([]byte, error) { return c.encode(value) }
+func (c funcCodec[T]) Decode(data []byte) (T, error)  { return c.decode(data) }
+
+// JSONCodec returns a [Codec] encoding values as JSON, like [EncodeJSONLines]: each value is encoded
+// on a single line, without a trailing newline, with [json.Marshal].
+func JSONCodec[T any]() Codec[T] {
+	return NewCodec(encodeJSONLine[T], func(data []byte) (T, error) {
+		return decodeJSONLine[T](data, false)
+	})
+}
+
+// XMLCodec returns a [Codec] encoding values as XML elements, with [xml.Marshal] and [xml.Unmarshal].
+func XMLCodec[T any]() Codec[T] {
+	return NewCodec(
+		func(value T) ([]byte, error) { return xml.Marshal(value) },
+		func(data []byte) (T, error) {
+			var value T
+			err := xml.Unmarshal(data, &value)
+			return value, err
+		},
+	)
+}
+
+// GobCodec returns a [Codec] encoding values with [encoding/gob]. Each value is encoded as a
+// complete gob stream, including the description of its type, so it can be decoded on its own.
+func GobCodec[T any]() Codec[T] {
+	return NewCodec(
+		func(value T) ([]byte, error) {
+			var buf bytes.Buffer
+			err := gob.NewEncoder(&buf).Encode(value)
+			return buf.Bytes(), err
+		},
+		func(data []byte) (T, error) {
+			var value T
+			err := gob.NewDecoder(bytes.NewReader(data)).Decode(&value)
+			return value, err
+		},
+	)
+}
+
+// CodecOptions configure the stages created by [Encode] and [Decode].
+type CodecOptions struct {
+	// HandleError is called with the error of each item that cannot be encoded or decoded.
+	HandleError func(error)
+}
+
+// newCodecOptions returns the default options, configured with the provided option functions.
+func newCodecOptions(opts ...func(*CodecOptions)) CodecOptions {
+	options := CodecOptions{HandleError: must.IgnoreError}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// errSkip is returned by the conversion of an item holding no value, such as a blank line,
+// which is dropped.
+var errSkip = errors.New("skip")
+
+// converter implements a pipeline component that converts each item, such as encoding or decoding
+// it. It runs fused with adjacent processors.
+type converter struct {
+	*stage
+	// convert converts an item, returning errSkip if it holds no value.
+	convert func(item any) (any, error)
+	// handleError is called with the error of each item that cannot be converted.
+	handleError func(error)
+	// errors receives the error of each item that cannot be converted, carried in the envelope of
+	// the item, if set. Otherwise, such items are negatively acknowledged.
+	errors piper.Inlet
+}
+
+// newConverter creates a new converter with the given name.
+func newConverter(name string, handleError func(error), errors piper.Inlet, convert func(any) (any, error)) piper.Pipe {
+	pipe := converter{
+		stage:       newStage(name),
+		convert:     convert,
+		handleError: handleError,
+		errors:      errors,
+	}
+	fuse(pipe)
+	return pipe
+}
+
+func (c converter) In() chan<- any  { return c.launch().in }
+func (c converter) Out() <-chan any { return c.launch().out }
+
+// process implements processor, converting the input item and passing it downstream.
+func (c converter) process(input any, next func(any)) {
+	rec := c.receive(input)
+	output, err := c.convert(unwrap(input))
+	switch {
+	case errors.Is(err, errSkip):
+		c.drop(input, rec)
+	case err != nil:
+		c.route(input, rec, err)
+	default:
+		c.forward(next, input, rewrap(input, output), rec)
+	}
+}
+
+// close implements closer, closing the error inlet, if any, once the converter is done.
+func (c converter) close() {
+	if c.errors != nil {
+		close(c.errors.In())
+	}
+}
+
+// route reports the error of the input, sending it to the error inlet if any, or negatively
+// acknowledging the input otherwise.
+func (c converter) route(input any, rec receipt, err error) {
+	c.handleError(err)
+	if c.errors == nil {
+		c.fail(input, rec, err)
+		return
+	}
+	c.reject(rec, err)
+	c.errors.In() <- prepare(c.errors, rewrap(input, err))
+}
+
+// Encode creates a new [piper.Pipe] that encodes each item of type T with the codec, sending the
+// encoded []byte. Items that cannot be encoded are negatively acknowledged.
+func Encode[T any](codec Codec[T], opts ...func(*CodecOptions)) piper.Pipe {
+	return newConverter("encode", newCodecOptions(opts...).HandleError, nil, func(item any) (any, error) {
+		return codec.Encode(item.(T))
+	})
+}
+
+// Decode creates a new [piper.Pipe] that decodes each item, a []byte or string, into a value of
+// type T with the codec. Items that cannot be decoded are negatively acknowledged.
+func Decode[T any](codec Codec[T], opts ...func(*CodecOptions)) piper.Pipe {
+	return newConverter("decode", newCodecOptions(opts...).HandleError, nil, func(item any) (any, error) {
+		data, err := bytesOf(item)
+		if err != nil {
+			return nil, err
+		}
+		return codec.Decode(data)
+	})
+}
+
+// FromEncoded creates a new [Flow] that decodes each token read from r into a value of type T with
+// the codec. It reads tokens like [FromReader], splitting lines if split is nil, and decodes them like
+// [Decode]; tokens that cannot be decoded are passed to [ReaderOptions.HandleError] and skipped.
+func FromEncoded[T any](r io.Reader, split bufio.SplitFunc, codec Codec[T], opts ...func(*ReaderOptions)) Flow {
+	options := ReaderOptions{HandleError: must.IgnoreError}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return FromReader(r, split, opts...).Thru(Decode(codec, func(o *CodecOptions) {
+		o.HandleError = options.HandleError
+	}))
+}
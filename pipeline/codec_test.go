@@ -0,0 +1,187 @@
+package pipeline_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// Order is a value exchanged with codecs.
+type Order struct {
+	ID    int      `json:"id" xml:"id,attr"`
+	Items []string `json:"items" xml:"item"`
+}
+
+func TestCodecs(t *testing.T) {
+	t.Parallel()
+
+	var (
+		orders = []any{Order{ID: 1, Items: []string{"a", "b"}}, Order{ID: 2, Items: []string{"<c>"}}}
+		csv    = pipeline.NewCodec(
+			func(o Order) ([]byte, error) {
+				return []byte(strconv.Itoa(o.ID) + "," + strings.Join(o.Items, ",")), nil
+			},
+			func(data []byte) (Order, error) {
+				fields := strings.Split(string(data), ",")
+				id, err := strconv.Atoi(fields[0])
+				return Order{ID: id, Items: fields[1:]}, err
+			},
+		)
+	)
+
+	tests := []struct {
+		name  string
+		codec pipeline.Codec[Order]
+		want  []string
+	}{
+		{
+			name:  "json",
+			codec: pipeline.JSONCodec[Order](),
+			want:  []string{`{"id":1,"items":["a","b"]}`, `{"id":2,"items":["\u003cc\u003e"]}`},
+		},
+		{
+			name:  "xml",
+			codec: pipeline.XMLCodec[Order](),
+			want:  []string{`<Order id="1"><item>a</item><item>b</item></Order>`, `<Order id="2"><item>&lt;c&gt;</item></Order>`},
+		},
+		{name: "gob", codec: pipeline.GobCodec[Order]()},
+		{name: "custom", codec: csv, want: []string{"1,a,b", "2,<c>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := Consume[[]byte](pipeline.FromSlice(orders...).Thru(pipeline.Encode(tt.codec)))
+			if tt.want != nil {
+				got := make([]string, len(encoded))
+				for i, data := range encoded {
+					got[i] = string(data)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("got %q, want %q", got, tt.want)
+				}
+			}
+
+			decoded := Consume[Order](pipeline.FromSlice(encoded...).Thru(pipeline.Decode(tt.codec)))
+			if want := []Order{orders[0].(Order), orders[1].(Order)}; !reflect.DeepEqual(decoded, want) {
+				t.Errorf("got %v, want %v", decoded, want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	t.Parallel()
+
+	var (
+		deliveries = &Deliveries{}
+		errs       []error
+		flow       = pipeline.FromSlice(deliveries.Message(1, `{"id":1}`), deliveries.Message(2, "{"), deliveries.Message(3, 42)).
+				Thru(pipeline.Decode(pipeline.JSONCodec[Order](), func(o *pipeline.CodecOptions) {
+				o.HandleError = func(err error) { errs = append(errs, err) }
+			}))
+	)
+
+	if got, want := Consume[Order](flow), []Order{{ID: 1}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := deliveries.Nacked(), []int{2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got nacked %v, want %v", got, want)
+	}
+	if len(errs) != 2 {
+		t.Errorf("got errors %v, want 2", errs)
+	}
+}
+
+func TestFromEncoded(t *testing.T) {
+	t.Parallel()
+
+	var (
+		buf  bytes.Buffer
+		sink = pipeline.ToWriter(&buf, pipeline.XMLCodec[Order]().Encode)
+	)
+	pipeline.FromSlice(Order{ID: 1}, Order{ID: 2}).To(sink)
+	if err := sink.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("<Order id=\"x\"/>\n")
+
+	var errs []error
+	flow := pipeline.FromEncoded(&buf, bufio.ScanLines, pipeline.XMLCodec[Order](), func(o *pipeline.ReaderOptions) {
+		o.HandleError = func(err error) { errs = append(errs, err) }
+	})
+	if got, want := Consume[Order](flow), []Order{{ID: 1}, {ID: 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(errs) != 1 {
+		t.Errorf("got errors %v, want one for the invalid line", errs)
+	}
+}
+
+func TestSendHTTPCodec(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+
+	body := func(r *http.Response) (any, error) {
+		data, err := io.ReadAll(r.Body)
+		return string(data), err
+	}
+
+	t.Run("encodes bodies with the codec", func(t *testing.T) {
+		flow := pipeline.FromSlice[any](Order{ID: 1}, []byte("raw")).
+			Thru(pipeline.SendHTTP(http.MethodPost, server.URL, func(o *pipeline.HttpPipeOptions) {
+				o.Codec = pipeline.XMLCodec[any]()
+				o.HandleResponse = body
+			}))
+
+		if got, want := Consume[string](flow), []string{`<Order id="1"></Order>`, "raw"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("prefers the marshal function", func(t *testing.T) {
+		flow := pipeline.FromSlice(Order{ID: 1}).
+			Thru(pipeline.SendHTTP(http.MethodPost, server.URL, func(o *pipeline.HttpPipeOptions) {
+				o.MarshalFunc = func(any) ([]byte, error) { return []byte("marshaled"), nil }
+				o.HandleResponse = body
+			}))
+
+		if got, want := Consume[string](flow), []string{"marshaled"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("reports encoding errors", func(t *testing.T) {
+		var (
+			errEncode = errors.New("cannot encode")
+			errs      []error
+			flow      = pipeline.FromSlice(Order{ID: 1}).
+					Thru(pipeline.SendHTTP(http.MethodPost, server.URL, func(o *pipeline.HttpPipeOptions) {
+					o.Codec = pipeline.NewCodec(
+						func(any) ([]byte, error) { return nil, errEncode },
+						func([]byte) (any, error) { return nil, nil },
+					)
+					o.HandleError = func(err error) { errs = append(errs, err) }
+				}))
+		)
+
+		if got := Consume[any](flow); len(got) != 0 {
+			t.Errorf("got %v, want no output", got)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], errEncode) {
+			t.Errorf("got errors %v, want %v", errs, errEncode)
+		}
+	})
+}
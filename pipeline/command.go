@@ -37,6 +37,7 @@ func (f CommandFunction[In, Out]) Execute(input In) (out Out, exitcode int, err
 // executor implements a pipeline component that executes commands.
 // It can be configured to handle errors and process command output in custom ways.
 type executor[In any, Out any] struct {
+	*stage
 	// cmd is the command to be executed
 	cmd Command[In, Out]
 	// in receives inputs to be passed to the command
@@ -52,6 +53,7 @@ type executor[In any, Out any] struct {
 // that don't require input (like 'ls' or 'date').
 func FromCmd[In any, Out any](cmd Command[In, Out], opts ...func(*CommandPipeOptions[Out])) Flow {
 	source := executor[In, Out]{
+		stage:   newStage("command"),
 		cmd:     cmd,
 		in:      make(chan any, 1),
 		out:     make(chan any),
@@ -69,6 +71,7 @@ func FromCmd[In any, Out any](cmd Command[In, Out], opts ...func(*CommandPipeOpt
 // This is suitable for commands that process input (like 'grep' or 'sed').
 func ExecCmd[In any, Out any](cmd Command[In, Out], opts ...func(*CommandPipeOptions[Out])) piper.Pipe {
 	source := executor[In, Out]{
+		stage:   newStage("command"),
 		cmd:     cmd,
 		in:      make(chan any),
 		out:     make(chan any),
@@ -91,6 +94,7 @@ func (c executor[In, Out]) Out() <-chan any {
 // It processes each input by executing the command and handling its output according to the configured options.
 func (c executor[In, Out]) start() {
 	defer close(c.out)
+	defer c.finish()
 
 	opts := CommandPipeOptions[Out]{
 		HandleError:  must.IgnoreError,
@@ -102,17 +106,20 @@ func (c executor[In, Out]) start() {
 	}
 
 	for input := range c.in {
+		rec := c.receive(input)
+
 		// execute command
-		output, exitcode, err := c.cmd.Execute(input.(In))
+		output, exitcode, err := c.cmd.Execute(unwrap(input).(In))
 
 		// handle error
 		if err != nil {
 			opts.HandleError(err)
+			c.fail(input, rec, err)
 			continue
 		}
 
 		// handle output
-		c.out <- opts.HandleOutput(output, exitcode)
+		c.emit(c.out, input, rewrap(input, opts.HandleOutput(output, exitcode)), rec)
 	}
 }
 
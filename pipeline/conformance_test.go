@@ -0,0 +1,153 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+)
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	// the subtests run in parallel once this function returns
+	t.Cleanup(server.Close)
+
+	var (
+		double = func(i int) int { return i * 2 }
+		even   = func(i int) bool { return i%2 == 0 }
+		pairs  = func(o *pipetest.ConformanceOptions) {
+			o.Input = func(i int) any { return []int{i, i + 1} }
+		}
+		strs = func(o *pipetest.ConformanceOptions) {
+			o.Input = func(i int) any { return strconv.Itoa(i) }
+		}
+		encoded = func(encode func([]byte) []byte) func(*pipetest.ConformanceOptions) {
+			return func(o *pipetest.ConformanceOptions) {
+				o.Input = func(i int) any { return encode([]byte(strconv.Itoa(i))) }
+			}
+		}
+		gzipped = encoded(func(data []byte) []byte {
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write(data)
+			zw.Close()
+			return buf.Bytes()
+		})
+	)
+
+	// the number of items sent by pipes for n items received
+	var (
+		each   = func(n int) int { return n }
+		twice  = func(n int) int { return 2 * n }
+		evens  = func(n int) int { return (n + 1) / 2 }
+		odds   = func(n int) int { return n / 2 }
+		once   = func(n int) int { return min(n, 1) }
+		first  = func(n int) int { return min(n, 3) }
+		after  = func(n int) int { return max(n-3, 0) }
+		groups = func(size int) func(int) int {
+			return func(n int) int { return (n + size - 1) / size }
+		}
+		digits = func(n int) int {
+			count := 0
+			for i := range n {
+				count += len(strconv.Itoa(i))
+			}
+			return count
+		}
+	)
+
+	tests := []struct {
+		name   string
+		pipe   func() piper.Pipe
+		output func(n int) int
+		opts   []func(*pipetest.ConformanceOptions)
+	}{
+		{name: "Map", pipe: func() piper.Pipe { return pipeline.Map(double) }, output: each},
+		{name: "FlatMap", pipe: func() piper.Pipe { return pipeline.FlatMap(func(i int) []int { return []int{i, i} }) }, output: twice},
+		{name: "Flatten", pipe: func() piper.Pipe { return pipeline.Flatten[[]int]() }, output: twice, opts: []func(*pipetest.ConformanceOptions){pairs}},
+		{name: "KeepIf", pipe: func() piper.Pipe { return pipeline.KeepIf(even) }, output: evens},
+		{name: "DropIf", pipe: func() piper.Pipe { return pipeline.DropIf(even) }, output: odds},
+		{name: "Reduce", pipe: func() piper.Pipe { return pipeline.Reduce(func(acc, i int) int { return acc + i }) }, output: each},
+		{name: "BatchN", pipe: func() piper.Pipe { return pipeline.BatchN[int](3) }, output: groups(3)},
+		{name: "BatchEvery", pipe: func() piper.Pipe { return pipeline.BatchEvery[int](time.Millisecond) }},
+		{name: "BatchAll", pipe: func() piper.Pipe { return pipeline.BatchAll[int]() }, output: once},
+		{name: "Chunk", pipe: func() piper.Pipe { return pipeline.Chunk[[]int](1) }, output: twice, opts: []func(*pipetest.ConformanceOptions){pairs}},
+		{name: "TakeN", pipe: func() piper.Pipe { return pipeline.TakeN(3) }, output: first},
+		{name: "TakeLastN", pipe: func() piper.Pipe { return pipeline.TakeLastN(3) }, output: first},
+		{name: "DropN", pipe: func() piper.Pipe { return pipeline.DropN(3) }, output: after},
+		{name: "Unique", pipe: func() piper.Pipe { return pipeline.Unique[int]() }, output: each},
+		{
+			name: "SlidingWindow",
+			pipe: func() piper.Pipe {
+				return pipeline.SlidingWindow[int](func(o *pipeline.SlidingWindowOptions) {
+					o.WindowSize = 3
+					o.Interval = time.Millisecond
+				})
+			},
+		},
+		{name: "Passthrough", pipe: pipeline.Passthrough, output: each},
+		{name: "Parallelize", pipe: func() piper.Pipe {
+			return pipeline.Parallelize(4, func() piper.Pipe { return pipeline.Map(double) })
+		}, output: each},
+		{name: "Join", pipe: func() piper.Pipe { return pipeline.Join(pipeline.Map(double), pipeline.KeepIf(even)) }, output: each},
+		{name: "Named", pipe: func() piper.Pipe { return pipeline.Named("double", pipeline.Map(double)) }, output: each},
+		{name: "DecodeJSONLines", pipe: func() piper.Pipe { return pipeline.DecodeJSONLines[int]() }, output: each, opts: []func(*pipetest.ConformanceOptions){strs}},
+		{name: "EncodeJSONLines", pipe: func() piper.Pipe { return pipeline.EncodeJSONLines[int]() }, output: each},
+		{name: "Encode", pipe: func() piper.Pipe { return pipeline.Encode(pipeline.GobCodec[int]()) }, output: each},
+		{name: "Decode", pipe: func() piper.Pipe { return pipeline.Decode(pipeline.JSONCodec[int]()) }, output: each, opts: []func(*pipetest.ConformanceOptions){strs}},
+		{name: "Compress", pipe: func() piper.Pipe { return pipeline.Compress(pipeline.Gzip) }, output: each, opts: []func(*pipetest.ConformanceOptions){strs}},
+		{name: "Decompress", pipe: func() piper.Pipe { return pipeline.Decompress(pipeline.Gzip) }, output: each, opts: []func(*pipetest.ConformanceOptions){gzipped}},
+		{name: "EncodeBase64", pipe: func() piper.Pipe { return pipeline.EncodeBase64(nil) }, output: each, opts: []func(*pipetest.ConformanceOptions){strs}},
+		{
+			name:   "DecodeBase64",
+			pipe:   func() piper.Pipe { return pipeline.DecodeBase64(nil) },
+			output: each,
+			opts:   []func(*pipetest.ConformanceOptions){encoded(func(data []byte) []byte { return base64.StdEncoding.AppendEncode(nil, data) })},
+		},
+		{name: "EncodeHex", pipe: func() piper.Pipe { return pipeline.EncodeHex() }, output: each, opts: []func(*pipetest.ConformanceOptions){strs}},
+		{
+			name:   "DecodeHex",
+			pipe:   func() piper.Pipe { return pipeline.DecodeHex() },
+			output: each,
+			opts:   []func(*pipetest.ConformanceOptions){encoded(func(data []byte) []byte { return hex.AppendEncode(nil, data) })},
+		},
+		{name: "ChunkBytes", pipe: func() piper.Pipe { return pipeline.ChunkBytes(1) }, output: digits, opts: []func(*pipetest.ConformanceOptions){strs}},
+		{
+			name: "RotateFile",
+			pipe: func() piper.Pipe {
+				return pipeline.RotateFile[int](t.TempDir(), "items.log", nil, func(o *pipeline.RotateOptions) { o.MaxItems = 10 })
+			},
+			output: groups(10),
+		},
+		{name: "ExecCmd", pipe: func() piper.Pipe { return pipeline.ExecCmd(EchoCommand("ok", nil)) }, output: each, opts: []func(*pipetest.ConformanceOptions){strs}},
+		{
+			name:   "SendHTTP",
+			pipe:   func() piper.Pipe { return pipeline.SendHTTP(http.MethodPost, server.URL) },
+			output: each,
+			opts:   []func(*pipetest.ConformanceOptions){strs, func(o *pipetest.ConformanceOptions) { o.Large = 50 }},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			opts := append(tt.opts, func(o *pipetest.ConformanceOptions) { o.Output = tt.output })
+			pipetest.Conformance(t, func(context.Context) piper.Pipe { return tt.pipe() }, opts...)
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/internal/must"
+)
+
+// CSVOptions configure the CSV source and sink created by [FromCSV] and [ToCSV].
+type CSVOptions struct {
+	// Comma is the field delimiter. Defaults to ','.
+	Comma rune
+	// Comment, if not zero, starts the lines skipped by [FromCSV].
+	Comment rune
+	// LazyQuotes allows [FromCSV] to read quotes in unquoted fields, and single quotes in quoted fields.
+	LazyQuotes bool
+	// UseCRLF ends the rows written by [ToCSV] with \r\n instead of \n.
+	UseCRLF bool
+	// NoHeader maps the columns to the fields of the struct in order, rather than by the names
+	// of the header row. [FromCSV] reads no header, and [ToCSV] writes none.
+	NoHeader bool
+	// TimeLayout is the layout of [time.Time] fields. Defaults to [time.RFC3339].
+	TimeLayout string
+	// HandleError is called with a [*LineError] for each row that cannot be read, decoded or
+	// written, and with the error ending [FromCSV] if reading fails.
+	HandleError func(error)
+	// Errors receives a [*LineError] for each row that cannot be read or decoded by [FromCSV],
+	// which skips the row. Its input is closed once the source is done. [ToCSV] does not use it,
+	// negatively acknowledging the rows it cannot write instead.
+	Errors piper.Inlet
+}
+
+// newCSVOptions returns the default options, configured with the provided option functions.
+func newCSVOptions(opts ...func(*CSVOptions)) CSVOptions {
+	options := CSVOptions{
+		Comma:       ',',
+		TimeLayout:  time.RFC3339,
+		HandleError: must.IgnoreError,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// FromCSV creates a new [Flow] that sends each row read from r as a struct of type T. Columns are
+// mapped to the exported fields of T named by their csv tag, such as `csv:"name"`, or by the name
+// of the field; fields tagged `csv:"-"` are ignored. The fields of embedded structs without a tag
+// are mapped as if they were fields of T, while embedded pointers are not supported. Columns without
+// a field are ignored, and fields without a column are left unset. Strings, numbers, booleans, [time.Time] and types implementing
+// [encoding.TextUnmarshaler] are converted from their text, or pointers to them; empty columns
+// leave the field unset.
+//
+// Rows that cannot be read or converted are skipped and reported as a [*LineError]; see [CSVOptions]
+// for details. Like [FromReader], reading starts once the pipeline receives its first row, and stops
+// once r is exhausted or the context of the flow is done. FromCSV panics if T is not a struct.
+func FromCSV[T any](r io.Reader, opts ...func(*CSVOptions)) Flow {
+	var (
+		options = newCSVOptions(opts...)
+		fields  = csvFieldsOf[T]()
+		source  *seqSource[T]
+	)
+
+	// report reports that the row on the line cannot be read or decoded, routing it if configured.
+	report := func(ctx context.Context, line int, record []string, err error) bool {
+		text := strings.Join(record, string(options.Comma))
+		lineErr := &LineError{Line: line, Text: text, Err: err}
+		source.reject(source.receive(text), lineErr)
+		options.HandleError(lineErr)
+		if options.Errors == nil {
+			return true
+		}
+		select {
+		case options.Errors.In() <- lineErr:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	source = newSeqSource("csv", func(ctx context.Context) iter.Seq[T] {
+		return func(yield func(T) bool) {
+			if options.Errors != nil {
+				defer close(options.Errors.In())
+			}
+
+			reader := csv.NewReader(r)
+			reader.Comma = options.Comma
+			reader.Comment = options.Comment
+			reader.LazyQuotes = options.LazyQuotes
+			reader.FieldsPerRecord = -1
+			reader.ReuseRecord = true
+
+			columns := make([]int, len(fields))
+			for i := range columns {
+				columns[i] = i
+			}
+			if !options.NoHeader {
+				header, err := reader.Read()
+				if err != nil {
+					if !errors.Is(err, io.EOF) {
+						options.HandleError(err)
+					}
+					return
+				}
+				columns = csvColumns(fields, header)
+			}
+
+			for {
+				record, err := reader.Read()
+				if errors.Is(err, io.EOF) {
+					return
+				}
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					if !report(ctx, parseErr.StartLine, record, parseErr.Err) {
+						return
+					}
+					continue
+				}
+				if err != nil {
+					source.failure("read failed", err)
+					options.HandleError(err)
+					return
+				}
+
+				line, _ := reader.FieldPos(0)
+				value, err := decodeCSVRow[T](fields, columns, record, options.TimeLayout)
+				if err != nil {
+					if !report(ctx, line, record, err) {
+						return
+					}
+					continue
+				}
+				if !yield(value) {
+					return
+				}
+			}
+		}
+	})
+	return From(source)
+}
+
+// ToCSV creates a new [piper.Sink] that writes a header row, unless disabled, then each item of
+// type T as a row. Columns are mapped to the fields of T like [FromCSV]. Items are written like
+// [ToWriter]; rows that cannot be written are negatively acknowledged and reported as a [*LineError],
+// numbered by the line the row would start on. If the delimiter is invalid, the error is reported
+// once the sink is created, and every row fails. Use the Wait method of the sink to wait for its
+// input to be written. ToCSV panics if T is not a struct.
+func ToCSV[T any](w io.Writer, opts ...func(*CSVOptions)) *writerSink[T] {
+	var (
+		options = newCSVOptions(opts...)
+		fields  = csvFieldsOf[T]()
+		// line is the line the next row starts on, as quoted fields may span several lines.
+		line = 1
+	)
+
+	// format writes the row to a buffer with a csv.Writer, quoting fields as needed.
+	format := func(row []string) ([]byte, error) {
+		var (
+			buf    bytes.Buffer
+			writer = csv.NewWriter(&buf)
+		)
+		writer.Comma = options.Comma
+		writer.UseCRLF = options.UseCRLF
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+		writer.Flush()
+		return buf.Bytes(), writer.Error()
+	}
+
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.name
+	}
+	header, err := format(names)
+	switch {
+	case err != nil:
+		// only an invalid delimiter fails the header, and every row with it
+		options.HandleError(err)
+		header = nil
+	case options.NoHeader:
+		header = nil
+	default:
+		line += bytes.Count(header, []byte("\n"))
+	}
+
+	encode := func(item T) ([]byte, error) {
+		row, err := encodeCSVRow(fields, item, options.TimeLayout)
+		if err == nil {
+			var data []byte
+			if data, err = format(row); err == nil {
+				line += bytes.Count(data, []byte("\n"))
+				return data, nil
+			}
+		}
+		return nil, &LineError{Line: line, Err: err}
+	}
+
+	return newWriterSink("csv", w, encode, WriterOptions{
+		BufferSize:  4096,
+		HandleError: options.HandleError,
+	}, header)
+}
+
+// csvField is an exported field of a struct mapped to a CSV column.
+type csvField struct {
+	// name is the name of the column.
+	name string
+	// index is the index sequence of the field within the struct, as for [reflect.Value.FieldByIndex].
+	index []int
+}
+
+// csvFieldsOf returns the fields of the struct T mapped to CSV columns, in order.
+// It panics if T is not a struct.
+func csvFieldsOf[T any]() []csvField {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("pipeline: CSV rows must be structs, not %v", t))
+	}
+	return appendCSVFields(nil, t, nil)
+}
+
+// appendCSVFields appends the fields of the struct type t, found at the index sequence within
+// the row, flattening the fields of embedded structs without a tag.
+func appendCSVFields(fields []csvField, t reflect.Type, index []int) []csvField {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("csv"), ",")
+		at := append(index[:len(index):len(index)], i)
+		if f.Anonymous && name == "" && isCSVStruct(f.Type) {
+			fields = appendCSVFields(fields, f.Type, at)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		fields = append(fields, csvField{name: name, index: at})
+	}
+	return fields
+}
+
+// isCSVStruct reports whether the fields of a struct of type t are mapped to columns, rather
+// than the struct itself being converted from its text, like a [time.Time].
+func isCSVStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType &&
+		!t.Implements(textMarshalerType) && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// csvColumns returns the column of each field in the header, or -1 if the field has no column.
+func csvColumns(fields []csvField, header []string) []int {
+	columns := make([]int, len(fields))
+	for i, f := range fields {
+		columns[i] = -1
+		for j, name := range header {
+			if strings.TrimSpace(name) == f.name {
+				columns[i] = j
+				break
+			}
+		}
+	}
+	return columns
+}
+
+// decodeCSVRow converts the record into a struct of type T, reading each field from its column.
+func decodeCSVRow[T any](fields []csvField, columns []int, record []string, layout string) (T, error) {
+	var (
+		value T
+		v     = reflect.ValueOf(&value).Elem()
+	)
+	for i, f := range fields {
+		column := columns[i]
+		if column < 0 || column >= len(record) || record[column] == "" {
+			continue
+		}
+		if err := parseCSVValue(v.FieldByIndex(f.index), record[column], layout); err != nil {
+			return value, fmt.Errorf("column %q: %w", f.name, err)
+		}
+	}
+	return value, nil
+}
+
+// encodeCSVRow converts the struct into a record, writing each field to its column.
+func encodeCSVRow[T any](fields []csvField, item T, layout string) ([]string, error) {
+	var (
+		v   = reflect.ValueOf(item)
+		row = make([]string, len(fields))
+	)
+	for i, f := range fields {
+		text, err := formatCSVValue(v.FieldByIndex(f.index), layout)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", f.name, err)
+		}
+		row[i] = text
+	}
+	return row, nil
+}
+
+var (
+	timeType            = reflect.TypeFor[time.Time]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// parseCSVValue sets the field from its text.
+func parseCSVValue(v reflect.Value, text string, layout string) error {
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if err := parseCSVValue(ptr.Elem(), text, layout); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if v.Type() == timeType {
+		t, err := time.Parse(layout, text)
+		if err == nil {
+			v.Set(reflect.ValueOf(t))
+		}
+		return err
+	}
+	if v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+// formatCSVValue returns the text of the field.
+func formatCSVValue(v reflect.Value, layout string) (string, error) {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(layout), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	default:
+		return "", fmt.Errorf("unsupported type %v", v.Type())
+	}
+}
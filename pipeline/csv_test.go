@@ -0,0 +1,303 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// Level is a text-encoded enumeration.
+type Level int
+
+func (l Level) MarshalText() ([]byte, error) {
+	if l == 0 {
+		return []byte("low"), nil
+	}
+	return []byte("high"), nil
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 0
+	case "high":
+		*l = 1
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+// Grade is a text-encoded number that cannot be encoded if negative.
+type Grade int
+
+func (g Grade) MarshalText() ([]byte, error) {
+	if g < 0 {
+		return nil, errors.New("negative grade")
+	}
+	return []byte(strconv.Itoa(int(g))), nil
+}
+
+// Stamp is embedded in the rows of other structs.
+type Stamp struct {
+	At time.Time `csv:"at"`
+}
+
+type Remark struct {
+	Stamp
+	Text  string `csv:"text"`
+	Grade Grade  `csv:"grade"`
+}
+
+type Reading struct {
+	Sensor  string    `csv:"sensor"`
+	Value   float64   `csv:"value"`
+	Count   int       `csv:"count"`
+	Valid   bool      `csv:"valid"`
+	At      time.Time `csv:"at"`
+	Level   Level     `csv:"level"`
+	Note    *string   `csv:"note"`
+	Ignored string    `csv:"-"`
+}
+
+func TestFromCSV(t *testing.T) {
+	t.Parallel()
+
+	var (
+		at   = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		note = "checked"
+	)
+
+	t.Run("maps header columns to fields", func(t *testing.T) {
+		input := "valid,sensor,value,count,at,level,note,extra\n" +
+			"true,a,1.5,3,2024-01-02T03:04:05Z,high,checked,x\n" +
+			"false,\"b, c\",,,,low,,\n"
+
+		want := []Reading{
+			{Sensor: "a", Value: 1.5, Count: 3, Valid: true, At: at, Level: 1, Note: &note},
+			{Sensor: "b, c"},
+		}
+		if got := Consume[Reading](pipeline.FromCSV[Reading](strings.NewReader(input))); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("maps columns in order without a header", func(t *testing.T) {
+		input := "a;2;4;true;02/01/2024\n"
+		flow := pipeline.FromCSV[Reading](strings.NewReader(input), func(o *pipeline.CSVOptions) {
+			o.NoHeader = true
+			o.Comma = ';'
+			o.TimeLayout = "02/01/2006"
+		})
+
+		want := []Reading{{Sensor: "a", Value: 2, Count: 4, Valid: true, At: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}}
+		if got := Consume[Reading](flow); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("routes malformed rows", func(t *testing.T) {
+		var (
+			input = "sensor,count\n" +
+				"a,1\n" +
+				"b,two\n" +
+				"\"c,3\n"
+			errs = pipeline.ToSlice[*pipeline.LineError]()
+			flow = pipeline.FromCSV[Reading](strings.NewReader(input), func(o *pipeline.CSVOptions) {
+				o.Errors = errs
+			})
+		)
+
+		if got, want := Consume[Reading](flow), []Reading{{Sensor: "a", Count: 1}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+
+		got := errs.Slice()
+		if len(got) != 2 {
+			t.Fatalf("got %d errors, want 2: %v", len(got), got)
+		}
+		if got[0].Line != 3 || got[0].Text != "b,two" {
+			t.Errorf("got error %v on line %d for %q, want line 3", got[0], got[0].Line, got[0].Text)
+		}
+		if got[1].Line != 4 {
+			t.Errorf("got error %v on line %d, want line 4", got[1], got[1].Line)
+		}
+	})
+
+	t.Run("panics unless rows are structs", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic")
+			}
+		}()
+		pipeline.FromCSV[int](strings.NewReader(""))
+	})
+}
+
+func TestToCSV(t *testing.T) {
+	t.Parallel()
+
+	var (
+		at   = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		note = "checked"
+	)
+
+	t.Run("writes a header and rows", func(t *testing.T) {
+		var (
+			buf  bytes.Buffer
+			sink = pipeline.ToCSV[Reading](&buf)
+		)
+
+		pipeline.FromSlice(
+			Reading{Sensor: "a", Value: 1.5, Count: 3, Valid: true, At: at, Level: 1, Note: &note},
+			Reading{Sensor: "b, \"c\""},
+		).To(sink)
+
+		if err := sink.Wait(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "sensor,value,count,valid,at,level,note\n" +
+			"a,1.5,3,true,2024-01-02T03:04:05Z,high,checked\n" +
+			"\"b, \"\"c\"\"\",0,0,false,0001-01-01T00:00:00Z,low,\n"
+		if got := buf.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("writes a header without rows", func(t *testing.T) {
+		var (
+			buf  bytes.Buffer
+			sink = pipeline.ToCSV[Reading](&buf, func(o *pipeline.CSVOptions) {
+				o.Comma = '\t'
+				o.UseCRLF = true
+			})
+		)
+
+		pipeline.FromSlice[Reading]().To(sink)
+
+		if err := sink.Wait(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := buf.String(), "sensor\tvalue\tcount\tvalid\tat\tlevel\tnote\r\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("writes rows without a header", func(t *testing.T) {
+		var (
+			buf  bytes.Buffer
+			sink = pipeline.ToCSV[Reading](&buf, func(o *pipeline.CSVOptions) {
+				o.NoHeader = true
+			})
+		)
+
+		pipeline.FromSlice(Reading{Sensor: "a", At: at}).To(sink)
+
+		if err := sink.Wait(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := buf.String(), "a,0,0,false,2024-01-02T03:04:05Z,low,\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("reports the line of rows that cannot be written", func(t *testing.T) {
+		var (
+			buf  bytes.Buffer
+			errs []*pipeline.LineError
+			sink = pipeline.ToCSV[Remark](&buf, func(o *pipeline.CSVOptions) {
+				o.HandleError = func(err error) {
+					var lineErr *pipeline.LineError
+					if errors.As(err, &lineErr) {
+						errs = append(errs, lineErr)
+					}
+				}
+			})
+		)
+
+		// the first row spans lines 2 and 3
+		pipeline.FromSlice(Remark{Text: "a\nb", Grade: 1}, Remark{Text: "c", Grade: -1}, Remark{Text: "d", Grade: -1}).To(sink)
+		sink.Wait()
+
+		var lines []int
+		for _, err := range errs {
+			lines = append(lines, err.Line)
+		}
+		if want := []int{4, 4}; !reflect.DeepEqual(lines, want) {
+			t.Errorf("got errors on lines %v, want %v", lines, want)
+		}
+	})
+
+	t.Run("reports an invalid delimiter", func(t *testing.T) {
+		var (
+			buf  bytes.Buffer
+			errs []error
+			sink = pipeline.ToCSV[Remark](&buf, func(o *pipeline.CSVOptions) {
+				o.Comma = '"'
+				o.HandleError = func(err error) { errs = append(errs, err) }
+			})
+		)
+
+		pipeline.FromSlice(Remark{Text: "a"}).To(sink)
+		if err := sink.Wait(); err == nil {
+			t.Error("got no error, want the row to fail")
+		}
+		if len(errs) != 2 {
+			t.Errorf("got errors %v, want the delimiter and the row reported", errs)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("got %q, want nothing written", buf.String())
+		}
+	})
+
+	t.Run("flattens embedded structs", func(t *testing.T) {
+		var (
+			buf   bytes.Buffer
+			sink  = pipeline.ToCSV[Remark](&buf)
+			input = []Remark{{Stamp: Stamp{At: at}, Text: "a", Grade: 2}}
+		)
+
+		pipeline.FromSlice(input...).To(sink)
+		if err := sink.Wait(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := buf.String(), "at,text,grade\n2024-01-02T03:04:05Z,a,2\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+
+		type Row struct {
+			Stamp
+			Text string `csv:"text"`
+		}
+		want := []Row{{Stamp: Stamp{At: at}, Text: "a"}}
+		if got := Consume[Row](pipeline.FromCSV[Row](&buf)); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("round trips rows", func(t *testing.T) {
+		var (
+			buf   bytes.Buffer
+			sink  = pipeline.ToCSV[Reading](&buf)
+			input = []Reading{
+				{Sensor: "a", Value: 1.5, Count: 3, Valid: true, At: at, Level: 1, Note: &note},
+				{Sensor: "b\nc", At: at},
+			}
+		)
+
+		pipeline.FromSlice(input...).To(sink)
+		if err := sink.Wait(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got := Consume[Reading](pipeline.FromCSV[Reading](&buf)); !reflect.DeepEqual(got, input) {
+			t.Errorf("got %+v, want %+v", got, input)
+		}
+	})
+}
@@ -1,6 +1,11 @@
 package pipeline
 
-import "github.com/nisimpson/piper"
+import (
+	"log/slog"
+	"slices"
+
+	"github.com/nisimpson/piper"
+)
 
 // DemuxKeyFunction is a function that determines the destination branch for each item upstream.
 // It takes an item of type T and returns a string key identifying the target branch.
@@ -13,6 +18,7 @@ type DemuxPipelineFunction = func(source piper.Source) Flow
 // demuxer implements a pipeline sink that distributes incoming items to multiple branches
 // based on a key function. Each branch can have its own processing pipeline.
 type demuxer[In any] struct {
+	*stage
 	// in receives items to be distributed.
 	in chan any
 	// generators maps branch keys to functions that create the processing pipeline for that branch.
@@ -22,7 +28,11 @@ type demuxer[In any] struct {
 	// sources holds the source end of each branch's pipeline.
 	sources []piper.Source
 	// channels maps branch keys to the channels used to send items to each branch.
-	channels map[string]chan In
+	channels map[string]chan any
+	// topology records the branch pipelines, downstream of node.
+	topology *topology
+	// node identifies the sink within its topology.
+	node int
 }
 
 // Demux creates a fan-out [piper.Sink] that distributes items to multiple [Flow] branches.
@@ -30,29 +40,44 @@ type demuxer[In any] struct {
 // provide the processing pipeline for each branch.
 func Demux[In any](keyfn DemuxKeyFunction[In], generators map[string]DemuxPipelineFunction) demuxer[In] {
 	sink := demuxer[In]{
+		stage:       newStage("demux"),
 		in:          make(chan any),
 		keyFunction: keyfn,
 		generators:  generators,
 		sources:     make([]piper.Source, 0, len(generators)),
-		channels:    make(map[string]chan In),
+		channels:    make(map[string]chan any),
+		topology:    newTopology(),
 	}
 
-	for key, generator := range generators {
+	sink.node = sink.topology.add(sink, SinkNode)
+
+	keys := make([]string, 0, len(generators))
+	for key := range generators {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
 		var (
-			channel  = make(chan In)
+			channel  = make(chan any)
 			pipeline = FromChannel(channel)
 		)
+		// record the branch downstream of this sink, routed by its key
+		pipeline.topology, pipeline.node, pipeline.route = sink.topology, sink.node, key
 		sink.channels[key] = channel
-		sink.sources = append(sink.sources, generator(pipeline))
+		sink.sources = append(sink.sources, generators[key](pipeline))
 	}
 
 	go sink.start()
 	return sink
 }
 
-// Sources returns the source ends of all branch pipelines.
+// Sources returns the source ends of all branch pipelines, ordered by their key.
 func (d demuxer[In]) Sources() []piper.Source { return d.sources }
 
+// graft implements grafted, connecting the branch pipelines to the [Flow] sending items to this sink.
+func (d demuxer[In]) graft() (*topology, int) { return d.topology, d.node }
+
 // In returns the channel used to send items into the fan-out sink.
 func (d demuxer[In]) In() chan<- any { return d.in }
 
@@ -62,13 +87,18 @@ func (d demuxer[In]) start() {
 	for _, ch := range d.channels {
 		defer close(ch)
 	}
+	defer d.finish()
 	for input := range d.in {
-		item := input.(In)
-		key := d.keyFunction(item)
+		rec := d.receive(input)
+		key := d.keyFunction(unwrap(input).(In))
 		channel, ok := d.channels[key]
 		if !ok {
+			// no branch for this key; drop the item
+			d.log(slog.LevelWarn, "no branch for key", slog.String("key", key))
+			d.drop(input, rec)
 			continue
 		}
-		channel <- item
+		// send the item to the branch, preserving any message envelope
+		d.emit(channel, input, input, rec)
 	}
 }
@@ -17,6 +17,25 @@ Key features:
   - Extensible architecture supporting custom pipeline components
   - Built-in error handling and propagation
   - Support for both synchronous and asynchronous processing
+  - At-least-once delivery through acknowledged [Message] envelopes
+  - Per-stage instrumentation through an [Observer], such as the in-memory [Collector]
+  - Structured logging of stage activity with [Flow.WithLogger]
+  - Distributed tracing of each item across stages with [Flow.WithTracer]
+  - Topology introspection with [Flow.Graph], exported as Graphviz DOT or Mermaid text
+  - Named stages, see [Named], queryable at runtime with [Flow.Stages]
+  - Time-based stages scheduled by an injectable [Clock]
+  - Adjacent stateless stages, such as [Map] and [Filter], fused into a single goroutine
+  - Vectorized transport between stateless stages with [Flow.WithVectors]
+  - Iterator integration: sources from [iter.Seq] with [FromSeq], and flows ranged over with [Flow.All] and [Seq]
+  - Generator sources, such as [FromRange], [FromTicker], [Repeat], [Cycle] and [Unfold]
+  - Line-oriented I/O with [FromReader] and [ToWriter]
+  - JSON Lines codecs, such as [FromJSONLines], [DecodeJSONLines] and [ToJSONLines]
+  - CSV rows mapped to structs with [FromCSV] and [ToCSV]
+  - Following files as they grow, across truncation and rotation, with [FromFileTail]
+  - Watching drop folders for new, changed and removed files with [FromDirWatch]
+  - Rotating files rolled by size, item count or time with [RotateFile] and [ToRotatingFile]
+  - Byte transforms: compression with [Compress] and [Decompress], [EncodeBase64], [EncodeHex] and [ChunkBytes]
+  - Typed codecs for JSON, XML and gob with [Codec], [Encode], [Decode] and [FromEncoded]
 
 Pipeline construction follows a fluent builder pattern:
  1. Start with the [From] constructor to create a new [Flow].
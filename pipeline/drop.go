@@ -1,10 +1,15 @@
 package pipeline
 
-import "github.com/nisimpson/piper"
+import (
+	"fmt"
+
+	"github.com/nisimpson/piper"
+)
 
 // dropper represents a pipeline stage that drops a specified number of items
 // from the input stream before forwarding remaining items to the output stream.
 type dropper struct {
+	*stage
 	// in is the input channel that receives items from the previous stage
 	in chan any
 	// out is the output channel that sends items to the next stage
@@ -19,6 +24,7 @@ type dropper struct {
 // If count is negative, then DropN is the equivalent of [Passthrough].
 func DropN(count int) piper.Pipe {
 	pipe := dropper{
+		stage: newStage(fmt.Sprintf("drop(%d)", count)),
 		in:    make(chan any),
 		out:   make(chan any),
 		count: count,
@@ -44,11 +50,12 @@ func (d dropper) Out() <-chan any { return d.out }
 // The output channel is automatically closed wdhen processing is complete.
 func (d dropper) start() {
 	defer close(d.out)
+	defer d.finish()
 
 	// If count is negative, pass through all items
 	if d.count < 0 {
 		for item := range d.in {
-			d.out <- item
+			d.emit(d.out, item, item, d.receive(item))
 		}
 		return
 	}
@@ -56,11 +63,13 @@ func (d dropper) start() {
 	// Drop the first 'count' items
 	dropped := 0
 	for item := range d.in {
+		rec := d.receive(item)
 		if dropped < d.count {
 			dropped++
+			d.drop(item, rec) // dropped items are fully processed
 			continue
 		}
 		// Forward all remaining items
-		d.out <- item
+		d.emit(d.out, item, item, rec)
 	}
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
 )
 
 func TestDropN(t *testing.T) {
@@ -62,35 +63,7 @@ func TestDropN(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Create the pipeline
-			pipe := pipeline.DropN(tt.count)
-
-			// Create channel for collecting results
-			results := make([]any, 0)
-			done := make(chan struct{})
-
-			// Start collector goroutine
-			go func() {
-				for item := range pipe.Out() {
-					results = append(results, item)
-				}
-				close(done)
-			}()
-
-			// Send input
-			in := pipe.In()
-			for _, item := range tt.input {
-				in <- item
-			}
-			close(in)
-
-			// Wait for collection to complete
-			<-done
-
-			// Check results
-			if !reflect.DeepEqual(results, tt.expected) {
-				t.Errorf("got %v, want %v", results, tt.expected)
-			}
+			pipetest.AssertEmits(t, pipeline.DropN(tt.count), tt.input, tt.expected)
 		})
 	}
 
@@ -1,11 +1,16 @@
 package pipeline_test
 
 import (
+	"bufio"
 	"fmt"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
 )
 
 func ExampleFlatMap() {
@@ -92,21 +97,25 @@ func ExampleBatchN() {
 
 // ExampleBatchEvery demonstrates time-based batching
 func ExampleBatchEvery() {
-	// Create a batcher that sends items every 100ms
-	pipe := pipeline.BatchEvery[int](100 * time.Millisecond)
+	// Create a batcher that sends items every 100ms, according to a fake clock
+	clock := pipetest.NewFakeClock(time.Time{})
+	pipe := pipeline.BatchEvery[int](100*time.Millisecond, func(bo *pipeline.BatcherOptions) {
+		bo.Clock = clock
+	})
 
-	// Send input with delays
+	// Send input, advancing the clock past the first interval
 	in := pipe.In()
-	go func() {
-		in <- 1
-		in <- 2
-		time.Sleep(150 * time.Millisecond)
-		in <- 3
-		in <- 4
-		close(in)
-	}()
+	clock.BlockUntil(1)
+	in <- 1
+	in <- 2
+	clock.Advance(100 * time.Millisecond)
+	fmt.Printf("%v\n", (<-pipe.Out()).([]int))
+
+	in <- 3
+	in <- 4
+	close(in)
 
-	// Receive batches
+	// Receive the remaining batch
 	for batch := range pipe.Out() {
 		fmt.Printf("%v\n", batch.([]int))
 	}
@@ -1402,3 +1411,171 @@ func ExampleUnique_compositeKey() {
 	// Date: 2024-01-01, Category: B, Value: 3
 	// Date: 2024-01-02, Category: A, Value: 4
 }
+
+// ExampleFlow_Graph demonstrates exporting the topology of a pipeline as a Mermaid flowchart
+func ExampleFlow_Graph() {
+	sink := pipeline.ToSlice[int]()
+
+	flow := pipeline.FromSlice(1, 2, 3).
+		Thru(pipeline.Map(func(i int) int { return i * 2 }))
+
+	flow.To(sink)
+
+	fmt.Print(flow.Graph().Mermaid())
+	// Output:
+	// flowchart LR
+	// 	n0(["slice"])
+	// 	n1["map"]
+	// 	n2[("slice")]
+	// 	n0 --> n1
+	// 	n1 --> n2
+}
+
+// ExampleFlow_WithVectors demonstrates exchanging items in vectors between stateless stages
+func ExampleFlow_WithVectors() {
+	sink := pipeline.ToSlice[string]()
+
+	pipeline.FromSlice(1, 2, 3, 4, 5, 6).
+		WithVectors(64).
+		Thru(pipeline.Map(func(i int) int { return i * i })).
+		Thru(pipeline.KeepIf(func(i int) bool { return i%2 == 0 })).
+		Thru(pipeline.Map(strconv.Itoa)).
+		To(sink)
+
+	fmt.Println(sink.Slice())
+	// Output:
+	// [4 16 36]
+}
+
+// ExampleFromSeq demonstrates sending the values of an iterator through a pipeline
+func ExampleFromSeq() {
+	words := strings.Fields("the quick brown fox")
+
+	flow := pipeline.FromSeq(slices.Values(words)).
+		Thru(pipeline.Map(strings.ToUpper))
+
+	for word := range flow.All() {
+		fmt.Println(word)
+	}
+	// Output:
+	// THE
+	// QUICK
+	// BROWN
+	// FOX
+}
+
+// ExampleSeq demonstrates ranging over the first items of a pipeline, stopping its endless source
+func ExampleSeq() {
+	naturals := func(yield func(int) bool) {
+		for i := 1; yield(i); i++ {
+		}
+	}
+
+	flow := pipeline.FromSeq(naturals).
+		Thru(pipeline.Map(func(i int) int { return i * i }))
+
+	for square := range pipeline.Seq[int](flow) {
+		if square > 20 {
+			break
+		}
+		fmt.Println(square)
+	}
+	// Output:
+	// 1
+	// 4
+	// 9
+	// 16
+}
+
+// ExampleFromRange demonstrates generating a range of integers
+func ExampleFromRange() {
+	sink := pipeline.ToSlice[int]()
+
+	pipeline.FromRange(1, 6).
+		Thru(pipeline.Map(func(i int) int { return i * i })).
+		To(sink)
+
+	fmt.Println(sink.Slice())
+	// Output:
+	// [1 4 9 16 25]
+}
+
+// ExampleUnfold demonstrates generating items from a state threaded between calls,
+// such as the cursor of a paginated listing
+func ExampleUnfold() {
+	pages := [][]string{{"a", "b"}, {"c"}, {"d", "e"}}
+
+	flow := pipeline.Unfold(0, func(cursor int) ([]string, int, bool) {
+		if cursor >= len(pages) {
+			return nil, cursor, false
+		}
+		return pages[cursor], cursor + 1, true
+	}).Thru(pipeline.Flatten[[]string]())
+
+	fmt.Println(slices.Collect(pipeline.Seq[string](flow)))
+	// Output:
+	// [a b c d e]
+}
+
+// ExampleFromReader demonstrates processing the lines of a reader, writing the results to a writer
+func ExampleFromReader() {
+	input := strings.NewReader("INFO start\nERROR disk full\nINFO stop\nERROR timeout\n")
+	sink := pipeline.ToWriter[string](os.Stdout, nil)
+
+	pipeline.FromReader(input, bufio.ScanLines).
+		Thru(pipeline.KeepIf(func(line string) bool { return strings.HasPrefix(line, "ERROR") })).
+		Thru(pipeline.Map(func(line string) string { return strings.TrimPrefix(line, "ERROR ") })).
+		To(sink)
+
+	if err := sink.Wait(); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// disk full
+	// timeout
+}
+
+// ExampleFromJSONLines demonstrates decoding, transforming and encoding JSON Lines
+func ExampleFromJSONLines() {
+	type order struct {
+		ID    int     `json:"id"`
+		Total float64 `json:"total"`
+	}
+
+	input := strings.NewReader(`{"id":1,"total":9.5}
+{"id":2,"total":120}
+{"id":3,"total":42}
+`)
+	sink := pipeline.ToJSONLines(os.Stdout)
+
+	pipeline.FromJSONLines[order](input).
+		Thru(pipeline.KeepIf(func(o order) bool { return o.Total > 10 })).
+		To(sink)
+
+	sink.Wait()
+	// Output:
+	// {"id":2,"total":120}
+	// {"id":3,"total":42}
+}
+
+// ExampleFromCSV demonstrates reading CSV rows into structs and writing them back out
+func ExampleFromCSV() {
+	type product struct {
+		SKU   string  `csv:"sku"`
+		Price float64 `csv:"price"`
+		Stock int     `csv:"stock"`
+	}
+
+	input := strings.NewReader("sku,stock,price\nA-1,0,9.99\nB-2,12,24.5\nC-3,3,4\n")
+	sink := pipeline.ToCSV[product](os.Stdout)
+
+	pipeline.FromCSV[product](input).
+		Thru(pipeline.KeepIf(func(p product) bool { return p.Stock > 0 })).
+		To(sink)
+
+	sink.Wait()
+	// Output:
+	// sku,price,stock
+	// B-2,24.5,12
+	// C-3,4,3
+}
@@ -8,10 +8,7 @@ type FilterFunction[In any] func(In) bool
 
 // filterPipe implements a pipeline component that selectively passes items based on a filter function.
 type filterPipe[In any] struct {
-	// in receives items to be filtered
-	in chan any
-	// out sends items that pass the filter
-	out chan any
+	*stage
 	// filterFunc determines which items to keep
 	filterFunc FilterFunction[In]
 }
@@ -20,12 +17,11 @@ type filterPipe[In any] struct {
 // Only items for which fn returns true will be passed downstream.
 func Filter[In any](fn FilterFunction[In]) piper.Pipe {
 	pipe := filterPipe[In]{
-		in:         make(chan any),
-		out:        make(chan any),
+		stage:      newStage("filter"),
 		filterFunc: fn,
 	}
 
-	go pipe.start()
+	fuse(pipe)
 	return pipe
 }
 
@@ -44,18 +40,17 @@ func DropIf[In any](fn FilterFunction[In]) piper.Pipe {
 	})
 }
 
-func (f filterPipe[In]) In() chan<- any  { return f.in }
-func (f filterPipe[In]) Out() <-chan any { return f.out }
-
-// start begins the filtering process, passing through only the items that satisfy the filter function.
-func (f filterPipe[In]) start() {
-	defer close(f.out)
-	for input := range f.in {
-		test := f.filterFunc(input.(In))
-		if !test {
-			// drop and do not pass downstream
-			continue
-		}
-		f.out <- input
+func (f filterPipe[In]) In() chan<- any  { return f.launch().in }
+func (f filterPipe[In]) Out() <-chan any { return f.launch().out }
+
+// process implements processor, passing the input downstream only if it satisfies the filter function.
+func (f filterPipe[In]) process(input any, next func(any)) {
+	rec := f.receive(input)
+	test := f.filterFunc(unwrap(input).(In))
+	if !test {
+		// drop and do not pass downstream; the item is fully processed
+		f.drop(input, rec)
+		return
 	}
+	f.forward(next, input, input, rec)
 }
@@ -5,10 +5,7 @@ import "github.com/nisimpson/piper"
 // flatmapper implements a pipeline component that transforms each input item into multiple output items.
 // It executes a mapping function that returns a slice, then sends each element of that slice downstream individually.
 type flatmapper[In any, Out any] struct {
-	// in receives items to be transformed
-	in chan any
-	// out sends the transformed items
-	out chan any
+	*stage
 	// mapFunction converts each input item into a slice of output items
 	mapFunction MapFunction[In, []Out]
 }
@@ -17,11 +14,10 @@ type flatmapper[In any, Out any] struct {
 // Each input item is transformed into a slice of output items, which are then sent individually downstream.
 func FlatMap[In any, Out any](fn MapFunction[In, []Out]) piper.Pipe {
 	pipe := flatmapper[In, Out]{
-		in:          make(chan any),
-		out:         make(chan any),
+		stage:       newStage("flat_map"),
 		mapFunction: fn,
 	}
-	go pipe.start()
+	fuse(pipe)
 	return pipe
 }
 
@@ -39,17 +35,18 @@ func Flatten[In []Out, Out any]() piper.Pipe {
 	return FlatMap(func(in In) []Out { return in })
 }
 
-func (f flatmapper[In, Out]) In() chan<- any  { return f.in }
-func (f flatmapper[In, Out]) Out() <-chan any { return f.out }
+func (f flatmapper[In, Out]) In() chan<- any  { return f.launch().in }
+func (f flatmapper[In, Out]) Out() <-chan any { return f.launch().out }
 
-// start begins the flat mapping process, transforming each input item into multiple output items.
-// Each item in the output slice is sent individually downstream.
-func (f flatmapper[In, Out]) start() {
-	defer close(f.out)
-	for input := range f.in {
-		items := f.mapFunction(input.(In))
-		for _, item := range items {
-			f.out <- item
-		}
+// process implements processor, transforming the input item into multiple output items.
+// Each item in the output slice is passed individually downstream.
+func (f flatmapper[In, Out]) process(input any, next func(any)) {
+	rec := f.receive(input)
+	items := f.mapFunction(unwrap(input).(In))
+	latency := rec.elapsed()
+	// the input is acknowledged once all of its items are acknowledged
+	for _, item := range split(input, items) {
+		next(rec.carry(item))
 	}
+	f.emitted(input, rec, latency)
 }
@@ -2,7 +2,7 @@ package pipeline
 
 import (
 	"context"
-	"sync"
+	"log/slog"
 
 	"github.com/nisimpson/piper"
 )
@@ -16,33 +16,126 @@ type Flow struct {
 	// ctx is the context associated with this pipeline, used for cancellation and other context-related operations.
 	// It is propagated to downstream components in the pipeline.
 	ctx context.Context
+	// cancel cancels ctx, and the contexts the flow had before [Flow.WithContext], stopping the
+	// pipeline once a range loop over [Flow.All] breaks.
+	cancel context.CancelCauseFunc
+	// instruments are attached to each built-in component added to the pipeline.
+	instruments instruments
+	// topology records the components of the pipeline; it is shared by every flow extending it.
+	topology *topology
+	// node identifies the outlet within the topology.
+	node int
+	// route labels the edge to the next component added to the pipeline, such as a [Demux] key.
+	route string
+	// vectors is the maximum number of items exchanged at once between fused stages, if above one.
+	vectors int
 }
 
 // From creates a new pipeline starting from the given source.
 // This is typically used as the entry point for constructing a new pipeline.
 func From(source piper.Source) Flow {
-	ctx := context.TODO()
+	ctx, cancel := context.WithCancelCause(context.TODO())
+	flow := Flow{outlet: source, ctx: ctx, cancel: cancel}
 	if pipeline, ok := source.(Flow); ok {
-		ctx = pipeline.ctx
+		flow.ctx = pipeline.ctx
+		flow.cancel = pipeline.cancel
+		flow.instruments = pipeline.instruments
+		flow.topology = pipeline.topology
+		flow.node = pipeline.node
+		flow.route = pipeline.route
+		flow.vectors = pipeline.vectors
+		return flow
 	}
-	return Flow{outlet: source, ctx: ctx}
+	flow.topology = newTopology()
+	flow.node = flow.topology.add(source, SourceNode)
+	return flow
 }
 
 // WithContext adds the target context to this [Flow]. If you want this pipeline to support
-// cancellation, you must call this method before adding a [Pipe] or [Sink].
+// cancellation, you must call this method before adding a [Pipe] or [Sink]. Generator sources,
+// such as [FromSeq] and [FromRange], stop generating items once the context is done. The items
+// still in flight are then discarded, and discarded messages negatively acknowledged with the
+// cause of the context.
 func (f Flow) WithContext(ctx context.Context) Flow {
+	var (
+		cancel   context.CancelCauseFunc
+		previous = f.cancel
+	)
+	ctx, cancel = context.WithCancelCause(ctx)
 	f.ctx = ctx
+	f.cancel = func(cause error) {
+		cancel(cause)
+		previous(cause)
+	}
+	if b, ok := f.outlet.(binder); ok {
+		b.bind(ctx)
+	}
+	return f
+}
+
+// WithObserver attaches the [Observer] to this [Flow]. The observer receives the events of the
+// current source, if it is a built-in component, and of every built-in [piper.Pipe] or [piper.Sink]
+// added afterwards.
+func (f Flow) WithObserver(o Observer) Flow {
+	f.instruments.observer = o
+	f.attach(f.outlet)
+	return f
+}
+
+// WithLogger attaches the [slog.Logger] to this [Flow], configured with the provided option functions.
+// The current source, if it is a built-in component, and every built-in [piper.Pipe] or [piper.Sink]
+// added afterwards emit structured records to the logger, such as stage start and stop, failed items,
+// dropped items and slow items. See [LogOptions] for details.
+func (f Flow) WithLogger(logger *slog.Logger, opts ...func(*LogOptions)) Flow {
+	f.instruments.logger = logger
+	f.instruments.logOptions = newLogOptions(opts...)
+	f.attach(f.outlet)
+	return f
+}
+
+// WithTracer attaches the [Tracer] to this [Flow]. The current source, if it is a built-in component,
+// and every built-in [piper.Pipe] or [piper.Sink] added afterwards start a span for each item they
+// process, as a child of the span of the stage upstream. Requests sent by [SendHTTP] carry the
+// span in a W3C traceparent header, and batches created by [Batch] are linked to the spans of their
+// members.
+//
+// To carry the trace context, items flowing between built-in stages are wrapped in a [Message];
+// they are unwrapped before reaching any other [piper.Inlet]. Items read directly from [Flow.Out]
+// remain wrapped, and [Message.Context] returns the context carrying the span.
+func (f Flow) WithTracer(t Tracer) Flow {
+	f.instruments.tracer = t
+	f.attach(f.outlet)
+	return f
+}
+
+// WithVectors makes the stateless built-in stages added afterwards, such as [Map], [Filter] and
+// [FlatMap], exchange items in vectors of up to size items with each other, rather than one item per
+// channel operation. Each stage processes all of the items waiting upstream as a single chunk, and
+// sends its output as soon as the chunk is processed, so no item waits for a vector to fill.
+// Any other [piper.Inlet], such as a user-written [piper.Pipe], still receives single items.
+// A size of one or less disables vectors.
+func (f Flow) WithVectors(size int) Flow {
+	f.vectors = size
 	return f
 }
 
 // Thru adds one or more processing steps to the pipeline.
 // Each [Pipe] is connected in sequence (indexed order), with data flowing from one to the next.
 // Returns a new [Flow] instance representing the updated pipeline.
+//
+// Stateless built-in stages, such as [Map], [Filter], [FlatMap] and [Passthrough], read directly
+// from the outlet upstream, and adjacent ones added by the same call run fused in a single goroutine.
+// Once connected, a pipe must only be read through the returned [Flow].
 func (f Flow) Thru(pipes ...piper.Pipe) Flow {
 	for _, pipe := range pipes {
-		go f.transmit(pipe)
-		f = From(pipe).WithContext(f.ctx)
+		f.attach(pipe)
+		if !f.fuse(pipe) {
+			go f.transmit(pipe)
+		}
+		f = f.extend(pipe, f.link(pipe, PipeNode))
 	}
+	// start the chain ending at the outlet, as no more processors may be fused with it
+	launch(f.outlet)
 	return f
 }
 
@@ -50,19 +143,24 @@ func (f Flow) Thru(pipes ...piper.Pipe) Flow {
 // This is typically the final step in pipeline construction, establishing
 // where the processed data will ultimately be delivered.
 func (f Flow) To(sink piper.Sink) {
+	f.attach(sink)
+	f.link(sink, SinkNode)
 	go f.transmit(sink)
 }
 
 // Tee splits the pipeline into two branches.
 // The same data will be sent to both pipe1 and pipe2, allowing for parallelized processing paths.
-// Returns two new [Flow] instances, one for each branch.
+// Each [Message] is acknowledged once both branches acknowledge it, and negatively acknowledged
+// if either branch fails it. Returns two new [Flow] instances, one for each branch.
 func (f Flow) Tee(pipe1, pipe2 piper.Pipe) (Flow, Flow) {
-	go f.tee(pipe1, pipe2)
+	f.attach(pipe1)
+	f.attach(pipe2)
 	var (
-		f1 = From(pipe1).WithContext(f.ctx)
-		f2 = From(pipe2).WithContext(f.ctx)
+		node1 = f.link(pipe1, PipeNode)
+		node2 = f.link(pipe2, PipeNode)
 	)
-	return f1, f2
+	go f.tee(pipe1, pipe2)
+	return f.extend(pipe1, node1), f.extend(pipe2, node2)
 }
 
 // Out returns the output channel of the [Flow].
@@ -72,48 +170,185 @@ func (f Flow) Out() <-chan any {
 	return f.outlet.Out()
 }
 
+// Graph returns a snapshot of the topology of the pipeline, including every component connected
+// to it so far: sources, pipes added with [Flow.Thru] and [Flow.Tee], sinks, and the branches
+// and sources combined with [Demux] and [Mux]. Components are named by [Namer], or after
+// their type if they do not implement it.
+func (f Flow) Graph() Graph {
+	return f.topology.snapshot()
+}
+
+// extend returns a new [Flow] continuing from the outlet, identified by node, sharing the
+// context, instruments and topology of this flow.
+func (f Flow) extend(outlet piper.Outlet, node int) Flow {
+	f.outlet = outlet
+	f.node = node
+	f.route = ""
+	return f
+}
+
+// grafted is implemented by components carrying their own topology, such as the branches of a [Demux].
+type grafted interface {
+	graft() (*topology, int)
+}
+
+// link records the component of the given kind in the topology of this flow, downstream
+// of the outlet, and returns its node.
+func (f Flow) link(component any, kind NodeKind) int {
+	var node int
+	if g, ok := component.(grafted); ok {
+		var t *topology
+		t, node = g.graft()
+		f.topology.merge(t)
+	} else {
+		node = f.topology.add(component, kind)
+	}
+	f.topology.connect(f.node, node, f.route)
+	return node
+}
+
+// adopt records the source upstream of the outlet of this flow, merging its topology
+// if it is a [Flow].
+func (f Flow) adopt(source piper.Source) {
+	upstream := From(source)
+	f.topology.merge(upstream.topology)
+	f.topology.connect(upstream.node, f.node, upstream.route)
+}
+
+// fuse connects the pipe to the outlet of this flow without a relay goroutine, if it is an
+// unlaunched processor: it is either fused with the processor upstream, or reads directly
+// from the outlet, receiving vectors from the processor upstream if this flow has vectors.
+// It reports whether the pipe was connected.
+func (f Flow) fuse(pipe piper.Pipe) bool {
+	down := fusionOf(pipe)
+	if down == nil {
+		return false
+	}
+	up := fusionOf(f.outlet)
+	if up != nil && up.absorb(down) {
+		return true
+	}
+	if !down.redirect(f.outlet, f.ctx, f.vectors) {
+		return false
+	}
+	if up != nil && f.vectors > 1 {
+		up.vectorize(f.vectors)
+	}
+	return true
+}
+
+// attach attaches the instruments of this flow to the component, if it is a built-in one.
+func (f Flow) attach(component any) {
+	if s, ok := component.(instrumentable); ok {
+		s.instrument(f.instruments)
+	}
+}
+
+// composite is implemented by components, such as [Join], whose input is read by one of the
+// components they are made of.
+type composite interface {
+	// inlet returns the component reading the input of the composite.
+	inlet() piper.Inlet
+}
+
+// prepare readies the item for the inlet. Envelopes created by the flow to carry trace context
+// are only understood by built-in components, and are unwrapped for any other inlet.
+func prepare(in piper.Inlet, item any) any {
+	for {
+		c, ok := in.(composite)
+		if !ok {
+			break
+		}
+		in = c.inlet()
+	}
+	if _, ok := in.(instrumentable); ok {
+		return item
+	}
+	return strip(item)
+}
+
 // transmit handles the movement of data from the pipeline's current outlet to the given inlet.
-// It ensures proper cleanup by closing the inlet's channel when transmission is complete.
+// It ensures proper cleanup by closing the inlet's channel when transmission is complete, or
+// once the context of the flow is done, discarding the remaining items.
 func (f Flow) transmit(in piper.Inlet) {
 	defer close(in.In())
 	for {
-		b, ok := <-f.outlet.Out()
-		if !ok {
-			return
-		}
 		select {
+		case b, ok := <-f.outlet.Out():
+			if !ok {
+				return
+			}
+			select {
+			case in.In() <- prepare(in, b):
+			case <-f.ctx.Done():
+				nack(b, context.Cause(f.ctx))
+				f.discard()
+				return
+			}
 		case <-f.ctx.Done():
+			f.discard()
 			return
-		case in.In() <- b:
 		}
 	}
 }
 
-// tee is an internal helper function that implements the data duplication logic for the Tee method.
-// It reads from the pipeline's outlet and sends each item to both input channels.
-func (p Flow) tee(in1, in2 piper.Inlet) {
-	send := func(wg *sync.WaitGroup, in piper.Inlet, data any) {
-		defer wg.Done()
-		in.In() <- data
+// discard negatively acknowledges the items remaining in the outlet of this flow with the cause of
+// its context, in the background, so that the components upstream are not blocked sending them.
+func (f Flow) discard() {
+	go discardAll(f.outlet.Out(), context.Cause(f.ctx))
+}
+
+// discardAll negatively acknowledges every item received from out with err, until it is closed.
+func discardAll(out <-chan any, err error) {
+	for item := range out {
+		if v, ok := item.(*vector); ok {
+			for _, item := range *v {
+				nack(item, err)
+			}
+			continue
+		}
+		nack(item, err)
 	}
+}
 
-	defer close(in1.In())
-	defer close(in2.In())
+// tee is an internal helper function that implements the data duplication logic for the Tee method.
+// It reads from the pipeline's outlet and sends each item to both input channels, in whichever
+// order they become ready.
+func (p Flow) tee(in1, in2 piper.Inlet) {
+	var (
+		ch1 = in1.In()
+		ch2 = in2.In()
+	)
+	defer close(ch1)
+	defer close(ch2)
 
-	for {
-		b, ok := <-p.outlet.Out()
-		if !ok {
-			return
+	for b := range p.outlet.Out() {
+		items := []any{b, b}
+		if msg, ok := b.(Message); ok && msg.settlement != nil {
+			// the message is acknowledged once both branches acknowledge it
+			items = split(b, []any{msg.Payload, msg.Payload})
 		}
-		select {
-		case <-p.ctx.Done():
-			return
-		default:
-			wg := &sync.WaitGroup{}
-			wg.Add(2)
-			go send(wg, in1, b)
-			go send(wg, in2, b)
-			wg.Wait()
+		var (
+			out1, out2   = ch1, ch2
+			item1, item2 = prepare(in1, items[0]), prepare(in2, items[1])
+		)
+		// a nil channel blocks forever, disabling the case once its item is sent
+		for out1 != nil || out2 != nil {
+			select {
+			case out1 <- item1:
+				out1 = nil
+			case out2 <- item2:
+				out2 = nil
+			case <-p.ctx.Done():
+				if out1 != nil {
+					nack(item1, context.Cause(p.ctx))
+				}
+				if out2 != nil {
+					nack(item2, context.Cause(p.ctx))
+				}
+				p.discard()
+				return
+			}
 		}
 	}
 }
@@ -0,0 +1,293 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nisimpson/piper"
+)
+
+// processor is implemented by stateless stages, such as [Map] and [Filter], which process
+// each item independently of the others. Adjacent processors connected by a [Flow] are
+// fused into a single goroutine, handing items to each other with a function call rather
+// than a channel. Fused processors allocate nothing per item, beyond boxing outputs that do not
+// fit in a pointer, such as most integers, as items are exchanged as interface values.
+type processor interface {
+	// process processes the input, passing each output to next. It returns once next has
+	// returned for every output.
+	process(input any, next func(any))
+	// core returns the stage core of the processor.
+	core() *stage
+}
+
+// closer is implemented by processors with outputs of their own, such as an error output, to
+// close them once the fusion running the processor is done.
+type closer interface {
+	close()
+}
+
+// fusable is implemented by components that may run fused with adjacent processors.
+type fusable interface {
+	// fusion returns the fusion running the component, or nil if it is not a processor.
+	fusion() *fusion
+}
+
+// fusionMu guards the fusion of processors.
+var fusionMu sync.Mutex
+
+// fusion runs a chain of processors in a single goroutine. Until it is launched, either by a
+// [Flow] once connected or when its input or output channel is first requested, the chain may
+// be extended by absorbing the fusion of a downstream processor, or redirected to read directly
+// from an upstream outlet.
+type fusion struct {
+	// launched is set once the goroutine running the chain is started.
+	launched atomic.Bool
+	// absorbed is set once the chain has been absorbed by an upstream fusion.
+	absorbed bool
+	// in is the input channel of the chain.
+	in chan any
+	// out is the output channel of the last processor of the chain.
+	out chan any
+	// source is the channel the chain reads from: in, unless redirected.
+	source <-chan any
+	// ctx stops the chain once done, if the chain is redirected by a [Flow].
+	ctx context.Context
+	// processors are the processors of the chain, in order.
+	processors []processor
+	// gather is the maximum number of items read from the source before processing them as a
+	// chunk, if the chain is redirected by a [Flow] with vectors.
+	gather int
+	// vectors is the maximum number of items sent downstream in each [vector], once the chain is
+	// read by a downstream fusion with vectors; the chain sends single items while it is zero.
+	vectors atomic.Int64
+}
+
+// vector is a chunk of items exchanged between fusions, in a single channel operation. Vectors are
+// only sent to fusions, which unpack them; every other inlet receives single items. Vectors are sent
+// by pointer, and returned to the vector pool once unpacked, so that exchanging them does not allocate.
+type vector []any
+
+// vectorPool holds the vectors unpacked by fusions, for upstream fusions to fill again.
+var vectorPool sync.Pool
+
+// newVector returns an empty vector, reused from the pool if possible, holding up to size items.
+func newVector(size int) *vector {
+	if v, ok := vectorPool.Get().(*vector); ok && cap(*v) >= size {
+		return v
+	}
+	v := make(vector, 0, size)
+	return &v
+}
+
+// release returns the unpacked vector to the pool.
+func (v *vector) release() {
+	clear(*v)
+	*v = (*v)[:0]
+	vectorPool.Put(v)
+}
+
+// fuse creates a new fusion running the processor alone, and assigns it to the processor.
+func fuse(p processor) {
+	in := make(chan any)
+	p.core().fused.Store(&fusion{
+		in:         in,
+		out:        make(chan any),
+		source:     in,
+		processors: []processor{p},
+	})
+}
+
+// fusionOf returns the fusion running the component, or nil if it is not a processor.
+func fusionOf(component any) *fusion {
+	if f, ok := component.(fusable); ok {
+		return f.fusion()
+	}
+	return nil
+}
+
+// launch launches the fusion running the component, if it is a processor.
+func launch(component any) {
+	if f := fusionOf(component); f != nil {
+		f.launch()
+	}
+}
+
+// launch launches the fusion of the stage, if not already launched, and returns it.
+func (s *stage) launch() *fusion {
+	for {
+		f := s.fused.Load()
+		if f.launched.Load() || f.launch() {
+			return f
+		}
+		// the stage was absorbed by an upstream fusion in the meantime
+	}
+}
+
+// launch starts the goroutine running the chain, if not already started. It reports
+// false if the chain was absorbed by an upstream fusion, which runs it instead.
+func (f *fusion) launch() bool {
+	fusionMu.Lock()
+	defer fusionMu.Unlock()
+	if f.absorbed {
+		return false
+	}
+	if !f.launched.Load() {
+		f.launched.Store(true)
+		go f.run()
+	}
+	return true
+}
+
+// fusion implements fusable, returning the fusion of the stage if it is a processor.
+func (s *stage) fusion() *fusion {
+	return s.fused.Load()
+}
+
+// core implements processor, returning the stage itself.
+func (s *stage) core() *stage { return s }
+
+// absorb appends the processors of the downstream fusion to this fusion, so that they run in
+// the same goroutine. It reports whether the fusions could be merged; both must be unlaunched,
+// and the downstream fusion must not be redirected.
+func (f *fusion) absorb(down *fusion) bool {
+	fusionMu.Lock()
+	defer fusionMu.Unlock()
+	if down == nil || f == down || f.launched.Load() || f.absorbed ||
+		down.launched.Load() || down.absorbed || down.source != down.in {
+		return false
+	}
+	f.processors = append(f.processors, down.processors...)
+	f.out = down.out
+	down.absorbed = true
+	for _, p := range down.processors {
+		p.core().fused.Store(f)
+	}
+	return true
+}
+
+// redirect makes the unlaunched fusion read from the outlet instead of its input channel, until
+// ctx is done, gathering up to size items into each chunk it processes. It reports whether the
+// fusion could be redirected.
+func (f *fusion) redirect(outlet piper.Outlet, ctx context.Context, size int) bool {
+	// read the outlet first, as it may launch an upstream fusion
+	source := outlet.Out()
+
+	fusionMu.Lock()
+	defer fusionMu.Unlock()
+	if f.launched.Load() || f.absorbed || f.source != f.in {
+		return false
+	}
+	f.source = source
+	f.ctx = ctx
+	f.gather = size
+	// buffer a single item, preserving the slack of the relay goroutine this replaces
+	f.out = make(chan any, 1)
+	return true
+}
+
+// vectorize makes the fusion send its output in vectors of up to size items.
+func (f *fusion) vectorize(size int) {
+	f.vectors.Store(int64(size))
+}
+
+// run processes each item read from the source through the chain of processors,
+// closing the output once the source is closed or the context of the chain is done.
+func (f *fusion) run() {
+	defer close(f.out)
+	defer func() {
+		for _, p := range f.processors {
+			if c, ok := p.(closer); ok {
+				c.close()
+			}
+			p.core().finish()
+		}
+	}()
+
+	var done <-chan struct{}
+	if f.ctx != nil {
+		done = f.ctx.Done()
+	}
+
+	// chain each processor to the next, ending with the output
+	var (
+		last   = f.processors[len(f.processors)-1].core()
+		nexts  = make([]func(any), len(f.processors)+1)
+		output *vector
+	)
+	flush := func() {
+		if output != nil && len(*output) > 0 {
+			// the receiver owns the vector once sent, releasing it once unpacked
+			last.push(f.out, output)
+			output = nil
+		}
+	}
+	nexts[len(f.processors)] = func(item any) {
+		size := int(f.vectors.Load())
+		if size <= 1 {
+			last.push(f.out, item)
+			return
+		}
+		if output == nil {
+			output = newVector(size)
+		}
+		*output = append(*output, item)
+		if len(*output) >= size {
+			flush()
+		}
+	}
+	for i := len(f.processors) - 1; i >= 0; i-- {
+		p, next := f.processors[i], nexts[i+1]
+		nexts[i] = func(item any) { p.process(item, next) }
+	}
+
+	head := nexts[0]
+	process := func(item any) {
+		if v, ok := item.(*vector); ok {
+			for _, item := range *v {
+				head(item)
+			}
+			v.release()
+			return
+		}
+		head(item)
+	}
+
+	for {
+		select {
+		case item, ok := <-f.source:
+			if !ok {
+				flush()
+				return
+			}
+			process(item)
+			// process any items already waiting as part of the same chunk
+			if !f.drain(process) {
+				flush()
+				return
+			}
+			flush()
+		case <-done:
+			// discard the remaining items, so that the components upstream are not blocked
+			go discardAll(f.source, context.Cause(f.ctx))
+			return
+		}
+	}
+}
+
+// drain processes up to gather-1 more items from the source, without waiting for items
+// not yet sent. It reports false once the source is closed.
+func (f *fusion) drain(process func(any)) bool {
+	for n := 1; n < f.gather; n++ {
+		select {
+		case item, ok := <-f.source:
+			if !ok {
+				return false
+			}
+			process(item)
+		default:
+			return true
+		}
+	}
+	return true
+}
@@ -0,0 +1,203 @@
+package pipeline_test
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+func TestFusion(t *testing.T) {
+	t.Run("fuses adjacent processors", func(t *testing.T) {
+		var (
+			collector = pipeline.NewCollector()
+			double    = pipeline.Named("double", pipeline.Map(func(i int) int { return i * 2 }))
+			large     = pipeline.Named("large", pipeline.KeepIf(func(i int) bool { return i > 4 }))
+			repeat    = pipeline.Named("repeat", pipeline.FlatMap(func(i int) []int { return []int{i, i} }))
+			pass      = pipeline.Named("pass", pipeline.Passthrough())
+			format    = pipeline.Named("format", pipeline.Map(strconv.Itoa))
+			flow      = pipeline.FromSlice(1, 2, 3, 4).
+					WithObserver(collector).
+					Thru(double, large, repeat, pass, format)
+		)
+
+		got := Consume[string](flow)
+		if want := []string{"6", "6", "8", "8"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+
+		// each fused stage still reports its own events
+		Eventually(t, func() bool { return CountsOf(collector.Snapshot())["format"].Emitted == 4 })
+		want := map[string]Counts{
+			"double": {Received: 4, Emitted: 4},
+			"large":  {Received: 4, Emitted: 2, Dropped: 2},
+			"repeat": {Received: 2, Emitted: 2},
+			"pass":   {Received: 4, Emitted: 4},
+			"format": {Received: 4, Emitted: 4},
+		}
+		if got := CountsOf(collector.Snapshot()); !reflect.DeepEqual(got, want) {
+			t.Errorf("got counts %v, want %v", got, want)
+		}
+		Eventually(t, func() bool {
+			for name := range want {
+				if status, _ := flow.Stage(name); status.State != pipeline.StageStopped {
+					return false
+				}
+			}
+			return true
+		})
+	})
+
+	t.Run("connects processors across calls", func(t *testing.T) {
+		flow := pipeline.FromSlice(1, 2, 3).
+			Thru(pipeline.Map(func(i int) int { return i + 1 })).
+			Thru(pipeline.Map(func(i int) int { return i * 10 })).
+			Thru(pipeline.Passthrough())
+
+		if got, want := Consume[int](flow), []int{20, 30, 40}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("fuses joined processors", func(t *testing.T) {
+		pipe := pipeline.Join(
+			pipeline.Map(func(i int) int { return i * 2 }),
+			pipeline.KeepIf(func(i int) bool { return i > 2 }),
+			pipeline.Map(strconv.Itoa),
+		)
+
+		go func() {
+			defer close(pipe.In())
+			for i := 1; i <= 3; i++ {
+				pipe.In() <- i
+			}
+		}()
+
+		var got []string
+		for item := range pipe.Out() {
+			got = append(got, item.(string))
+		}
+		if want := []string{"4", "6"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("stops on cancellation", func(t *testing.T) {
+		var (
+			ctx, cancel = context.WithCancel(context.Background())
+			source      = make(chan int)
+			flow        = pipeline.FromChannel(source).
+					WithContext(ctx).
+					Thru(pipeline.Map(func(i int) int { return i }))
+		)
+		defer close(source)
+
+		cancel()
+		for range flow.Out() {
+			t.Fatal("expected no output")
+		}
+	})
+
+	t.Run("runs a chain in a single goroutine", func(t *testing.T) {
+		var (
+			source = make(chan int, 1)
+			flow   = pipeline.FromChannel(source)
+			before = runtime.NumGoroutine()
+		)
+
+		flow = flow.Thru(
+			pipeline.Passthrough(),
+			pipeline.Map(func(i int) int { return i + 1 }),
+			pipeline.Map(func(i int) int { return i + 1 }),
+			pipeline.KeepIf(func(int) bool { return true }),
+			pipeline.Map(func(i int) int { return i + 1 }),
+		)
+
+		if got := runtime.NumGoroutine() - before; got > 1 {
+			t.Errorf("got %d goroutines running the chain, want 1", got)
+		}
+
+		source <- 1
+		close(source)
+		if got, want := Consume[int](flow), []int{4}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestVectors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("exchanges items between stages", func(t *testing.T) {
+		var (
+			collector = pipeline.NewCollector()
+			want      []string
+			flow      = pipeline.FromSlice(items(100)...).
+					WithObserver(collector).
+					WithVectors(8).
+					Thru(pipeline.Named("double", pipeline.Map(func(i int) int { return i * 2 }))).
+					Thru(pipeline.Named("large", pipeline.KeepIf(func(i int) bool { return i >= 100 }))).
+					Thru(pipeline.Named("repeat", pipeline.FlatMap(func(i int) []int { return []int{i, i} }))).
+					Thru(pipeline.Named("format", pipeline.Map(strconv.Itoa)))
+		)
+		for i := 50; i < 100; i++ {
+			want = append(want, strconv.Itoa(i*2), strconv.Itoa(i*2))
+		}
+
+		if got := Consume[string](flow); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+
+		Eventually(t, func() bool { return CountsOf(collector.Snapshot())["format"].Emitted == 100 })
+		counts := CountsOf(collector.Snapshot())
+		for name, want := range map[string]Counts{
+			"double": {Received: 100, Emitted: 100},
+			"large":  {Received: 100, Emitted: 50, Dropped: 50},
+			"repeat": {Received: 50, Emitted: 50},
+			"format": {Received: 100, Emitted: 100},
+		} {
+			if got := counts[name]; got != want {
+				t.Errorf("got counts %v for %s, want %v", got, name, want)
+			}
+		}
+	})
+
+	t.Run("sends single items to other pipes", func(t *testing.T) {
+		flow := pipeline.FromSlice(items(100)...).
+			WithVectors(16).
+			Thru(pipeline.Map(func(i int) int { return i + 1 })).
+			Thru(NamelessPipe{pipeline.Map(func(i int) int { return i * 2 })}).
+			Thru(pipeline.Map(func(i int) int { return i - 1 }))
+
+		got := Consume[int](flow)
+		if len(got) != 100 {
+			t.Fatalf("got %d items, want 100", len(got))
+		}
+		for i, item := range got {
+			if want := (i+1)*2 - 1; item != want {
+				t.Fatalf("got %d at %d, want %d", item, i, want)
+			}
+		}
+	})
+
+	t.Run("acknowledges messages", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			sink       = pipeline.ToSlice[int]()
+		)
+
+		pipeline.FromSlice(deliveries.Messages(1, 2, 3, 4)...).
+			WithVectors(4).
+			Thru(pipeline.Map(func(i int) int { return i * 2 })).
+			Thru(pipeline.KeepIf(func(i int) bool { return i > 2 })).
+			To(sink)
+
+		if got, want := sink.Slice(), []int{4, 6, 8}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		Eventually(t, func() bool { return len(deliveries.Acked()) == 4 })
+	})
+}
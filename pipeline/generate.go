@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+// integer is the set of integer types generated by [FromRange].
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// FromRange creates a new [Flow] that sends the integers from start up to, but not including, end.
+// Nothing is sent if end is not greater than start.
+func FromRange[T integer](start, end T) Flow {
+	return fromSeq("range", func(context.Context) iter.Seq[T] {
+		return func(yield func(T) bool) {
+			for i := start; i < end; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// TickerOptions configure the source created by [FromTicker].
+type TickerOptions struct {
+	// Clock schedules the ticks. Defaults to [SystemClock].
+	Clock Clock
+}
+
+// FromTicker creates a new [Flow] that sends the current time, as a [time.Time], on every tick of
+// the interval, until the context of the flow is done. Ticks are dropped while the pipeline is busy,
+// like those of a [time.Ticker]. The interval must be greater than zero.
+func FromTicker(interval time.Duration, opts ...func(*TickerOptions)) Flow {
+	options := TickerOptions{Clock: SystemClock()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Clock == nil {
+		options.Clock = SystemClock()
+	}
+
+	return fromSeq("ticker", func(ctx context.Context) iter.Seq[time.Time] {
+		return func(yield func(time.Time) bool) {
+			ticker := options.Clock.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case now := <-ticker.C():
+					if !yield(now) {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	})
+}
+
+// Repeat creates a new [Flow] that sends the item n times. If n is negative, the item is
+// sent until the context of the flow is done.
+func Repeat[T any](item T, n int) Flow {
+	return fromSeq("repeat", func(context.Context) iter.Seq[T] {
+		return func(yield func(T) bool) {
+			for i := 0; n < 0 || i < n; i++ {
+				if !yield(item) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// Cycle creates a new [Flow] that sends the items in order, starting over after the last one,
+// until the context of the flow is done. Nothing is sent if there are no items.
+func Cycle[T any](items ...T) Flow {
+	return fromSeq("cycle", func(context.Context) iter.Seq[T] {
+		return func(yield func(T) bool) {
+			for len(items) > 0 {
+				for _, item := range items {
+					if !yield(item) {
+						return
+					}
+				}
+			}
+		}
+	})
+}
+
+// UnfoldFunction returns the item generated from the state, the state generating the next
+// item, and whether the item should be sent. Generation stops once it returns false.
+type UnfoldFunction[S any, T any] func(state S) (item T, next S, ok bool)
+
+// Unfold creates a new [Flow] that sends the items generated by next, starting from the seed
+// state and threading the state from each call to the next, until next returns false or the
+// context of the flow is done. It suits stateful generation, such as cursor-based pagination,
+// where each page returns the cursor of the next.
+func Unfold[S any, T any](seed S, next UnfoldFunction[S, T]) Flow {
+	return fromSeq("unfold", func(context.Context) iter.Seq[T] {
+		return func(yield func(T) bool) {
+			state := seed
+			for {
+				item, following, ok := next(state)
+				if !ok || !yield(item) {
+					return
+				}
+				state = following
+			}
+		}
+	})
+}
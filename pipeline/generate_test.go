@@ -0,0 +1,173 @@
+package pipeline_test
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+)
+
+// First returns the first n items of the flow, stopping it afterwards.
+func First[T any](flow pipeline.Flow, n int) []T {
+	var items []T
+	for item := range pipeline.Seq[T](flow) {
+		if len(items) == n {
+			break
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func TestFromRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		start, end int
+		want       []int
+	}{
+		{name: "sends each integer", start: 2, end: 5, want: []int{2, 3, 4}},
+		{name: "sends negative integers", start: -2, end: 1, want: []int{-2, -1, 0}},
+		{name: "sends nothing for an empty range", start: 3, end: 3, want: []int{}},
+		{name: "sends nothing for a reversed range", start: 5, end: 2, want: []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Consume[int](pipeline.FromRange(tt.start, tt.end)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("stops on cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		flow := pipeline.FromRange(0, 1_000_000).WithContext(ctx)
+
+		<-flow.Out()
+		cancel()
+		if got := len(Consume[int](flow)); got > 1 {
+			t.Errorf("got %d items after cancellation, want at most 1", got)
+		}
+	})
+}
+
+func TestFromTicker(t *testing.T) {
+	t.Parallel()
+
+	var (
+		start       = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock       = pipetest.NewFakeClock(start)
+		ctx, cancel = context.WithCancel(context.Background())
+		flow        = pipeline.FromTicker(time.Second, func(to *pipeline.TickerOptions) {
+			to.Clock = clock
+		}).WithContext(ctx)
+		out = flow.Out()
+	)
+	defer cancel()
+
+	for i := 1; i <= 3; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		if got, want := (<-out).(time.Time), start.Add(time.Duration(i)*time.Second); !got.Equal(want) {
+			t.Errorf("got tick %v, want %v", got, want)
+		}
+	}
+
+	cancel()
+	for range out {
+	}
+	if n := clock.Waiters(); n != 0 {
+		t.Errorf("got %d waiters after cancellation, want none", n)
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sends the item n times", func(t *testing.T) {
+		if got, want := Consume[string](pipeline.Repeat("a", 3)), []string{"a", "a", "a"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("sends nothing for zero", func(t *testing.T) {
+		if got := Consume[string](pipeline.Repeat("a", 0)); len(got) != 0 {
+			t.Errorf("got %v, want nothing", got)
+		}
+	})
+
+	t.Run("sends until cancelled if negative", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		flow := pipeline.Repeat("a", -1).WithContext(ctx)
+
+		for i := 0; i < 100; i++ {
+			<-flow.Out()
+		}
+		cancel()
+		for range flow.Out() {
+		}
+	})
+}
+
+func TestCycle(t *testing.T) {
+	t.Parallel()
+
+	t.Run("cycles through the items", func(t *testing.T) {
+		got := First[int](pipeline.Cycle(1, 2, 3), 7)
+		if want := []int{1, 2, 3, 1, 2, 3, 1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("sends nothing without items", func(t *testing.T) {
+		if got := Consume[int](pipeline.Cycle[int]()); len(got) != 0 {
+			t.Errorf("got %v, want nothing", got)
+		}
+	})
+}
+
+func TestUnfold(t *testing.T) {
+	t.Parallel()
+
+	t.Run("threads the state", func(t *testing.T) {
+		fibonacci := pipeline.Unfold([2]int{0, 1}, func(s [2]int) (int, [2]int, bool) {
+			return s[0], [2]int{s[1], s[0] + s[1]}, true
+		})
+		if got, want := First[int](fibonacci, 8), []int{0, 1, 1, 2, 3, 5, 8, 13}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("paginates with a cursor", func(t *testing.T) {
+		pages := map[string]struct {
+			items []string
+			next  string
+		}{
+			"":   {items: []string{"a", "b"}, next: "p2"},
+			"p2": {items: []string{"c"}, next: "p3"},
+			"p3": {items: []string{"d"}},
+		}
+		type cursor struct {
+			token string
+			done  bool
+		}
+
+		flow := pipeline.Unfold(cursor{}, func(c cursor) ([]string, cursor, bool) {
+			if c.done {
+				return nil, c, false
+			}
+			page := pages[c.token]
+			return page.items, cursor{token: page.next, done: page.next == ""}, true
+		}).Thru(pipeline.Flatten[[]string]())
+
+		if got, want := slices.Collect(pipeline.Seq[string](flow)), []string{"a", "b", "c", "d"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,299 @@
+package pipeline
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// NodeKind classifies the components of a pipeline [Graph].
+type NodeKind int
+
+const (
+	// SourceNode is a component producing items, such as [FromSlice].
+	SourceNode NodeKind = iota
+	// PipeNode is a component transforming items, such as [Map].
+	PipeNode
+	// SinkNode is a component consuming items, such as [ToSlice].
+	SinkNode
+)
+
+// String returns the name of the node kind.
+func (k NodeKind) String() string {
+	switch k {
+	case SourceNode:
+		return "source"
+	case PipeNode:
+		return "pipe"
+	case SinkNode:
+		return "sink"
+	default:
+		return fmt.Sprintf("NodeKind(%d)", int(k))
+	}
+}
+
+// Node is a component of a pipeline [Graph].
+type Node struct {
+	// ID identifies the node within its graph.
+	ID int
+	// Name is the name of the component.
+	Name string
+	// Kind classifies the component.
+	Kind NodeKind
+}
+
+// Edge is a connection between two nodes of a pipeline [Graph], along which items flow.
+type Edge struct {
+	// From is the identifier of the upstream node.
+	From int
+	// To is the identifier of the downstream node.
+	To int
+	// Label describes the items flowing along the edge, such as the key of a [Demux] route.
+	Label string
+}
+
+// Graph is a snapshot of the topology of a pipeline, as recorded by a [Flow]. It is returned
+// by [Flow.Graph], and can be exported as text with [Graph.DOT] or [Graph.Mermaid].
+type Graph struct {
+	// Nodes are the components of the pipeline, in the order they were added.
+	Nodes []Node
+	// Edges are the connections between the components, ordered by their upstream node.
+	Edges []Edge
+}
+
+// DOT renders the graph in the Graphviz DOT language. Sources, pipes and sinks are drawn
+// with distinct shapes, and labeled edges carry their label.
+func (g Graph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph pipeline {\n")
+	b.WriteString("\trankdir=LR;\n")
+	for _, node := range g.Nodes {
+		fmt.Fprintf(&b, "\tn%d [label=%s, shape=%s];\n", node.ID, dotQuote(node.Name), dotShape(node.Kind))
+	}
+	for _, edge := range g.Edges {
+		if edge.Label == "" {
+			fmt.Fprintf(&b, "\tn%d -> n%d;\n", edge.From, edge.To)
+			continue
+		}
+		fmt.Fprintf(&b, "\tn%d -> n%d [label=%s];\n", edge.From, edge.To, dotQuote(edge.Label))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid renders the graph as a Mermaid flowchart. Sources, pipes and sinks are drawn
+// with distinct shapes, and labeled edges carry their label.
+func (g Graph) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, node := range g.Nodes {
+		left, right := mermaidShape(node.Kind)
+		fmt.Fprintf(&b, "\tn%d%s%s%s\n", node.ID, left, mermaidQuote(node.Name), right)
+	}
+	for _, edge := range g.Edges {
+		if edge.Label == "" {
+			fmt.Fprintf(&b, "\tn%d --> n%d\n", edge.From, edge.To)
+			continue
+		}
+		fmt.Fprintf(&b, "\tn%d -->|%s| n%d\n", edge.From, mermaidQuote(edge.Label), edge.To)
+	}
+	return b.String()
+}
+
+// dotShape returns the DOT node shape of the kind.
+func dotShape(kind NodeKind) string {
+	switch kind {
+	case SourceNode:
+		return "invhouse"
+	case SinkNode:
+		return "house"
+	default:
+		return "box"
+	}
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// mermaidShape returns the Mermaid delimiters of the node shape of the kind.
+func mermaidShape(kind NodeKind) (string, string) {
+	switch kind {
+	case SourceNode:
+		return "([", "])"
+	case SinkNode:
+		return "[(", ")]"
+	default:
+		return "[", "]"
+	}
+}
+
+// mermaidQuote quotes s as a Mermaid string, using entity codes for the characters
+// Mermaid does not allow within quotes.
+func mermaidQuote(s string) string {
+	return `"` + strings.NewReplacer(`"`, "#quot;", "\n", " ").Replace(s) + `"`
+}
+
+// topologyMu guards every topology, as topologies are merged together when flows are combined.
+var topologyMu sync.Mutex
+
+// nextNodeID is the identifier of the next recorded node. Identifiers are unique across
+// topologies, so that nodes keep their identifier when topologies are merged.
+var nextNodeID int
+
+// topology records the components of a pipeline and their connections as it is built. It is
+// shared by every [Flow] extending the same pipeline, and merged with the topologies of
+// the flows combined into it by [Mux] or [Demux].
+type topology struct {
+	// nodes are the recorded components.
+	nodes []vertex
+	// edges are the recorded connections.
+	edges []Edge
+	// merged is the topology this one was merged into, if any.
+	merged *topology
+}
+
+// vertex is a component recorded in a topology.
+type vertex struct {
+	// id identifies the vertex across topologies.
+	id int
+	// kind classifies the component.
+	kind NodeKind
+	// component is the recorded component, queried for its name and status.
+	component any
+}
+
+// newTopology creates a new empty topology.
+func newTopology() *topology {
+	return &topology{}
+}
+
+// root returns the topology holding the nodes of t, following any merges.
+// The caller must hold topologyMu.
+func (t *topology) root() *topology {
+	for t.merged != nil {
+		t = t.merged
+	}
+	return t
+}
+
+// add records the component as a node of the given kind, returning its identifier.
+func (t *topology) add(component any, kind NodeKind) int {
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
+	r := t.root()
+	id := nextNodeID
+	nextNodeID++
+	r.nodes = append(r.nodes, vertex{id: id, kind: kind, component: component})
+	return id
+}
+
+// connect records an edge between two nodes.
+func (t *topology) connect(from, to int, label string) {
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
+	r := t.root()
+	r.edges = append(r.edges, Edge{From: from, To: to, Label: label})
+}
+
+// merge moves the nodes and edges of other into t. Components recorded afterwards
+// in other are recorded in t.
+func (t *topology) merge(other *topology) {
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
+	r, o := t.root(), other.root()
+	if r == o {
+		return
+	}
+	r.nodes = append(r.nodes, o.nodes...)
+	r.edges = append(r.edges, o.edges...)
+	o.nodes, o.edges = nil, nil
+	o.merged = r
+}
+
+// upstream returns the component of the node and every component upstream of it.
+func (t *topology) upstream(node int) []any {
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
+	var (
+		r          = t.root()
+		components = make(map[int]any, len(r.nodes))
+		visited    = map[int]bool{node: true}
+		queue      = []int{node}
+		upstream   []any
+	)
+	for _, v := range r.nodes {
+		components[v.id] = v.component
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if c, ok := components[id]; ok {
+			upstream = append(upstream, c)
+		}
+		for _, e := range r.edges {
+			if e.To == id && !visited[e.From] {
+				visited[e.From] = true
+				queue = append(queue, e.From)
+			}
+		}
+	}
+	return upstream
+}
+
+// vertices returns the recorded components in the order they were added.
+// The caller must hold topologyMu.
+func (t *topology) vertices() []vertex {
+	vertices := slices.Clone(t.root().nodes)
+	slices.SortFunc(vertices, func(a, b vertex) int { return cmp.Compare(a.id, b.id) })
+	return vertices
+}
+
+// snapshot returns the recorded graph, numbering its nodes from zero in the order they were added.
+func (t *topology) snapshot() Graph {
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
+	var (
+		vertices = t.vertices()
+		ids      = make(map[int]int, len(vertices))
+		g        = Graph{Nodes: make([]Node, 0, len(vertices)), Edges: slices.Clone(t.root().edges)}
+	)
+	for i, v := range vertices {
+		ids[v.id] = i
+		g.Nodes = append(g.Nodes, Node{ID: i, Name: nameOf(v.component), Kind: v.kind})
+	}
+	for i := range g.Edges {
+		g.Edges[i].From = ids[g.Edges[i].From]
+		g.Edges[i].To = ids[g.Edges[i].To]
+	}
+	slices.SortStableFunc(g.Edges, func(a, b Edge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To))
+	})
+	return g
+}
+
+// statuses returns the status of the recorded components in the order they were added.
+func (t *topology) statuses() []StageStatus {
+	topologyMu.Lock()
+	defer topologyMu.Unlock()
+	vertices := t.vertices()
+	statuses := make([]StageStatus, 0, len(vertices))
+	for _, v := range vertices {
+		status := statusOf(v.component)
+		status.Kind = v.kind
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// nameOf returns the name of the component. Components not implementing [Namer] are
+// named after their type.
+func nameOf(component any) string {
+	if n, ok := component.(Namer); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", component)
+}
@@ -0,0 +1,164 @@
+package pipeline_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+)
+
+type NamelessPipe struct {
+	piper.Pipe
+}
+
+func TestFlowGraph(t *testing.T) {
+	t.Parallel()
+
+	t.Run("records pipes, branches and sinks", func(t *testing.T) {
+		var (
+			source = pipeline.FromSlice(1, 2, 3)
+			evens  = pipeline.KeepIf(func(i int) bool { return i%2 == 0 })
+			odds   = pipeline.DropIf(func(i int) bool { return i%2 == 0 })
+			sink1  = pipeline.ToSlice[int]()
+			sink2  = pipeline.ToSlice[int]()
+		)
+
+		flow := source.Thru(pipeline.Map(func(i int) int { return i * 2 }))
+		left, right := flow.Tee(evens, odds)
+		left.To(sink1)
+		right.To(sink2)
+
+		want := pipeline.Graph{
+			Nodes: []pipeline.Node{
+				{ID: 0, Name: "slice", Kind: pipeline.SourceNode},
+				{ID: 1, Name: "map", Kind: pipeline.PipeNode},
+				{ID: 2, Name: "filter", Kind: pipeline.PipeNode},
+				{ID: 3, Name: "filter", Kind: pipeline.PipeNode},
+				{ID: 4, Name: "slice", Kind: pipeline.SinkNode},
+				{ID: 5, Name: "slice", Kind: pipeline.SinkNode},
+			},
+			Edges: []pipeline.Edge{
+				{From: 0, To: 1},
+				{From: 1, To: 2},
+				{From: 1, To: 3},
+				{From: 2, To: 4},
+				{From: 3, To: 5},
+			},
+		}
+
+		if got := left.Graph(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+		if got := right.Graph(); !reflect.DeepEqual(got, want) {
+			t.Errorf("branches do not share their graph: got %+v", got)
+		}
+
+		sink1.Slice()
+		sink2.Slice()
+	})
+
+	t.Run("records demux routes", func(t *testing.T) {
+		var (
+			sink  = pipeline.ToSlice[int]()
+			demux = pipeline.Demux(
+				func(i int) string { return map[bool]string{true: "even", false: "odd"}[i%2 == 0] },
+				map[string]pipeline.DemuxPipelineFunction{
+					"odd":  func(s piper.Source) pipeline.Flow { return pipeline.From(s) },
+					"even": func(s piper.Source) pipeline.Flow { return pipeline.From(s).Thru(pipeline.TakeN(1)) },
+				},
+			)
+			flow = pipeline.FromSlice(1, 2)
+		)
+
+		flow.To(demux)
+		pipeline.Mux(demux.Sources()...).To(sink)
+
+		want := pipeline.Graph{
+			Nodes: []pipeline.Node{
+				{ID: 0, Name: "demux", Kind: pipeline.SinkNode},
+				{ID: 1, Name: "take(1)", Kind: pipeline.PipeNode},
+				{ID: 2, Name: "slice", Kind: pipeline.SourceNode},
+				{ID: 3, Name: "mux", Kind: pipeline.SourceNode},
+				{ID: 4, Name: "slice", Kind: pipeline.SinkNode},
+			},
+			Edges: []pipeline.Edge{
+				{From: 0, To: 1, Label: "even"},
+				{From: 0, To: 3, Label: "odd"},
+				{From: 1, To: 3},
+				{From: 2, To: 0},
+				{From: 3, To: 4},
+			},
+		}
+
+		if got := flow.Graph(); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+
+		sink.Slice()
+	})
+
+	t.Run("names custom components after their type", func(t *testing.T) {
+		var (
+			pipe = NamelessPipe{Pipe: pipeline.Passthrough()}
+			flow = pipeline.FromSlice(1).Thru(pipe, pipeline.Join(pipeline.Passthrough(), pipeline.TakeN(1)))
+		)
+
+		Consume[int](flow)
+
+		want := []pipeline.Node{
+			{ID: 0, Name: "slice", Kind: pipeline.SourceNode},
+			{ID: 1, Name: "pipeline_test.NamelessPipe", Kind: pipeline.PipeNode},
+			{ID: 2, Name: "passthrough | take(1)", Kind: pipeline.PipeNode},
+			{ID: 3, Name: "*pipeline_test.Fixture[int]", Kind: pipeline.SinkNode},
+		}
+
+		if got := flow.Graph().Nodes; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestGraph(t *testing.T) {
+	t.Parallel()
+
+	graph := pipeline.Graph{
+		Nodes: []pipeline.Node{
+			{ID: 0, Name: "slice", Kind: pipeline.SourceNode},
+			{ID: 1, Name: `say "hi"`, Kind: pipeline.PipeNode},
+			{ID: 2, Name: "slice", Kind: pipeline.SinkNode},
+		},
+		Edges: []pipeline.Edge{
+			{From: 0, To: 1},
+			{From: 1, To: 2, Label: "ok"},
+		},
+	}
+
+	t.Run("dot", func(t *testing.T) {
+		want := `digraph pipeline {
+	rankdir=LR;
+	n0 [label="slice", shape=invhouse];
+	n1 [label="say \"hi\"", shape=box];
+	n2 [label="slice", shape=house];
+	n0 -> n1;
+	n1 -> n2 [label="ok"];
+}
+`
+		if got := graph.DOT(); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("mermaid", func(t *testing.T) {
+		want := `flowchart LR
+	n0(["slice"])
+	n1["say #quot;hi#quot;"]
+	n2[("slice")]
+	n0 --> n1
+	n1 -->|"ok"| n2
+`
+		if got := graph.Mermaid(); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+}
@@ -2,9 +2,9 @@ package pipeline
 
 import (
 	"bytes"
-	"encoding/json"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/nisimpson/piper"
 	"github.com/nisimpson/piper/internal/must"
@@ -23,13 +23,20 @@ type HttpPipeOptions struct {
 	HandleError func(error)
 	// HandleResponse processes the HTTP response and converts it to an item to be sent downstream.
 	HandleResponse func(*http.Response) (any, error)
-	// MarshalFunc converts pipeline items to bytes for the request body.
+	// Codec encodes pipeline items as the request body. Items that are already a []byte are sent
+	// as is. Defaults to encoding items as JSON values, with [JSONCodec].
+	Codec Codec[any]
+	// MarshalFunc converts pipeline items to bytes for the request body, taking precedence over
+	// the Codec if set.
+	//
+	// Deprecated: Set the Codec instead, such as with [NewCodec].
 	MarshalFunc HttpBodyMarshalFunction
 }
 
 // httpPipe implements a pipeline component that makes HTTP requests.
 // It can be used either as a source (FromHTTP) or as a processing step (SendHTTP).
 type httpPipe struct {
+	*stage
 	// url is the target URL for HTTP requests
 	url string
 	// method is the HTTP method to use (GET, POST, etc.)
@@ -42,11 +49,25 @@ type httpPipe struct {
 	out chan any
 }
 
+// HttpName returns the default name of the components created by [FromHTTP] and [SendHTTP] for
+// the method and URL, such as "http POST /path". Use it to name components standing in for them.
+func HttpName(method, rawURL string) string {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		path = u.Path
+	}
+	if path == "" {
+		path = "/"
+	}
+	return "http " + method + " " + path
+}
+
 // FromHTTP creates a new [Flow] that starts by making an HTTP request.
 // The provided body is used for the initial request, and the response becomes the first pipeline item.
 // Provide [HttpPipeOptions] to configure the default behavior.
 func FromHTTP(method string, url string, body io.Reader, opts ...func(*HttpPipeOptions)) Flow {
 	source := httpPipe{
+		stage:   newStage(HttpName(method, url)),
 		url:     url,
 		method:  method,
 		options: opts,
@@ -71,6 +92,7 @@ func FromHTTP(method string, url string, body io.Reader, opts ...func(*HttpPipeO
 // Provide [HttpPipeOptions] to configure the default behavior.
 func SendHTTP(method string, url string, opts ...func(*HttpPipeOptions)) piper.Pipe {
 	pipe := httpPipe{
+		stage:   newStage(HttpName(method, url)),
 		url:     url,
 		method:  method,
 		options: opts,
@@ -102,31 +124,54 @@ func (h httpPipe) start() {
 		Client:         &http.Client{},
 		HandleError:    must.IgnoreError,
 		HandleResponse: h.passResponse,
-		MarshalFunc:    json.Marshal,
+		Codec:          JSONCodec[any](),
 	}
 
 	defer close(h.out)
+	defer h.finish()
 	opts.apply(h.options...)
+	encode := encodeJSONLine[any]
+	switch {
+	case opts.MarshalFunc != nil:
+		encode = opts.MarshalFunc
+	case opts.Codec != nil:
+		encode = opts.Codec.Encode
+	}
 
 	for input := range h.in {
-		switch item := input.(type) {
+		rec := h.receive(input)
+		// each item is sent with a copy of the base request, so that it does not carry the
+		// headers set for the previous item
+		req := opts.Request.Clone(opts.Request.Context())
+		switch item := unwrap(input).(type) {
 		case []byte:
-			opts.Request.Body = io.NopCloser(bytes.NewBuffer(item))
+			req.Body = io.NopCloser(bytes.NewBuffer(item))
 		default:
-			data := must.Return(opts.MarshalFunc(item))
-			opts.Request.Body = io.NopCloser(bytes.NewBuffer(data))
+			data, err := encode(item)
+			if err != nil {
+				opts.HandleError(err)
+				h.fail(input, rec, err)
+				continue
+			}
+			req.Body = io.NopCloser(bytes.NewBuffer(data))
+		}
+		if rec.span != nil {
+			// propagate the trace context of the item to the server
+			req.Header.Set("traceparent", rec.span.SpanContext().TraceParent())
 		}
-		res, err := opts.Client.Do(opts.Request)
+		res, err := opts.Client.Do(req)
 		if err != nil {
 			opts.HandleError(err)
+			h.fail(input, rec, err)
 			continue
 		}
 		output, err := opts.HandleResponse(res)
 		if err != nil {
 			opts.HandleError(err)
+			h.fail(input, rec, err)
 			continue
 		}
-		h.out <- output
+		h.emit(h.out, input, rewrap(input, output), rec)
 	}
 }
 
@@ -219,3 +219,44 @@ func TestSendHTTP(t *testing.T) {
 		return
 	}
 }
+
+func TestSendHTTPBody(t *testing.T) {
+	t.Parallel()
+
+	var (
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(w, r.Body)
+		}))
+		item   = map[string]string{"text": "<b>&</b>"}
+		action = pipeline.SendHTTP(http.MethodPost, server.URL, func(hpo *pipeline.HttpPipeOptions) {
+			hpo.HandleResponse = func(r *http.Response) (any, error) {
+				data, err := io.ReadAll(r.Body)
+				return string(data), err
+			}
+		})
+	)
+	defer server.Close()
+
+	// items are encoded like json.Marshal by default, escaping characters significant to HTML
+	got := Consume[string](pipeline.FromSlice(item).Thru(action))
+	if want := []string{string(must.Return(json.Marshal(item)))}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got body %q, want %q", got, want)
+	}
+}
+
+func TestHttpName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		method, url, want string
+	}{
+		{method: http.MethodPost, url: "http://example.com/path?q=1", want: "http POST /path"},
+		{method: http.MethodGet, url: "http://example.com", want: "http GET /"},
+	}
+
+	for _, tt := range tests {
+		if got := pipeline.HttpName(tt.method, tt.url); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
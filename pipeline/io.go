@@ -0,0 +1,310 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"sync"
+
+	"github.com/nisimpson/piper/internal/must"
+)
+
+// ReaderOptions configure the source created by [FromReader].
+type ReaderOptions struct {
+	// MaxTokenSize is the maximum size of a token, in bytes. Reading fails with [bufio.ErrTooLong]
+	// on longer tokens. Defaults to [bufio.MaxScanTokenSize].
+	MaxTokenSize int
+	// HandleError is called when reading fails, ending the source.
+	HandleError func(error)
+}
+
+// FromReader creates a new [Flow] that sends each token read from r, as a string. Tokens are
+// split by the [bufio.SplitFunc], such as [bufio.ScanLines], [bufio.ScanWords] or [ScanDelimiter];
+// a nil split function splits lines. Reading starts once the pipeline receives its first token,
+// and stops once r is exhausted or the context of the flow is done. A read blocked on r is not
+// interrupted, but the source stops without waiting for it, and its token is discarded.
+func FromReader(r io.Reader, split bufio.SplitFunc, opts ...func(*ReaderOptions)) Flow {
+	options := ReaderOptions{
+		MaxTokenSize: bufio.MaxScanTokenSize,
+		HandleError:  must.IgnoreError,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if split == nil {
+		split = bufio.ScanLines
+	}
+
+	var source *seqSource[string]
+	source = newSeqSource("reader", func(ctx context.Context) iter.Seq[string] {
+		return func(yield func(string) bool) {
+			var (
+				tokens = make(chan string)
+				failed error
+			)
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			// scan in the background, as reads cannot be interrupted
+			go func() {
+				defer close(tokens)
+				scanner := bufio.NewScanner(r)
+				scanner.Split(split)
+				scanner.Buffer(make([]byte, 0, min(options.MaxTokenSize, 4096)), options.MaxTokenSize)
+				for scanner.Scan() {
+					select {
+					case tokens <- scanner.Text():
+					case <-ctx.Done():
+						return
+					}
+				}
+				failed = scanner.Err()
+			}()
+
+			for {
+				select {
+				case token, ok := <-tokens:
+					if !ok {
+						if failed != nil {
+							source.failure("read failed", failed)
+							options.HandleError(failed)
+						}
+						return
+					}
+					if !yield(token) {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	})
+	return From(source)
+}
+
+// ScanDelimiter returns a [bufio.SplitFunc] splitting tokens on the delimiter, which is removed
+// from each token. The last token is returned even if it does not end with the delimiter.
+func ScanDelimiter(delim []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.Index(data, delim); i >= 0 && len(delim) > 0 {
+			return i + len(delim), data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		// request more data
+		return 0, nil, nil
+	}
+}
+
+// ScanChunks returns a [bufio.SplitFunc] for [FromReader] that splits the input into chunks of size
+// bytes, the last chunk holding the remaining bytes, such as to stream a large file in parts. The size
+// must not exceed [ReaderOptions.MaxTokenSize]. ScanChunks panics if the size is less than 1.
+func ScanChunks(size int) bufio.SplitFunc {
+	if size < 1 {
+		panic("chunk size must be greater than 0")
+	}
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		switch {
+		case len(data) >= size:
+			return size, data[:size], nil
+		case atEOF && len(data) > 0:
+			return len(data), data, nil
+		default:
+			// request more data
+			return 0, nil, nil
+		}
+	}
+}
+
+// EncodeFunction represents a function that encodes an item as bytes.
+type EncodeFunction[In any] func(In) ([]byte, error)
+
+// WriterOptions configure the sink created by [ToWriter].
+type WriterOptions struct {
+	// Delimiter is written after each item. Defaults to a newline.
+	Delimiter []byte
+	// BufferSize is the size of the buffer holding the items before they are written, in bytes.
+	// Defaults to 4096.
+	BufferSize int
+	// HandleError is called when writing fails.
+	HandleError func(error)
+}
+
+// writerSink implements a pipeline sink that writes each item to a writer.
+type writerSink[In any] struct {
+	*stage
+	// in receives items to be written.
+	in chan any
+	// w buffers the items written.
+	w *bufio.Writer
+	// encode encodes each item.
+	encode EncodeFunction[In]
+	// options configure the delimiter and error handling.
+	options WriterOptions
+	// preamble is written before the first item, such as a header.
+	preamble []byte
+	// pending holds the items written since the last flush, reported and settled once flushed.
+	pending []written
+	// wg is used to signal when all items have been written.
+	wg *sync.WaitGroup
+	// err is the first error encountered while writing.
+	err error
+}
+
+// written is an item written but not yet flushed, with the receipt of its processing.
+type written struct {
+	item any
+	rec  receipt
+}
+
+// ToWriter creates a new [piper.Sink] that writes each item to w, encoded by the [EncodeFunction]
+// and followed by the delimiter. A nil encode function writes strings and byte slices as is, and
+// formats other items with [fmt.Sprint]. Items are buffered, and flushed whenever no more items are
+// waiting and once the input is closed. Items are reported as emitted and acknowledged once
+// flushed, and fail, negatively acknowledged, if they cannot be written or flushed.
+// Use the Wait method of the sink to wait for its input to be written.
+func ToWriter[In any](w io.Writer, encode EncodeFunction[In], opts ...func(*WriterOptions)) *writerSink[In] {
+	options := WriterOptions{
+		Delimiter:   []byte("\n"),
+		BufferSize:  4096,
+		HandleError: must.IgnoreError,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if encode == nil {
+		encode = encodeAny[In]
+	}
+
+	return newWriterSink("writer", w, encode, options, nil)
+}
+
+// newWriterSink creates and starts a new writer sink with the given name, writing the
+// preamble, if any, before the first item.
+func newWriterSink[In any](name string, w io.Writer, encode EncodeFunction[In], options WriterOptions, preamble []byte) *writerSink[In] {
+	sink := &writerSink[In]{
+		stage:    newStage(name),
+		in:       make(chan any),
+		w:        bufio.NewWriterSize(w, options.BufferSize),
+		encode:   encode,
+		options:  options,
+		preamble: preamble,
+		wg:       &sync.WaitGroup{},
+	}
+	sink.wg.Add(1)
+	go sink.start()
+	return sink
+}
+
+// encodeAny encodes strings and byte slices as is, and other items with [fmt.Sprint].
+func encodeAny[In any](item In) ([]byte, error) {
+	switch v := any(item).(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	default:
+		return []byte(fmt.Sprint(v)), nil
+	}
+}
+
+func (s *writerSink[In]) In() chan<- any { return s.in }
+
+// Wait blocks until every item has been written and flushed, returning the first error
+// encountered while writing, if any.
+func (s *writerSink[In]) Wait() error {
+	s.wg.Wait()
+	return s.err
+}
+
+// start writes each item received, flushing the writer whenever no item is waiting.
+func (s *writerSink[In]) start() {
+	defer s.wg.Done()
+	defer s.finish()
+	defer s.flush()
+
+	if len(s.preamble) > 0 {
+		if _, err := s.w.Write(s.preamble); err != nil {
+			s.failed(err)
+		}
+	}
+
+	for {
+		var (
+			item any
+			ok   bool
+		)
+		select {
+		case item, ok = <-s.in:
+		default:
+			// flush while idle, then wait for the next item
+			s.flush()
+			item, ok = <-s.in
+		}
+		if !ok {
+			return
+		}
+		s.write(item)
+	}
+}
+
+// write encodes and writes the item, followed by the delimiter.
+func (s *writerSink[In]) write(item any) {
+	rec := s.receive(item)
+	data, err := s.encode(unwrap(item).(In))
+	if err == nil {
+		_, err = s.w.Write(data)
+	}
+	if err == nil {
+		_, err = s.w.Write(s.options.Delimiter)
+	}
+	if err != nil {
+		s.failed(err)
+		s.fail(item, rec, err)
+		return
+	}
+	s.pending = append(s.pending, written{item: item, rec: rec})
+}
+
+// flush writes any buffered items, reporting the items written since the last flush as emitted
+// once flushed, or failed if the flush fails.
+func (s *writerSink[In]) flush() {
+	if s.w.Buffered() == 0 && len(s.pending) == 0 {
+		return
+	}
+	err := s.w.Flush()
+	pending := s.pending
+	s.pending = nil
+	if err == nil {
+		for _, w := range pending {
+			s.emitted(w.item, w.rec, w.rec.elapsed())
+			ack(w.item)
+		}
+		return
+	}
+	// a failed writer keeps returning the error it failed with, handled once
+	if !errors.Is(err, s.err) {
+		s.failed(err)
+	}
+	for _, w := range pending {
+		s.fail(w.item, w.rec, err)
+	}
+}
+
+// failed records the error, passing it to the error handler.
+func (s *writerSink[In]) failed(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+	s.options.HandleError(err)
+}
@@ -0,0 +1,207 @@
+package pipeline_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// FailingWriter fails every write with its error.
+type FailingWriter struct {
+	err error
+}
+
+func (w FailingWriter) Write([]byte) (int, error) { return 0, w.err }
+
+func TestFromReader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		split bufio.SplitFunc
+		want  []string
+	}{
+		{name: "splits lines by default", input: "a\nb\r\nc", want: []string{"a", "b", "c"}},
+		{name: "splits words", input: " the  quick\nfox ", split: bufio.ScanWords, want: []string{"the", "quick", "fox"}},
+		{name: "splits on a delimiter", input: "a||b||||c||", split: pipeline.ScanDelimiter([]byte("||")), want: []string{"a", "b", "", "c"}},
+		{name: "sends nothing for empty input", input: "", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Consume[string](pipeline.FromReader(strings.NewReader(tt.input), tt.split))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("reports tokens that are too long", func(t *testing.T) {
+		var errs []error
+		flow := pipeline.FromReader(strings.NewReader("ok\ntoo long\nlost"), nil, func(ro *pipeline.ReaderOptions) {
+			ro.MaxTokenSize = 4
+			ro.HandleError = func(err error) { errs = append(errs, err) }
+		})
+
+		if got, want := Consume[string](flow), []string{"ok"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], bufio.ErrTooLong) {
+			t.Errorf("got errors %v, want %v", errs, bufio.ErrTooLong)
+		}
+	})
+
+	t.Run("stops without waiting for a blocked read", func(t *testing.T) {
+		var (
+			ctx, cancel = context.WithCancel(context.Background())
+			input, feed = io.Pipe()
+			out         = pipeline.FromReader(input, nil).WithContext(ctx).Out()
+		)
+		defer feed.Close()
+
+		fmt.Fprintln(feed, "a")
+		if got := <-out; got != "a" {
+			t.Fatalf("got %v, want a", got)
+		}
+
+		// the next read blocks while the input remains open
+		cancel()
+		select {
+		case _, ok := <-out:
+			if ok {
+				t.Error("got an item, want the source closed")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("source did not stop")
+		}
+	})
+}
+
+func TestToWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes each item on its own line", func(t *testing.T) {
+		var (
+			buf  bytes.Buffer
+			sink = pipeline.ToWriter[any](&buf, nil)
+		)
+
+		pipeline.FromSlice[any]("a", []byte("b"), 3).To(sink)
+
+		if err := sink.Wait(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := buf.String(), "a\nb\n3\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("encodes items with a delimiter", func(t *testing.T) {
+		var (
+			buf    bytes.Buffer
+			encode = func(i int) ([]byte, error) { return []byte(strconv.Itoa(i * 2)), nil }
+			sink   = pipeline.ToWriter(&buf, encode, func(wo *pipeline.WriterOptions) {
+				wo.Delimiter = []byte(",")
+				wo.BufferSize = 1
+			})
+		)
+
+		pipeline.FromRange(1, 4).To(sink)
+
+		if err := sink.Wait(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := buf.String(), "2,4,6,"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("reports encoding errors", func(t *testing.T) {
+		var (
+			buf        bytes.Buffer
+			deliveries = &Deliveries{}
+			errOdd     = errors.New("odd")
+			sink       = pipeline.ToWriter(&buf, func(i int) ([]byte, error) {
+				if i%2 == 1 {
+					return nil, errOdd
+				}
+				return []byte(strconv.Itoa(i)), nil
+			})
+		)
+
+		pipeline.FromSlice(deliveries.Messages(1, 2, 3)...).To(sink)
+
+		if err := sink.Wait(); !errors.Is(err, errOdd) {
+			t.Errorf("got error %v, want %v", err, errOdd)
+		}
+		if got, want := buf.String(), "2\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if got, want := deliveries.Acked(), []int{2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got acked %v, want %v", got, want)
+		}
+		if got, want := deliveries.Nacked(), []int{1, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got nacked %v, want %v", got, want)
+		}
+	})
+
+	t.Run("reports write errors", func(t *testing.T) {
+		var (
+			errWrite   = errors.New("disk full")
+			errs       []error
+			deliveries = &Deliveries{}
+			sink       = pipeline.ToWriter[int](FailingWriter{errWrite}, nil, func(wo *pipeline.WriterOptions) {
+				wo.HandleError = func(err error) { errs = append(errs, err) }
+			})
+		)
+
+		pipeline.FromSlice(deliveries.Messages(1, 2)...).To(sink)
+
+		if err := sink.Wait(); !errors.Is(err, errWrite) {
+			t.Errorf("got error %v, want %v", err, errWrite)
+		}
+		if len(errs) == 0 {
+			t.Errorf("expected the error handler to be called")
+		}
+		// buffered items are not acknowledged until flushed
+		if got, want := deliveries.Nacked(), []int{1, 2}; !reflect.DeepEqual(got, want) || len(deliveries.Acked()) != 0 {
+			t.Errorf("got nacked %v and acked %v, want nacked %v", got, deliveries.Acked(), want)
+		}
+	})
+
+	t.Run("reports items once flushed", func(t *testing.T) {
+		for _, tt := range []struct {
+			name string
+			w    io.Writer
+			want Counts
+		}{
+			{name: "written", w: io.Discard, want: Counts{Received: 3, Emitted: 3}},
+			{name: "failed", w: FailingWriter{errors.New("disk full")}, want: Counts{Received: 3, Errors: 3}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				var (
+					collector = pipeline.NewCollector()
+					sink      = pipeline.ToWriter[int](tt.w, nil)
+				)
+
+				pipeline.FromSlice(1, 2, 3).WithObserver(collector).To(sink)
+				sink.Wait()
+
+				if got := CountsOf(collector.Snapshot())["writer"]; got != tt.want {
+					t.Errorf("got %+v, want %+v", got, tt.want)
+				}
+			})
+		}
+	})
+}
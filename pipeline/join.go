@@ -7,6 +7,8 @@ import "github.com/nisimpson/piper"
 type joinedPipe struct {
 	source piper.Pipe
 	target piper.Pipe
+	// fused is set if the target runs fused with the source, without a goroutine between them.
+	fused bool
 }
 
 // Join connects multiple pipes together in sequence, where the output of each pipe
@@ -21,13 +23,43 @@ func Join(src piper.Pipe, into ...piper.Pipe) piper.Pipe {
 }
 
 // newJoinedPipe creates a new joinedPipe instance that connects the source pipe
-// to the target pipe and starts the data flow between them.
+// to the target pipe and starts the data flow between them. Processors, such as
+// [Map] and [Filter], are fused to run in a single goroutine instead.
 func newJoinedPipe(src, tgt piper.Pipe) joinedPipe {
 	pipe := joinedPipe{source: src, target: tgt}
+	if up := fusionOf(src); up != nil && up.absorb(fusionOf(tgt)) {
+		pipe.fused = true
+		return pipe
+	}
 	go pipe.start()
 	return pipe
 }
 
+// fusion implements fusable, returning the fusion running both joined pipes, if fused.
+func (p joinedPipe) fusion() *fusion {
+	if p.fused {
+		return fusionOf(p.target)
+	}
+	return nil
+}
+
+// instrument attaches the instruments of a [Flow] to both joined pipes.
+func (p joinedPipe) instrument(i instruments) {
+	for _, pipe := range []piper.Pipe{p.source, p.target} {
+		if s, ok := pipe.(instrumentable); ok {
+			s.instrument(i)
+		}
+	}
+}
+
+// inlet implements composite, returning the source pipe reading the input of the joined pipe.
+func (p joinedPipe) inlet() piper.Inlet { return p.source }
+
+// Name implements [Namer], naming the joined pipe after both joined pipes.
+func (p joinedPipe) Name() string {
+	return nameOf(p.source) + " | " + nameOf(p.target)
+}
+
 // In returns the input channel of the joined pipe, which is the input channel
 // of the source pipe.
 func (p joinedPipe) In() chan<- any { return p.source.In() }
@@ -41,6 +73,6 @@ func (p joinedPipe) Out() <-chan any { return p.target.Out() }
 func (p joinedPipe) start() {
 	defer close(p.target.In())
 	for input := range p.source.Out() {
-		p.target.In() <- input
+		p.target.In() <- prepare(p.target, input)
 	}
 }
@@ -8,16 +8,33 @@ import (
 )
 
 func TestJoin(t *testing.T) {
-	var (
-		source = pipeline.FromSlice(1, 2, 3, 4)
-		pipe1  = pipeline.Map(func(i int) int { return i * 2 })
-		pipe2  = pipeline.Map(func(i int) int { return i * 3 })
-		pipe   = pipeline.Join(pipe1, pipe2)
-		want   = []int{6, 12, 18, 24}
-		got    = Consume[int](source.Thru(pipe))
-	)
+	t.Parallel()
 
-	if !reflect.DeepEqual(want, got) {
-		t.Errorf("want %v, got %v", want, got)
-	}
+	t.Run("joins pipes in order", func(t *testing.T) {
+		var (
+			source = pipeline.FromSlice(1, 2, 3, 4)
+			pipe1  = pipeline.Map(func(i int) int { return i * 2 })
+			pipe2  = pipeline.Map(func(i int) int { return i * 3 })
+			pipe   = pipeline.Join(pipe1, pipe2)
+			want   = []int{6, 12, 18, 24}
+			got    = Consume[int](source.Thru(pipe))
+		)
+
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("want %v, got %v", want, got)
+		}
+	})
+
+	t.Run("passes items to custom pipes under a tracer", func(t *testing.T) {
+		var (
+			id   = func(i int) int { return i }
+			pipe = pipeline.Join(Doubler(), pipeline.Map(id), Doubler())
+			flow = pipeline.FromSlice(1, 2, 3).WithTracer(&FakeTracer{}).Thru(pipeline.Map(id), pipe)
+			want = []int{4, 8, 12}
+		)
+
+		if got := Consume[int](flow); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
 }
@@ -0,0 +1,289 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/internal/must"
+)
+
+// JSONLinesOptions configure the JSON Lines codec stages, such as [DecodeJSONLines] and [EncodeJSONLines].
+type JSONLinesOptions struct {
+	// DisallowUnknownFields rejects lines holding an object with fields not matching the decoded type.
+	DisallowUnknownFields bool
+	// MaxLineSize is the maximum size of a line read by [FromJSONLines], in bytes.
+	// Defaults to 1 MiB.
+	MaxLineSize int
+	// HandleError is called with a [*LineError] for each line that cannot be decoded or encoded,
+	// and with the error ending [FromJSONLines] if reading fails.
+	HandleError func(error)
+	// Errors receives a [*LineError] for each line that cannot be decoded or encoded, carried
+	// in the envelope of the line if it is a [Message], to be settled by the receiver. Otherwise,
+	// such lines are negatively acknowledged. The input of Errors is closed once the stage is done.
+	Errors piper.Inlet
+}
+
+// LineError is the error of a line that cannot be decoded or encoded by a JSON Lines or CSV stage.
+type LineError struct {
+	// Line is the number of the line, counting from one. CSV rows are numbered by the
+	// line they start on, including the header.
+	Line int
+	// Text is the line that cannot be decoded, or empty if the line cannot be encoded.
+	// The fields of a CSV row are joined by the delimiter.
+	Text string
+	// Err is the decoding or encoding error.
+	Err error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error { return e.Err }
+
+// DecodeJSONLines creates a [piper.Pipe] that decodes each line, a string or []byte holding a JSON
+// value, into a value of type T. Blank lines are skipped, while null values are sent as the zero
+// value of T. Lines that cannot be decoded are reported as a [*LineError]; see [JSONLinesOptions]
+// for details.
+func DecodeJSONLines[T any](opts ...func(*JSONLinesOptions)) piper.Pipe {
+	options := newJSONLinesOptions(opts...)
+	return newJSONLinesConverter("jsonl decode", options, func(item any) (any, string, error) {
+		var line []byte
+		switch v := item.(type) {
+		case string:
+			line = []byte(v)
+		case []byte:
+			line = v
+		default:
+			return nil, fmt.Sprint(v), fmt.Errorf("unexpected %T, want string or []byte", item)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			return nil, "", errSkip
+		}
+		value, err := decodeJSONLine[T](line, options.DisallowUnknownFields)
+		if err != nil {
+			return nil, string(line), err
+		}
+		return value, "", nil
+	})
+}
+
+// EncodeJSONLines creates a [piper.Pipe] that encodes each item of type T as a JSON value on a
+// single line, without a trailing newline, sent as a []byte. Items that cannot be encoded are
+// reported as a [*LineError]; see [JSONLinesOptions] for details.
+func EncodeJSONLines[T any](opts ...func(*JSONLinesOptions)) piper.Pipe {
+	return newJSONLinesConverter("jsonl encode", newJSONLinesOptions(opts...), func(item any) (any, string, error) {
+		line, err := encodeJSONLine(item.(T))
+		return line, "", err
+	})
+}
+
+// FromJSONLines creates a new [Flow] that decodes each line read from r into a value of type T,
+// streaming the lines through a single [json.Decoder]. Blank lines are skipped, and lines that
+// cannot be decoded are skipped and reported as a [*LineError]; see [JSONLinesOptions] for details.
+// Like [FromReader], reading starts once the pipeline receives its first value, and stops once r is
+// exhausted, a line is longer than [JSONLinesOptions.MaxLineSize], or the context of the flow is done.
+func FromJSONLines[T any](r io.Reader, opts ...func(*JSONLinesOptions)) Flow {
+	var (
+		options = newJSONLinesOptions(opts...)
+		source  *seqSource[T]
+	)
+
+	// report reports that the line cannot be decoded, routing it if configured.
+	report := func(ctx context.Context, lineErr *LineError) bool {
+		source.reject(source.receive(lineErr.Text), lineErr)
+		options.HandleError(lineErr)
+		if options.Errors == nil {
+			return true
+		}
+		select {
+		case options.Errors.In() <- lineErr:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	source = newSeqSource("jsonl", func(ctx context.Context) iter.Seq[T] {
+		return func(yield func(T) bool) {
+			if options.Errors != nil {
+				defer close(options.Errors.In())
+			}
+
+			lines := newLineReader(r, options.MaxLineSize)
+			decoder := newJSONDecoder(lines, options.DisallowUnknownFields)
+			for {
+				var value T
+				lines.started = false
+				err := decoder.Decode(&value)
+				if err == nil {
+					err = lines.trailing(decoder)
+				}
+				if errors.Is(err, io.EOF) {
+					return
+				}
+				if err := lines.err; err != nil {
+					source.failure("read failed", err)
+					options.HandleError(err)
+					return
+				}
+				if err != nil {
+					if !report(ctx, &LineError{Line: lines.line, Text: lines.Text(), Err: err}) {
+						return
+					}
+					// the decoder cannot recover from syntax errors, so decoding resumes on the next line
+					lines.skip()
+					decoder = newJSONDecoder(lines, options.DisallowUnknownFields)
+					continue
+				}
+				if !yield(value) {
+					return
+				}
+			}
+		}
+	})
+	return From(source)
+}
+
+// lineReader reads the lines scanned from a reader, returning at most the rest of one line from each
+// call to Read. Once a value is started, it reports the end of its line as [io.ErrUnexpectedEOF], so
+// that a [json.Decoder] reading it never decodes a value spanning lines.
+type lineReader struct {
+	// scanner scans the lines of the reader.
+	scanner *bufio.Scanner
+	// err is the error that stopped the scanner, if any.
+	err error
+	// line is the number of the last line read, counting from one.
+	line int
+	// text is the last line read, ending with a newline.
+	text []byte
+	// rest is the part of text not read yet.
+	rest []byte
+	// started reports whether anything but whitespace was read since it was last reset.
+	started bool
+	// buffered holds the part of the line buffered by the decoder, when checked for trailing data.
+	buffered bytes.Buffer
+}
+
+// newLineReader creates a new lineReader reading lines of up to size bytes from r.
+func newLineReader(r io.Reader, size int) *lineReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, size)
+	return &lineReader{scanner: scanner}
+}
+
+// Read implements [io.Reader], reading the next line once the last one is read.
+func (r *lineReader) Read(p []byte) (int, error) {
+	if len(r.rest) == 0 {
+		if r.started {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if !r.scanner.Scan() {
+			if r.err = r.scanner.Err(); r.err != nil {
+				return 0, r.err
+			}
+			return 0, io.EOF
+		}
+		r.line++
+		r.text = append(append(r.text[:0], r.scanner.Bytes()...), '\n')
+		r.rest = r.text
+	}
+	n := copy(p, r.rest)
+	r.rest = r.rest[n:]
+	r.started = r.started || len(bytes.TrimSpace(p[:n])) > 0
+	return n, nil
+}
+
+// Text returns the last line read, without its newline.
+func (r *lineReader) Text() string {
+	return string(bytes.TrimSuffix(r.text, []byte("\n")))
+}
+
+// skip skips the rest of the last line read.
+func (r *lineReader) skip() {
+	r.rest = nil
+}
+
+// trailing returns an error if the rest of the last line read, including the part buffered by
+// the decoder, holds anything but whitespace once a value is decoded.
+func (r *lineReader) trailing(decoder *json.Decoder) error {
+	r.buffered.Reset()
+	if _, err := r.buffered.ReadFrom(decoder.Buffered()); err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(r.buffered.Bytes())) > 0 || len(bytes.TrimSpace(r.rest)) > 0 {
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
+
+// newJSONDecoder creates a new [json.Decoder] reading r.
+func newJSONDecoder(r io.Reader, disallowUnknownFields bool) *json.Decoder {
+	decoder := json.NewDecoder(r)
+	if disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder
+}
+
+// ToJSONLines creates a new [piper.Sink] that writes each item to w as a JSON value on its own
+// line. It writes items like [ToWriter], and encodes them like [EncodeJSONLines].
+func ToJSONLines(w io.Writer) *writerSink[any] {
+	return ToWriter(w, encodeJSONLine[any])
+}
+
+// newJSONLinesOptions returns the default options, configured with the provided option functions.
+func newJSONLinesOptions(opts ...func(*JSONLinesOptions)) JSONLinesOptions {
+	options := JSONLinesOptions{
+		MaxLineSize: 1 << 20,
+		HandleError: must.IgnoreError,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// newJSONLinesConverter creates a new converter with the given name, numbering the lines it
+// receives. Lines that cannot be converted are reported as a [*LineError], holding the text
+// returned along with the error, if any.
+func newJSONLinesConverter(name string, options JSONLinesOptions, convert func(any) (any, string, error)) piper.Pipe {
+	line := 0
+	return newConverter(name, options.HandleError, options.Errors, func(item any) (any, error) {
+		line++
+		output, text, err := convert(item)
+		if err != nil && !errors.Is(err, errSkip) {
+			return nil, &LineError{Line: line, Text: text, Err: err}
+		}
+		return output, err
+	})
+}
+
+// decodeJSONLine decodes the line into a value of type T, rejecting trailing data.
+func decodeJSONLine[T any](line []byte, disallowUnknownFields bool) (T, error) {
+	var value T
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	if disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(&value); err != nil {
+		return value, err
+	}
+	offset := decoder.InputOffset()
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return value, fmt.Errorf("unexpected data after JSON value at offset %d", offset)
+	}
+	return value, nil
+}
+
+// encodeJSONLine encodes the item as a JSON value, without a trailing newline, like [json.Marshal].
+func encodeJSONLine[T any](item T) ([]byte, error) {
+	return json.Marshal(item)
+}
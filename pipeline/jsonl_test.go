@@ -0,0 +1,222 @@
+package pipeline_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+type Event struct {
+	ID   int    `json:"id"`
+	Kind string `json:"kind"`
+}
+
+func TestDecodeJSONLines(t *testing.T) {
+	t.Parallel()
+
+	t.Run("decodes each line", func(t *testing.T) {
+		flow := pipeline.FromSlice[any](`{"id":1,"kind":"a"}`, []byte(`{"id":2,"kind":"b"}`), "  ").
+			Thru(pipeline.DecodeJSONLines[Event]())
+
+		want := []Event{{ID: 1, Kind: "a"}, {ID: 2, Kind: "b"}}
+		if got := Consume[Event](flow); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("decodes null values", func(t *testing.T) {
+		flow := pipeline.FromSlice("1", "null", "", `"a"`).
+			Thru(pipeline.DecodeJSONLines[any]())
+
+		var got []any
+		for item := range flow.Out() {
+			got = append(got, item)
+		}
+		if want := []any{1.0, nil, "a"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("reports malformed lines", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			errs       []error
+			flow       = pipeline.FromSlice(
+				deliveries.Message(1, `{"id":1}`),
+				deliveries.Message(2, `{"id":`),
+				deliveries.Message(3, `{"id":3} {"id":4}`),
+				deliveries.Message(4, `{"id":4}}`),
+			).Thru(pipeline.DecodeJSONLines[Event](func(o *pipeline.JSONLinesOptions) {
+				o.HandleError = func(err error) { errs = append(errs, err) }
+			}))
+		)
+
+		if got, want := Consume[Event](flow), []Event{{ID: 1}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := deliveries.Nacked(), []int{2, 3, 4}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got nacked %v, want %v", got, want)
+		}
+
+		var lines []int
+		for _, err := range errs {
+			var lineErr *pipeline.LineError
+			if !errors.As(err, &lineErr) {
+				t.Fatalf("got error %v, want a line error", err)
+			}
+			lines = append(lines, lineErr.Line)
+		}
+		if want := []int{2, 3, 4}; !reflect.DeepEqual(lines, want) {
+			t.Errorf("got errors on lines %v, want %v", lines, want)
+		}
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		flow := pipeline.FromSlice(`{"id":1,"kind":"a"}`, `{"id":2,"extra":true}`).
+			Thru(pipeline.DecodeJSONLines[Event](func(o *pipeline.JSONLinesOptions) {
+				o.DisallowUnknownFields = true
+			}))
+
+		if got, want := Consume[Event](flow), []Event{{ID: 1, Kind: "a"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("routes malformed lines to the error output", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			errs       = pipeline.ToSlice[*pipeline.LineError]()
+			flow       = pipeline.FromSlice(
+				deliveries.Message(1, `{"id":1}`),
+				deliveries.Message(2, `not json`),
+			).Thru(pipeline.DecodeJSONLines[Event](func(o *pipeline.JSONLinesOptions) {
+				o.Errors = errs
+			}))
+		)
+
+		if got, want := Consume[Event](flow), []Event{{ID: 1}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+
+		got := errs.Slice()
+		if len(got) != 1 || got[0].Line != 2 || got[0].Text != "not json" {
+			t.Fatalf("got errors %v, want one for line 2", got)
+		}
+		// the error output settles the routed lines
+		if got, want := slices.Sorted(slices.Values(deliveries.Acked())), []int{1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got acked %v, want %v", got, want)
+		}
+	})
+}
+
+func TestEncodeJSONLines(t *testing.T) {
+	t.Parallel()
+
+	t.Run("encodes each item", func(t *testing.T) {
+		flow := pipeline.FromSlice(Event{ID: 1, Kind: "<a>"}, Event{ID: 2}).
+			Thru(pipeline.EncodeJSONLines[Event]())
+
+		var got []string
+		for _, line := range Consume[[]byte](flow) {
+			got = append(got, string(line))
+		}
+		// characters significant to HTML are escaped, like json.Marshal
+		if want := []string{`{"id":1,"kind":"\u003ca\u003e"}`, `{"id":2,"kind":""}`}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("reports items that cannot be encoded", func(t *testing.T) {
+		var errs []error
+		flow := pipeline.FromSlice[any](1, make(chan int), 3).
+			Thru(pipeline.EncodeJSONLines[any](func(o *pipeline.JSONLinesOptions) {
+				o.HandleError = func(err error) { errs = append(errs, err) }
+			}))
+
+		if got := len(Consume[[]byte](flow)); got != 2 {
+			t.Errorf("got %d lines, want 2", got)
+		}
+		var lineErr *pipeline.LineError
+		if len(errs) != 1 || !errors.As(errs[0], &lineErr) || lineErr.Line != 2 {
+			t.Errorf("got errors %v, want one for line 2", errs)
+		}
+	})
+}
+
+func TestJSONLines(t *testing.T) {
+	t.Parallel()
+
+	t.Run("decodes and encodes lines", func(t *testing.T) {
+		var (
+			input = "{\"id\":1,\"kind\":\"a\"}\n\n{\"id\":2,\"kind\":\"b\"}\n"
+			buf   bytes.Buffer
+			sink  = pipeline.ToJSONLines(&buf)
+		)
+
+		pipeline.FromJSONLines[Event](strings.NewReader(input)).
+			Thru(pipeline.Map(func(e Event) Event {
+				e.ID *= 10
+				return e
+			})).
+			To(sink)
+
+		if err := sink.Wait(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, want := buf.String(), "{\"id\":10,\"kind\":\"a\"}\n{\"id\":20,\"kind\":\"b\"}\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("skips malformed lines", func(t *testing.T) {
+		var (
+			input = strings.Join([]string{`{"id":1}`, `{"id":`, ``, `{"id":3}}`, `{"id":4} {"id":5}`, `  {"id":6}  `, `[7]`}, "\n")
+			errs  []*pipeline.LineError
+			flow  = pipeline.FromJSONLines[Event](strings.NewReader(input), func(o *pipeline.JSONLinesOptions) {
+				o.HandleError = func(err error) {
+					var lineErr *pipeline.LineError
+					if errors.As(err, &lineErr) {
+						errs = append(errs, lineErr)
+					}
+				}
+			})
+		)
+
+		if got, want := Consume[Event](flow), []Event{{ID: 1}, {ID: 6}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		var lines []int
+		for _, err := range errs {
+			lines = append(lines, err.Line)
+		}
+		if want := []int{2, 4, 5, 7}; !reflect.DeepEqual(lines, want) {
+			t.Errorf("got errors on lines %v, want %v", lines, want)
+		}
+		if len(errs) > 1 && errs[1].Text != `{"id":3}}` {
+			t.Errorf("got text %q, want the malformed line", errs[1].Text)
+		}
+	})
+
+	t.Run("stops at lines that are too long", func(t *testing.T) {
+		var (
+			errs []error
+			flow = pipeline.FromJSONLines[int](strings.NewReader("1\n22222\n3\n"), func(o *pipeline.JSONLinesOptions) {
+				o.MaxLineSize = 4
+				o.HandleError = func(err error) { errs = append(errs, err) }
+			})
+		)
+
+		if got, want := Consume[int](flow), []int{1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], bufio.ErrTooLong) {
+			t.Errorf("got errors %v, want %v", errs, bufio.ErrTooLong)
+		}
+	})
+}
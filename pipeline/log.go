@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"log/slog"
+	"time"
+)
+
+// LogOptions configure the records emitted by the stages of a [Flow] to its logger.
+type LogOptions struct {
+	// Level is the minimum level of the records emitted by stages. Defaults to [slog.LevelInfo].
+	//
+	// Stages log their start, once they receive their first item, and their stop at
+	// [slog.LevelInfo], slow items and unrouted items at [slog.LevelWarn], failed items at
+	// [slog.LevelError], and dropped items at [slog.LevelDebug]. Stages neither retry items nor
+	// drop them on overflow, as they wait for downstream stages instead, so there are no
+	// records for either.
+	Level slog.Leveler
+	// SlowThreshold is the processing latency above which an item is logged as slow.
+	// To disable, set SlowThreshold to a zero duration.
+	SlowThreshold time.Duration
+}
+
+// newLogOptions returns the default [LogOptions], configured with the provided option functions.
+func newLogOptions(opts ...func(*LogOptions)) LogOptions {
+	options := LogOptions{
+		Level: slog.LevelInfo,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Level == nil {
+		options.Level = slog.LevelInfo
+	}
+	return options
+}
@@ -0,0 +1,172 @@
+package pipeline_test
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+)
+
+// Entry summarizes a captured log record.
+type Entry struct {
+	Level slog.Level
+	Msg   string
+	Stage string
+}
+
+func EntriesOf(records []slog.Record) []Entry {
+	entries := make([]Entry, 0, len(records))
+	for _, r := range records {
+		entry := Entry{Level: r.Level, Msg: r.Message}
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key == "stage" {
+				entry.Stage = a.Value.String()
+			}
+			return true
+		})
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestWithLogger(t *testing.T) {
+	t.Parallel()
+
+	t.Run("logs stage lifecycle and failures", func(t *testing.T) {
+		var (
+			handler = pipetest.NewRecordingHandler()
+			sink    = pipeline.ToSlice[string]()
+			action  = pipeline.ExecCmd(EchoCommand("", errors.New("an error")))
+		)
+
+		pipeline.FromSlice("a").
+			WithLogger(slog.New(handler)).
+			Thru(action).
+			To(sink)
+
+		sink.Slice()
+
+		want := []Entry{
+			{Level: slog.LevelInfo, Msg: "stage started", Stage: "command"},
+			{Level: slog.LevelError, Msg: "item failed", Stage: "command"},
+			{Level: slog.LevelInfo, Msg: "stage stopped", Stage: "command"},
+			{Level: slog.LevelInfo, Msg: "stage stopped", Stage: "slice"},
+		}
+
+		Eventually(t, func() bool {
+			return slices.Equal(EntriesOf(handler.Records()), want)
+		})
+	})
+
+	t.Run("logs the start of each stage once", func(t *testing.T) {
+		var (
+			handler = pipetest.NewRecordingHandler()
+			flow    = pipeline.FromSlice(1, 2, 3).
+				WithLogger(slog.New(handler)).
+				Thru(pipeline.Map(func(i int) int { return i })).
+				WithObserver(pipeline.NewCollector()).
+				WithTracer(&FakeTracer{})
+		)
+
+		Consume[int](flow)
+
+		Eventually(t, func() bool {
+			return slices.Contains(EntriesOf(handler.Records()), Entry{
+				Level: slog.LevelInfo,
+				Msg:   "stage stopped",
+				Stage: "map",
+			})
+		})
+		started := 0
+		for _, entry := range EntriesOf(handler.Records()) {
+			if entry.Msg == "stage started" {
+				started++
+			}
+		}
+		if started != 1 {
+			t.Errorf("got %d start records, want 1", started)
+		}
+	})
+
+	t.Run("filters records by level", func(t *testing.T) {
+		var (
+			handler = pipetest.NewRecordingHandler()
+			sink    = pipeline.ToSlice[int]()
+			evens   = pipeline.KeepIf(func(i int) bool { return i%2 == 0 })
+		)
+
+		pipeline.FromSlice(1, 2, 3).
+			WithLogger(slog.New(handler), func(lo *pipeline.LogOptions) {
+				lo.Level = slog.LevelDebug
+			}).
+			Thru(evens).
+			To(sink)
+
+		sink.Slice()
+
+		Eventually(t, func() bool {
+			dropped := 0
+			for _, entry := range EntriesOf(handler.Records()) {
+				if entry.Msg == "item dropped" && entry.Stage == "filter" {
+					dropped++
+				}
+			}
+			return dropped == 2
+		})
+	})
+
+	t.Run("logs unrouted items", func(t *testing.T) {
+		var (
+			handler = pipetest.NewRecordingHandler()
+			demux   = pipeline.Demux(
+				func(s string) string { return s },
+				map[string]pipeline.DemuxPipelineFunction{
+					"known": func(s piper.Source) pipeline.Flow { return pipeline.From(s) },
+				},
+			)
+		)
+
+		pipeline.FromSlice("unknown").
+			WithLogger(slog.New(handler)).
+			To(demux)
+
+		Consume[string](demux.Sources()[0])
+
+		Eventually(t, func() bool {
+			return slices.Contains(EntriesOf(handler.Records()), Entry{
+				Level: slog.LevelWarn,
+				Msg:   "no branch for key",
+				Stage: "demux",
+			})
+		})
+	})
+
+	t.Run("logs slow items", func(t *testing.T) {
+		var (
+			handler = pipetest.NewRecordingHandler()
+			slow    = pipeline.Map(func(i int) int {
+				time.Sleep(2 * time.Millisecond)
+				return i
+			})
+		)
+
+		Consume[int](pipeline.FromSlice(1).
+			WithLogger(slog.New(handler), func(lo *pipeline.LogOptions) {
+				lo.SlowThreshold = time.Millisecond
+			}).
+			Thru(slow))
+
+		Eventually(t, func() bool {
+			return slices.Contains(EntriesOf(handler.Records()), Entry{
+				Level: slog.LevelWarn,
+				Msg:   "slow item",
+				Stage: "map",
+			})
+		})
+	})
+}
@@ -1,6 +1,8 @@
 package pipeline
 
 import (
+	"context"
+
 	"github.com/nisimpson/piper"
 )
 
@@ -10,39 +12,46 @@ type MapFunction[T any, U any] func(T) U
 
 // mapper implements a pipeline component that transforms items using a mapping function.
 type mapper[In any, Out any] struct {
-	// in receives items to be transformed
-	in chan any
-	// out sends transformed items
-	out chan any
+	*stage
 	// transform is the function that converts items from type In to type Out
-	transform MapFunction[In, Out]
+	transform func(context.Context, In) Out
+	// ctx is the context passed to transform, along with the trace context of each item
+	ctx context.Context
 }
 
 // Map creates a new [piper.Pipe] component that transforms items using the provided function.
 // Each input item is transformed from type In to type Out using the [MapFunction] fn.
 func Map[In any, Out any](fn MapFunction[In, Out]) piper.Pipe {
+	return MapContext(context.Background(), func(_ context.Context, in In) Out {
+		return fn(in)
+	})
+}
+
+// MapContext creates a new [piper.Pipe] component like [Map], calling fn with a context done
+// when ctx is done and carrying the trace context of each item. Pass it on to the services called
+// by fn, such as with an HTTP request, to follow an item traced by the [Flow] through them.
+func MapContext[In any, Out any](ctx context.Context, fn func(context.Context, In) Out) piper.Pipe {
 	pipe := mapper[In, Out]{
-		in:        make(chan any),
-		out:       make(chan any),
+		stage:     newStage("map"),
 		transform: fn,
+		ctx:       ctx,
 	}
 
-	go pipe.start()
+	fuse(pipe)
 	return pipe
 }
 
-func (m mapper[In, Out]) In() chan<- any  { return m.in }
-func (m mapper[In, Out]) Out() <-chan any { return m.out }
+func (m mapper[In, Out]) In() chan<- any  { return m.launch().in }
+func (m mapper[In, Out]) Out() <-chan any { return m.launch().out }
 
-// start begins the transformation process, converting each input item to an output item
-// using the mapping function. Each transformed item is sent downstream.
-func (m mapper[In, Out]) start() {
-	defer close(m.out)
-	for input := range m.in {
-		// execute the transformation
-		output := m.transform(input.(In))
+// process implements processor, converting the input item to an output item using
+// the mapping function, and passing it downstream.
+func (m mapper[In, Out]) process(input any, next func(any)) {
+	rec := m.receive(input)
 
-		// send along
-		m.out <- output
-	}
+	// execute the transformation
+	output := m.transform(traced(m.ctx, input, rec), unwrap(input).(In))
+
+	// send along, preserving any message envelope
+	m.forward(next, input, rewrap(input, output), rec)
 }
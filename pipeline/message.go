@@ -0,0 +1,234 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Message is an envelope that carries an item through a [Flow] together with callbacks
+// used to settle the delivery of that item. Built-in stages unwrap the payload before
+// handing it to user functions, and propagate the envelope downstream so that the
+// original delivery is acknowledged only once a [piper.Sink] has consumed the item,
+// or negatively acknowledged when a stage fails to process it.
+//
+// Messages are useful for queue-backed sources that require at-least-once delivery.
+// Send them through [FromChannel] like any other item:
+//
+//	ch := make(chan pipeline.Message)
+//	go func() {
+//		for delivery := range queue.Receive() {
+//			ch <- pipeline.NewMessage(delivery.Body, delivery.Ack, delivery.Nack)
+//		}
+//		close(ch)
+//	}()
+//	pipeline.FromChannel(ch).Thru(pipeline.Map(decode)).To(sink)
+type Message struct {
+	// Payload is the item carried by the message.
+	Payload any
+	// settlement is shared by every copy of the message, ensuring it is settled only once.
+	// Messages without a settlement are created by a [Flow] to carry trace context, and are
+	// unwrapped before reaching components that are not built-in.
+	settlement *settlement
+	// ctx carries the trace context of the item.
+	ctx context.Context
+}
+
+// settlement holds the acknowledgement callbacks of a [Message].
+type settlement struct {
+	once sync.Once
+	ack  func()
+	nack func(error)
+}
+
+// NewMessage creates a new [Message] carrying the payload. The ack function is called when
+// the item has been fully processed; the nack function is called with the error that
+// prevented the item from being processed. Either function may be nil. A message is settled
+// at most once: only the first call to [Message.Ack] or [Message.Nack] has any effect.
+func NewMessage(payload any, ack func(), nack func(error)) Message {
+	return Message{
+		Payload:    payload,
+		settlement: &settlement{ack: ack, nack: nack},
+	}
+}
+
+// Ack acknowledges that the message has been fully processed.
+func (m Message) Ack() {
+	if m.settlement == nil {
+		return
+	}
+	m.settlement.once.Do(func() {
+		if m.settlement.ack != nil {
+			m.settlement.ack()
+		}
+	})
+}
+
+// Nack reports that the message could not be processed because of err.
+func (m Message) Nack(err error) {
+	if m.settlement == nil {
+		return
+	}
+	m.settlement.once.Do(func() {
+		if m.settlement.nack != nil {
+			m.settlement.nack(err)
+		}
+	})
+}
+
+// Context returns the context carried by the message. When a [Tracer] is attached to the
+// [Flow], it carries the span of the last stage that processed the item.
+func (m Message) Context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
+// WithContext returns a copy of the message carrying ctx. The copy shares the
+// acknowledgement callbacks of the original message.
+func (m Message) WithContext(ctx context.Context) Message {
+	m.ctx = ctx
+	return m
+}
+
+// WithPayload returns a copy of the message carrying a new payload. The copy shares the
+// acknowledgement callbacks of the original message.
+func (m Message) WithPayload(payload any) Message {
+	m.Payload = payload
+	return m
+}
+
+// unwrap returns the payload of item if it is a [Message], or the item itself otherwise.
+func unwrap(item any) any {
+	if msg, ok := item.(Message); ok {
+		return msg.Payload
+	}
+	return item
+}
+
+// strip returns the payload of item if it is a [Message] created by a [Flow] to carry trace
+// context. Otherwise, the item is returned as-is.
+func strip(item any) any {
+	if msg, ok := item.(Message); ok && msg.settlement == nil {
+		return msg.Payload
+	}
+	return item
+}
+
+// contextOf returns the context carried by item, if it is a [Message].
+func contextOf(item any) context.Context {
+	if msg, ok := item.(Message); ok {
+		return msg.Context()
+	}
+	return context.Background()
+}
+
+// traced returns a copy of ctx carrying the trace context of item as received by a stage,
+// if any. Otherwise, ctx is returned as-is.
+func traced(ctx context.Context, item any, r receipt) context.Context {
+	trace := r.ctx
+	if trace == nil {
+		msg, ok := item.(Message)
+		if !ok || msg.ctx == nil {
+			return ctx
+		}
+		trace = msg.ctx
+	}
+	return tracedContext{Context: ctx, trace: trace}
+}
+
+// tracedContext is a context looking up values in the trace context of an item first, such
+// as its span, and done with the context it wraps.
+type tracedContext struct {
+	context.Context
+	trace context.Context
+}
+
+func (c tracedContext) Value(key any) any {
+	if value := c.trace.Value(key); value != nil {
+		return value
+	}
+	return c.Context.Value(key)
+}
+
+// rewrap carries payload in the envelope of src if src is a [Message].
+// Otherwise, payload is returned as-is.
+func rewrap(src any, payload any) any {
+	if msg, ok := src.(Message); ok {
+		return msg.WithPayload(payload)
+	}
+	return payload
+}
+
+// ack acknowledges item if it is a [Message].
+func ack(item any) {
+	if msg, ok := item.(Message); ok {
+		msg.Ack()
+	}
+}
+
+// nack negatively acknowledges item if it is a [Message].
+func nack(item any, err error) {
+	if msg, ok := item.(Message); ok {
+		msg.Nack(err)
+	}
+}
+
+// split carries each payload in a new envelope derived from src. The envelope of src is
+// acknowledged once every derived envelope is acknowledged, and negatively acknowledged as soon
+// as any of them is. If src is not a [Message], the payloads are returned as-is.
+func split[T any](src any, payloads []T) []any {
+	items := make([]any, len(payloads))
+	msg, ok := src.(Message)
+	if !ok {
+		for i, payload := range payloads {
+			items[i] = payload
+		}
+		return items
+	}
+	if len(payloads) == 0 {
+		// nothing left to process
+		msg.Ack()
+		return items
+	}
+	var pending atomic.Int64
+	pending.Store(int64(len(payloads)))
+	for i, payload := range payloads {
+		items[i] = NewMessage(payload,
+			func() {
+				if pending.Add(-1) == 0 {
+					msg.Ack()
+				}
+			},
+			msg.Nack,
+		).WithContext(msg.ctx)
+	}
+	return items
+}
+
+// merge carries payload in a new envelope that settles every [Message] in srcs.
+// If none of srcs are messages, the payload is returned as-is.
+func merge(srcs []any, payload any) any {
+	msgs := make([]Message, 0, len(srcs))
+	for _, src := range srcs {
+		if msg, ok := src.(Message); ok {
+			msgs = append(msgs, msg)
+		}
+	}
+	if len(msgs) == 0 {
+		return payload
+	}
+	return NewMessage(payload,
+		func() {
+			for _, msg := range msgs {
+				msg.Ack()
+			}
+		},
+		func(err error) {
+			for _, msg := range msgs {
+				msg.Nack(err)
+			}
+		},
+	)
+}
@@ -0,0 +1,228 @@
+package pipeline_test
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// Deliveries records the settlement of messages created by a test.
+type Deliveries struct {
+	mu     sync.Mutex
+	acked  []int
+	nacked []int
+}
+
+func (d *Deliveries) Message(id int, payload any) pipeline.Message {
+	return pipeline.NewMessage(payload,
+		func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.acked = append(d.acked, id)
+		},
+		func(error) {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.nacked = append(d.nacked, id)
+		},
+	)
+}
+
+func (d *Deliveries) Messages(payloads ...int) []pipeline.Message {
+	msgs := make([]pipeline.Message, len(payloads))
+	for i, payload := range payloads {
+		msgs[i] = d.Message(payload, payload)
+	}
+	return msgs
+}
+
+func (d *Deliveries) Acked() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]int{}, d.acked...)
+}
+
+func (d *Deliveries) Nacked() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]int{}, d.nacked...)
+}
+
+func TestMessage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("settles once", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			msg        = deliveries.Message(1, "hello")
+		)
+
+		msg.Ack()
+		msg.WithPayload("world").Ack()
+		msg.Nack(errors.New("too late"))
+
+		if got, want := deliveries.Acked(), []int{1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("acked %v, want %v", got, want)
+		}
+		if got := deliveries.Nacked(); len(got) != 0 {
+			t.Errorf("nacked %v, want none", got)
+		}
+	})
+
+	t.Run("acks after the sink consumes the item", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			source     = pipeline.FromSlice(deliveries.Messages(1, 2, 3, 4)...)
+			double     = pipeline.Map(func(i int) int { return i * 2 })
+			got        = Consume[int](source.Thru(double))
+		)
+
+		if want := []int{2, 4, 6, 8}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := deliveries.Acked(), []int{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+			t.Errorf("acked %v, want %v", got, want)
+		}
+	})
+
+	t.Run("acks items dropped by a filter", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			source     = pipeline.FromSlice(deliveries.Messages(1, 2, 3, 4)...)
+			isEven     = pipeline.Filter(func(i int) bool { return i%2 == 0 })
+			got        = Consume[int](source.Thru(isEven))
+		)
+
+		if want := []int{2, 4}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got := deliveries.Acked(); len(got) != 4 {
+			t.Errorf("acked %v, want all items", got)
+		}
+	})
+
+	t.Run("acks flat mapped items when all children ack", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			source     = pipeline.FromSlice(deliveries.Message(1, ""), deliveries.Message(2, "abc"))
+			chars      = pipeline.FlatMap(func(s string) []rune { return []rune(s) })
+			pipe       = source.Thru(chars)
+			first      = <-pipe.Out()
+		)
+
+		// the empty string produces no children, so it is acknowledged immediately.
+		first.(pipeline.Message).Ack()
+		if got := deliveries.Acked(); !reflect.DeepEqual(got, []int{1}) {
+			t.Errorf("acked %v after first child, want [1]", got)
+		}
+
+		for item := range pipe.Out() {
+			item.(pipeline.Message).Ack()
+		}
+
+		if got := deliveries.Acked(); !reflect.DeepEqual(got, []int{1, 2}) {
+			t.Errorf("acked %v, want [1 2]", got)
+		}
+	})
+
+	t.Run("acks batch members when the batch acks", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			source     = pipeline.FromSlice(deliveries.Messages(1, 2, 3)...)
+			got        = Consume[[]int](source.Thru(pipeline.BatchN[int](2)))
+		)
+
+		if want := [][]int{{1, 2}, {3}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := deliveries.Acked(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("acked %v, want %v", got, want)
+		}
+	})
+
+	t.Run("acks items leaving a sliding window when the window acks", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			source     = pipeline.FromSlice(deliveries.Messages(1, 2, 3, 4)...)
+			pipe       = source.Thru(pipeline.SlidingWindow[int](func(o *pipeline.SlidingWindowOptions) {
+				o.WindowSize = 3
+				o.StepSize = 1
+			}))
+			first = (<-pipe.Out()).(pipeline.Message)
+		)
+
+		if got, want := first.Payload, []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		if got := deliveries.Acked(); len(got) != 0 {
+			t.Errorf("acked %v before the window, want none", got)
+		}
+
+		// only the first item leaves the window; the others are still part of the next one
+		first.Ack()
+		if got, want := deliveries.Acked(), []int{1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("acked %v after the first window, want %v", got, want)
+		}
+
+		for item := range pipe.Out() {
+			item.(pipeline.Message).Ack()
+		}
+
+		if got, want := deliveries.Acked(), []int{1, 2, 3, 4}; !reflect.DeepEqual(got, want) {
+			t.Errorf("acked %v, want %v", got, want)
+		}
+	})
+
+	t.Run("nacks items that fail", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			source     = pipeline.FromSlice(deliveries.Message(1, "hello"))
+			action     = pipeline.ExecCmd(EchoCommand("", errors.New("an error")))
+			got        = Consume[string](source.Thru(action))
+		)
+
+		if len(got) != 0 {
+			t.Errorf("got %v, want none", got)
+		}
+		if got, want := deliveries.Nacked(), []int{1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("nacked %v, want %v", got, want)
+		}
+	})
+	t.Run("acks teed items when both branches ack", func(t *testing.T) {
+		var (
+			deliveries  = &Deliveries{}
+			errOdd      = errors.New("odd")
+			source      = pipeline.FromSlice(deliveries.Messages(1, 2)...)
+			left, right = source.Tee(pipeline.Passthrough(), pipeline.Passthrough())
+			done        = make(chan struct{})
+			msgs        []pipeline.Message
+		)
+
+		go func() {
+			defer close(done)
+			for item := range right.Out() {
+				msgs = append(msgs, item.(pipeline.Message))
+			}
+		}()
+		// the left branch acknowledges every item before the right branch settles them
+		Consume[int](left)
+		<-done
+		for _, msg := range msgs {
+			if msg.Payload.(int)%2 == 1 {
+				msg.Nack(errOdd)
+			} else {
+				msg.Ack()
+			}
+		}
+
+		if got, want := deliveries.Acked(), []int{2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("acked %v, want %v", got, want)
+		}
+		if got, want := deliveries.Nacked(), []int{1}; !reflect.DeepEqual(got, want) {
+			t.Errorf("nacked %v, want %v", got, want)
+		}
+	})
+}
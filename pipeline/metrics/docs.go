@@ -0,0 +1,12 @@
+// Package metrics exports the stage metrics of pipeline flows in the Prometheus text
+// exposition format. It has no dependency on the Prometheus client libraries: attach a
+// [pipeline.Collector] to each [pipeline.Flow], register it with a [Handler] under the name
+// of the flow, and serve the handler on a scrape endpoint.
+//
+//	collector := pipeline.NewCollector()
+//	handler := metrics.NewHandler()
+//	handler.Register("orders", collector)
+//	http.Handle("/metrics", handler)
+//
+//	pipeline.FromChannel(orders).WithObserver(collector).Thru(...).To(...)
+package metrics
@@ -0,0 +1,215 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// Snapshotter provides point-in-time stage metrics, such as a [pipeline.Collector].
+type Snapshotter interface {
+	Snapshot() []pipeline.StageStats
+}
+
+// HandlerOptions configure how a [Handler] exposes metrics.
+type HandlerOptions struct {
+	// Namespace is the prefix of every exported metric name.
+	Namespace string
+}
+
+// Handler is an [http.Handler] serving the metrics of registered flows in the
+// Prometheus text exposition format. Each sample is labeled with the flow and stage names.
+type Handler struct {
+	options HandlerOptions
+	mu      sync.RWMutex
+	flows   map[string]Snapshotter
+}
+
+// NewHandler creates a new [Handler], configured with the provided option functions.
+func NewHandler(opts ...func(*HandlerOptions)) *Handler {
+	options := HandlerOptions{
+		Namespace: "piper",
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Handler{
+		options: options,
+		flows:   make(map[string]Snapshotter),
+	}
+}
+
+// Register exposes the metrics of the flow under the given name, replacing any
+// flow previously registered with that name.
+func (h *Handler) Register(flow string, s Snapshotter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flows[flow] = s
+}
+
+// Unregister stops exposing the metrics of the named flow.
+func (h *Handler) Unregister(flow string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.flows, flow)
+}
+
+// ServeHTTP implements [http.Handler], writing the metrics of every registered flow.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	h.WriteTo(w)
+}
+
+// WriteTo writes the metrics of every registered flow to w in the Prometheus text exposition format.
+func (h *Handler) WriteTo(w io.Writer) (int64, error) {
+	var (
+		samples = h.collect()
+		buf     = bufio.NewWriter(w)
+		counter = &countingWriter{w: buf}
+	)
+
+	for _, family := range families {
+		name := h.options.Namespace + "_" + family.name
+		fmt.Fprintf(counter, "# HELP %s %s\n", name, family.help)
+		fmt.Fprintf(counter, "# TYPE %s %s\n", name, family.kind)
+		for _, sample := range samples {
+			family.write(counter, name, sample)
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return counter.n, err
+	}
+	return counter.n, counter.err
+}
+
+// sample holds the metrics of a single stage of a flow.
+type sample struct {
+	labels string
+	stats  pipeline.StageStats
+}
+
+// collect takes a snapshot of each registered flow, ordered by flow and stage name.
+func (h *Handler) collect() []sample {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, 0, len(h.flows))
+	for name := range h.flows {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	samples := make([]sample, 0)
+	for _, flow := range names {
+		for _, stats := range h.flows[flow].Snapshot() {
+			samples = append(samples, sample{
+				labels: fmt.Sprintf(`flow="%s",stage="%s"`, escape(flow), escape(stats.Name)),
+				stats:  stats,
+			})
+		}
+	}
+	return samples
+}
+
+// family describes a metric family exposed by the [Handler].
+type family struct {
+	name  string
+	help  string
+	kind  string
+	write func(w io.Writer, name string, s sample)
+}
+
+// families lists the exposed metric families, in exposition order.
+var families = []family{
+	counter("stage_received_total", "Number of items received by the stage.",
+		func(s pipeline.StageStats) float64 { return float64(s.Received) }),
+	counter("stage_emitted_total", "Number of items whose output was sent downstream by the stage.",
+		func(s pipeline.StageStats) float64 { return float64(s.Emitted) }),
+	counter("stage_dropped_total", "Number of items discarded by the stage.",
+		func(s pipeline.StageStats) float64 { return float64(s.Dropped) }),
+	counter("stage_errors_total", "Number of items the stage failed to process.",
+		func(s pipeline.StageStats) float64 { return float64(s.Errors) }),
+	counter("stage_failures_total", "Number of failures of the stage outside the processing of any item.",
+		func(s pipeline.StageStats) float64 { return float64(s.Failures) }),
+	counter("stage_blocked_seconds_total", "Time the stage spent waiting for a downstream receiver.",
+		func(s pipeline.StageStats) float64 { return s.Blocked.Seconds() }),
+	{
+		name: "stage_in_flight",
+		help: "Number of items held by the stage that are not yet emitted, dropped or failed.",
+		kind: "gauge",
+		write: func(w io.Writer, name string, s sample) {
+			fmt.Fprintf(w, "%s{%s} %s\n", name, s.labels, format(float64(s.stats.InFlight)))
+		},
+	},
+	{
+		name:  "stage_latency_seconds",
+		help:  "Processing latency of the items emitted by the stage.",
+		kind:  "histogram",
+		write: writeHistogram,
+	},
+}
+
+// counter describes a counter family whose value is extracted from the stage stats.
+func counter(name, help string, value func(pipeline.StageStats) float64) family {
+	return family{
+		name: name,
+		help: help,
+		kind: "counter",
+		write: func(w io.Writer, name string, s sample) {
+			fmt.Fprintf(w, "%s{%s} %s\n", name, s.labels, format(value(s.stats)))
+		},
+	}
+}
+
+// writeHistogram writes the cumulative buckets, sum and count of the stage latency histogram.
+func writeHistogram(w io.Writer, name string, s sample) {
+	var (
+		latency    = s.stats.Latency
+		cumulative uint64
+	)
+	for i, bound := range latency.Buckets {
+		cumulative += latency.Counts[i]
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, s.labels, format(bound.Seconds()), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, s.labels, latency.Count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, s.labels, format(latency.Sum.Seconds()))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, s.labels, latency.Count)
+}
+
+// format formats a sample value.
+func format(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// labelEscaper escapes label values as required by the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape escapes a label value.
+func escape(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// countingWriter counts the bytes written, and remembers the first error encountered.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
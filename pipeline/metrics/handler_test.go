@@ -0,0 +1,104 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/metrics"
+)
+
+type StaticSnapshot []pipeline.StageStats
+
+func (s StaticSnapshot) Snapshot() []pipeline.StageStats { return s }
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("writes exposition format", func(t *testing.T) {
+		var (
+			handler  = metrics.NewHandler()
+			recorder = httptest.NewRecorder()
+			request  = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		)
+
+		handler.Register(`orders "eu"`, StaticSnapshot{
+			{
+				Name:     "map",
+				Received: 4,
+				Emitted:  3,
+				Dropped:  0,
+				Errors:   1,
+				Failures: 1,
+				InFlight: 0,
+				Blocked:  1500 * time.Millisecond,
+				Latency: pipeline.Histogram{
+					Buckets: []time.Duration{time.Millisecond, time.Second},
+					Counts:  []uint64{2, 1, 0},
+					Count:   3,
+					Sum:     250 * time.Millisecond,
+				},
+			},
+		})
+
+		handler.ServeHTTP(recorder, request)
+
+		if got := recorder.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+			t.Errorf("got content type %q", got)
+		}
+
+		body := recorder.Body.String()
+		for _, want := range []string{
+			"# TYPE piper_stage_received_total counter\n",
+			`piper_stage_received_total{flow="orders \"eu\"",stage="map"} 4` + "\n",
+			`piper_stage_errors_total{flow="orders \"eu\"",stage="map"} 1` + "\n",
+			`piper_stage_failures_total{flow="orders \"eu\"",stage="map"} 1` + "\n",
+			`piper_stage_blocked_seconds_total{flow="orders \"eu\"",stage="map"} 1.5` + "\n",
+			"# TYPE piper_stage_in_flight gauge\n",
+			"# TYPE piper_stage_latency_seconds histogram\n",
+			`piper_stage_latency_seconds_bucket{flow="orders \"eu\"",stage="map",le="0.001"} 2` + "\n",
+			`piper_stage_latency_seconds_bucket{flow="orders \"eu\"",stage="map",le="1"} 3` + "\n",
+			`piper_stage_latency_seconds_bucket{flow="orders \"eu\"",stage="map",le="+Inf"} 3` + "\n",
+			`piper_stage_latency_seconds_sum{flow="orders \"eu\"",stage="map"} 0.25` + "\n",
+			`piper_stage_latency_seconds_count{flow="orders \"eu\"",stage="map"} 3` + "\n",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("missing %q in:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("exports collected flows", func(t *testing.T) {
+		var (
+			collector = pipeline.NewCollector()
+			handler   = metrics.NewHandler(func(ho *metrics.HandlerOptions) {
+				ho.Namespace = "app"
+			})
+			sink = pipeline.ToSlice[int]()
+		)
+
+		handler.Register("numbers", collector)
+		pipeline.FromSlice(1, 2, 3).WithObserver(collector).To(sink)
+		sink.Slice()
+
+		var body strings.Builder
+		if _, err := handler.WriteTo(&body); err != nil {
+			t.Fatal(err)
+		}
+
+		want := `app_stage_received_total{flow="numbers",stage="slice"} 3`
+		if !strings.Contains(body.String(), want) {
+			t.Errorf("missing %q in:\n%s", want, body.String())
+		}
+
+		handler.Unregister("numbers")
+		body.Reset()
+		handler.WriteTo(&body)
+		if strings.Contains(body.String(), `flow="numbers"`) {
+			t.Errorf("unregistered flow still exported:\n%s", body.String())
+		}
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 // muxer implements a pipeline source that combines multiple input sources into a single output stream.
 type muxer struct {
+	*stage
 	// out is the channel where combined data from all sources is sent
 	out chan any
 	// sources is the collection of input sources to read from
@@ -19,11 +20,16 @@ type muxer struct {
 // Data from all sources is interleaved into a single source stream.
 func Mux(sources ...piper.Source) Flow {
 	fanin := muxer{
-		out: make(chan any),
+		stage: newStage("mux"),
+		out:   make(chan any),
 	}
 	fanin.sources = append(fanin.sources, sources...)
 	go fanin.start()
-	return From(fanin)
+	flow := From(fanin)
+	for _, source := range sources {
+		flow.adopt(source)
+	}
+	return flow
 }
 
 // Out returns the channel containing the combined output from all sources.
@@ -33,6 +39,7 @@ func (m muxer) Out() <-chan any { return m.out }
 // It continues until all sources are exhausted.
 func (m muxer) start() {
 	defer close(m.out)
+	defer m.finish()
 	var (
 		sources = m.sources
 	)
@@ -43,6 +50,6 @@ func (m muxer) start() {
 			sources = slices.Delete(sources, idx, idx+1)
 			continue
 		}
-		m.out <- output
+		m.emit(m.out, output, output, m.receive(output))
 	}
 }
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"github.com/nisimpson/piper"
+)
+
+// Namer is implemented by pipeline components that have a name. The name identifies the
+// component in a [Graph], in the registry of its [Flow], and in the events reported to the
+// instruments of the flow.
+//
+// Built-in components implement Namer with a default name describing them, such as
+// "map", "batch(10)" or "http POST /path". Use [Named] to replace it.
+type Namer interface {
+	// Name returns the name of the component.
+	Name() string
+}
+
+// namedPipe is a [piper.Pipe] with a name given by [Named].
+type namedPipe struct {
+	piper.Pipe
+	// name is the name of the pipe.
+	name string
+}
+
+// Named returns pipe with the given name, replacing its default name if it is
+// a built-in component.
+func Named(name string, pipe piper.Pipe) piper.Pipe {
+	if s, ok := pipe.(interface{ rename(string) }); ok {
+		s.rename(name)
+	}
+	named := namedPipe{Pipe: pipe, name: name}
+	if _, ok := pipe.(instrumentable); ok {
+		return instrumentedPipe{named}
+	}
+	return named
+}
+
+// Name implements [Namer].
+func (p namedPipe) Name() string { return p.name }
+
+// instrumentedPipe is a [namedPipe] wrapping a built-in component. Only built-in components
+// accept the instruments of a [Flow] and the envelopes carrying trace context, so custom pipes
+// are wrapped by a namedPipe alone.
+type instrumentedPipe struct {
+	namedPipe
+}
+
+// instrument implements instrumentable, attaching the instruments to the named pipe.
+func (p instrumentedPipe) instrument(i instruments) {
+	p.Pipe.(instrumentable).instrument(i)
+}
+
+// inlet implements composite, returning the named pipe.
+func (p instrumentedPipe) inlet() piper.Inlet { return p.Pipe }
+
+// fusion implements fusable, returning the fusion running the named pipe, if any.
+func (p namedPipe) fusion() *fusion { return fusionOf(p.Pipe) }
+
+// status implements reporter, reporting the status of the named pipe.
+func (p namedPipe) status() StageStatus {
+	if r, ok := p.Pipe.(reporter); ok {
+		return r.status()
+	}
+	return StageStatus{Name: p.name}
+}
@@ -0,0 +1,141 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+)
+
+func TestNamer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		pipe piper.Pipe
+	}{
+		{name: "map", pipe: pipeline.Map(func(i int) int { return i })},
+		{name: "filter", pipe: pipeline.KeepIf(func(i int) bool { return true })},
+		{name: "batch(10)", pipe: pipeline.BatchN[int](10)},
+		{name: "batch(1s)", pipe: pipeline.BatchEvery[int](time.Second)},
+		{name: "batch", pipe: pipeline.BatchAll[int]()},
+		{name: "take(3)", pipe: pipeline.TakeN(3)},
+		{name: "take_last(3)", pipe: pipeline.TakeLastN(3)},
+		{name: "drop(2)", pipe: pipeline.DropN(2)},
+		{name: "http POST /path", pipe: pipeline.SendHTTP(http.MethodPost, "http://example.com/path?q=1")},
+		{name: "sliding_window(3, 1)", pipe: pipeline.SlidingWindow[int](func(so *pipeline.SlidingWindowOptions) {
+			so.WindowSize = 3
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer close(tt.pipe.In())
+			namer, ok := tt.pipe.(pipeline.Namer)
+			if !ok {
+				t.Fatalf("%T does not implement Namer", tt.pipe)
+			}
+			if got := namer.Name(); got != tt.name {
+				t.Errorf("got %q, want %q", got, tt.name)
+			}
+		})
+	}
+}
+
+func TestNamed(t *testing.T) {
+	t.Parallel()
+
+	t.Run("renames built-in pipes", func(t *testing.T) {
+		var (
+			collector = pipeline.NewCollector()
+			double    = pipeline.Named("double", pipeline.Map(func(i int) int { return i * 2 }))
+			flow      = pipeline.FromSlice(1, 2).WithObserver(collector).Thru(double)
+		)
+
+		if got := double.(pipeline.Namer).Name(); got != "double" {
+			t.Errorf("got name %q", got)
+		}
+
+		Consume[int](flow)
+
+		if got := flow.Graph().Nodes[1].Name; got != "double" {
+			t.Errorf("got node %q, want %q", got, "double")
+		}
+
+		Eventually(t, func() bool {
+			stats := collector.Snapshot()
+			return len(stats) == 1 && stats[0].Name == "double" && stats[0].Emitted == 2
+		})
+	})
+
+	t.Run("names custom pipes", func(t *testing.T) {
+		var (
+			pipe = pipeline.Named("custom", NamelessPipe{Pipe: pipeline.Passthrough()})
+			flow = pipeline.FromSlice(1).Thru(pipe)
+			got  = Consume[int](flow)
+		)
+
+		if len(got) != 1 {
+			t.Errorf("got %v", got)
+		}
+
+		status, ok := flow.Stage("custom")
+		if !ok || status.State != pipeline.StageUnknown || status.Kind != pipeline.PipeNode {
+			t.Errorf("got status %+v", status)
+		}
+	})
+	t.Run("passes items to custom pipes under a tracer", func(t *testing.T) {
+		var (
+			in     = make(chan any)
+			out    = make(chan any)
+			tracer = &FakeTracer{}
+			types  []string
+		)
+		go func() {
+			defer close(out)
+			for item := range in {
+				types = append(types, fmt.Sprintf("%T", item))
+				out <- item
+			}
+		}()
+
+		var (
+			pipe = pipeline.Named("custom", CustomPipe{in: in, out: out})
+			flow = pipeline.FromSlice(1, 2).WithTracer(tracer).Thru(pipeline.Map(func(i int) int { return i }), pipe)
+			got  = Consume[int](flow)
+		)
+
+		if want := []int{1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if want := []string{"int", "int"}; !reflect.DeepEqual(types, want) {
+			t.Errorf("custom pipe received %v, want %v", types, want)
+		}
+	})
+}
+
+// CustomPipe is a pipe reading from and writing to its channels.
+type CustomPipe struct {
+	in  chan any
+	out chan any
+}
+
+func (p CustomPipe) In() chan<- any  { return p.in }
+func (p CustomPipe) Out() <-chan any { return p.out }
+
+// Doubler returns a custom pipe doubling each int it receives. It panics if it receives
+// anything else, such as an envelope carrying trace context.
+func Doubler() CustomPipe {
+	pipe := CustomPipe{in: make(chan any), out: make(chan any)}
+	go func() {
+		defer close(pipe.out)
+		for item := range pipe.in {
+			pipe.out <- item.(int) * 2
+		}
+	}()
+	return pipe
+}
@@ -7,6 +7,7 @@ import (
 // nullSink implements a pipeline sink that discards all received items.
 // It provides synchronization capabilities to wait for all items to be processed.
 type nullSink struct {
+	*stage
 	// wg is used to signal when all items have been processed
 	wg *sync.WaitGroup
 	// in receives items to be discarded
@@ -17,8 +18,9 @@ type nullSink struct {
 // This is useful when you want to execute a pipeline but don't need its output.
 func ToNull() nullSink {
 	sink := nullSink{
-		in: make(chan any),
-		wg: &sync.WaitGroup{},
+		stage: newStage("null"),
+		in:    make(chan any),
+		wg:    &sync.WaitGroup{},
 	}
 	sink.wg.Add(1)
 	go sink.start()
@@ -38,7 +40,11 @@ func (nullSink) noop(any) {}
 // It signals completion through the WaitGroup when all items have been processed.
 func (n nullSink) start() {
 	defer n.wg.Done()
+	defer n.finish()
 	for i := range n.in {
+		rec := n.receive(i)
 		n.noop(i)
+		n.emitted(i, rec, rec.elapsed())
+		ack(i)
 	}
 }
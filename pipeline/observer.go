@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Observer receives instrumentation events from the stages of a [Flow].
+// Attach an observer with [Flow.WithObserver]; every built-in stage added to the flow
+// afterwards reports to it. Each item received by a stage is eventually reported
+// exactly once as emitted, dropped or failed. Failures of a stage outside the processing
+// of any item, such as a source failing to read its input, are reported apart from items.
+//
+// Observers are called synchronously from the stage goroutines, and must be safe for concurrent use.
+type Observer interface {
+	// OnReceive is called when a stage receives an item.
+	OnReceive(stage string, item any)
+	// OnEmit is called when the output of an item has been sent downstream. Latency is the
+	// time between receiving the item and sending its output, excluding any time blocked.
+	OnEmit(stage string, item any, latency time.Duration)
+	// OnDrop is called when a stage discards an item without sending any output.
+	OnDrop(stage string, item any)
+	// OnError is called when a stage fails to process an item.
+	OnError(stage string, err error)
+	// OnFailure is called when a stage fails outside the processing of any item, such as a
+	// source failing to read its input. It is not paired with a received item.
+	OnFailure(stage string, err error)
+	// OnBlocked is called when a stage was blocked for duration d waiting for a downstream receiver.
+	OnBlocked(stage string, d time.Duration)
+}
+
+// NopObserver is an [Observer] that ignores every event. Embed it to implement
+// only the events of interest.
+type NopObserver struct{}
+
+func (NopObserver) OnReceive(string, any)             {}
+func (NopObserver) OnEmit(string, any, time.Duration) {}
+func (NopObserver) OnDrop(string, any)                {}
+func (NopObserver) OnError(string, error)             {}
+func (NopObserver) OnFailure(string, error)           {}
+func (NopObserver) OnBlocked(string, time.Duration)   {}
+
+// DefaultLatencyBuckets are the upper bounds of the latency histogram buckets used by a [Collector].
+var DefaultLatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// CollectorOptions configure how a [Collector] aggregates stage events.
+type CollectorOptions struct {
+	// Buckets are the upper bounds of the latency histogram buckets, in increasing order.
+	Buckets []time.Duration
+}
+
+// Histogram is a snapshot of a latency distribution.
+type Histogram struct {
+	// Buckets are the upper bounds of each bucket.
+	Buckets []time.Duration
+	// Counts holds the number of observations in each bucket. It has one more element than
+	// Buckets, counting the observations above the last upper bound.
+	Counts []uint64
+	// Count is the total number of observations.
+	Count uint64
+	// Sum is the sum of all observations.
+	Sum time.Duration
+}
+
+// observe records a single observation.
+func (h *Histogram) observe(d time.Duration) {
+	idx := sort.Search(len(h.Buckets), func(i int) bool { return d <= h.Buckets[i] })
+	h.Counts[idx]++
+	h.Count++
+	h.Sum += d
+}
+
+// StageStats is a snapshot of the metrics collected for a named stage.
+type StageStats struct {
+	// Name is the name of the stage.
+	Name string
+	// Received is the number of items received by the stage.
+	Received uint64
+	// Emitted is the number of items whose output was sent downstream.
+	Emitted uint64
+	// Dropped is the number of items discarded by the stage.
+	Dropped uint64
+	// Errors is the number of items the stage failed to process.
+	Errors uint64
+	// Failures is the number of failures of the stage outside the processing of any item.
+	Failures uint64
+	// InFlight is the number of items received but not yet emitted, dropped or failed;
+	// that is, the depth of the queue held by the stage.
+	InFlight int64
+	// Blocked is the total time the stage spent waiting for a downstream receiver.
+	Blocked time.Duration
+	// Latency is the distribution of processing latencies of emitted items.
+	Latency Histogram
+}
+
+// Collector is an [Observer] that aggregates stage events in memory. Stages sharing
+// the same name are aggregated together. Use [Collector.Snapshot] to export the metrics.
+type Collector struct {
+	mu      sync.Mutex
+	buckets []time.Duration
+	stages  map[string]*StageStats
+}
+
+// NewCollector creates a new [Collector], configured with the provided option functions.
+func NewCollector(opts ...func(*CollectorOptions)) *Collector {
+	options := CollectorOptions{
+		Buckets: DefaultLatencyBuckets,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return &Collector{
+		buckets: slices.Clone(options.Buckets),
+		stages:  make(map[string]*StageStats),
+	}
+}
+
+// stats returns the metrics of the named stage, creating them if necessary.
+// The caller must hold the collector lock.
+func (c *Collector) stats(name string) *StageStats {
+	stats, ok := c.stages[name]
+	if !ok {
+		stats = &StageStats{
+			Name: name,
+			Latency: Histogram{
+				Buckets: c.buckets,
+				Counts:  make([]uint64, len(c.buckets)+1),
+			},
+		}
+		c.stages[name] = stats
+	}
+	return stats
+}
+
+// OnReceive implements [Observer].
+func (c *Collector) OnReceive(stage string, _ any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats(stage)
+	stats.Received++
+	stats.InFlight++
+}
+
+// OnEmit implements [Observer].
+func (c *Collector) OnEmit(stage string, _ any, latency time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats(stage)
+	stats.Emitted++
+	stats.InFlight--
+	stats.Latency.observe(latency)
+}
+
+// OnDrop implements [Observer].
+func (c *Collector) OnDrop(stage string, _ any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats(stage)
+	stats.Dropped++
+	stats.InFlight--
+}
+
+// OnError implements [Observer].
+func (c *Collector) OnError(stage string, _ error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats(stage)
+	stats.Errors++
+	stats.InFlight--
+}
+
+// OnFailure implements [Observer]. Failures do not change the number of items in flight.
+func (c *Collector) OnFailure(stage string, _ error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats(stage).Failures++
+}
+
+// OnBlocked implements [Observer].
+func (c *Collector) OnBlocked(stage string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats(stage).Blocked += d
+}
+
+// Snapshot returns a copy of the metrics collected so far, sorted by stage name.
+func (c *Collector) Snapshot() []StageStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := make([]StageStats, 0, len(c.stages))
+	for _, stats := range c.stages {
+		copied := *stats
+		copied.Latency.Counts = slices.Clone(stats.Latency.Counts)
+		snapshot = append(snapshot, copied)
+	}
+	slices.SortFunc(snapshot, func(a, b StageStats) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return snapshot
+}
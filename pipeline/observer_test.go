@@ -0,0 +1,152 @@
+package pipeline_test
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// Eventually polls the condition until it returns true, failing the test after a second.
+func Eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Counts summarizes the counters of a [pipeline.StageStats].
+type Counts struct {
+	Received, Emitted, Dropped, Errors, Failures uint64
+	InFlight                                     int64
+}
+
+func CountsOf(snapshot []pipeline.StageStats) map[string]Counts {
+	counts := make(map[string]Counts)
+	for _, stats := range snapshot {
+		counts[stats.Name] = Counts{
+			Received: stats.Received,
+			Emitted:  stats.Emitted,
+			Dropped:  stats.Dropped,
+			Errors:   stats.Errors,
+			Failures: stats.Failures,
+			InFlight: stats.InFlight,
+		}
+	}
+	return counts
+}
+
+func TestCollector(t *testing.T) {
+	t.Parallel()
+
+	t.Run("counts stage events", func(t *testing.T) {
+		var (
+			collector = pipeline.NewCollector()
+			sink      = pipeline.ToSlice[int]()
+			double    = pipeline.Map(func(i int) int { return i * 2 })
+			large     = pipeline.Filter(func(i int) bool { return i > 4 })
+		)
+
+		pipeline.FromSlice(1, 2, 3, 4).
+			WithObserver(collector).
+			Thru(double, large).
+			To(sink)
+
+		if got, want := sink.Slice(), []int{6, 8}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+
+		want := map[string]Counts{
+			"map":    {Received: 4, Emitted: 4},
+			"filter": {Received: 4, Emitted: 2, Dropped: 2},
+			"slice":  {Received: 2, Emitted: 2},
+		}
+
+		Eventually(t, func() bool {
+			return reflect.DeepEqual(CountsOf(collector.Snapshot()), want)
+		})
+	})
+
+	t.Run("counts errors", func(t *testing.T) {
+		var (
+			collector = pipeline.NewCollector()
+			action    = pipeline.ExecCmd(EchoCommand("", errors.New("an error")))
+		)
+
+		pipeline.FromSlice("a", "b").
+			WithObserver(collector).
+			Thru(action).
+			To(pipeline.ToNull())
+
+		want := map[string]Counts{
+			"command": {Received: 2, Errors: 2},
+		}
+
+		Eventually(t, func() bool {
+			return reflect.DeepEqual(CountsOf(collector.Snapshot()), want)
+		})
+	})
+
+	t.Run("counts failures apart from items", func(t *testing.T) {
+		var (
+			collector = pipeline.NewCollector()
+			reader    = io.MultiReader(strings.NewReader("a\nb\n"), iotest.ErrReader(errors.New("an error")))
+		)
+
+		pipeline.FromReader(reader, nil).
+			WithObserver(collector).
+			To(pipeline.ToNull())
+
+		want := map[string]Counts{
+			"reader": {Received: 2, Emitted: 2, Failures: 1},
+			"null":   {Received: 2, Emitted: 2},
+		}
+
+		Eventually(t, func() bool {
+			return reflect.DeepEqual(CountsOf(collector.Snapshot()), want)
+		})
+	})
+
+	t.Run("tracks latency and blocked time", func(t *testing.T) {
+		var (
+			collector = pipeline.NewCollector(func(co *pipeline.CollectorOptions) {
+				co.Buckets = []time.Duration{time.Millisecond, time.Hour}
+			})
+			slow = pipeline.Map(func(i int) int {
+				time.Sleep(2 * time.Millisecond)
+				return i
+			})
+			flow = pipeline.FromSlice(1, 2).WithObserver(collector).Thru(slow)
+		)
+
+		// hold the output for a while, blocking the stage once its output is full
+		time.Sleep(20 * time.Millisecond)
+		for range flow.Out() {
+		}
+
+		Eventually(t, func() bool {
+			snapshot := collector.Snapshot()
+			return len(snapshot) == 1 && snapshot[0].Emitted == 2
+		})
+
+		stats := collector.Snapshot()[0]
+		if want := []uint64{0, 2, 0}; !reflect.DeepEqual(stats.Latency.Counts, want) {
+			t.Errorf("got latency counts %v, want %v", stats.Latency.Counts, want)
+		}
+		if stats.Latency.Sum < 4*time.Millisecond {
+			t.Errorf("got latency sum %v, want at least 4ms", stats.Latency.Sum)
+		}
+		if stats.Blocked == 0 {
+			t.Errorf("expected blocked time to be recorded")
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/nisimpson/piper"
@@ -13,10 +14,10 @@ type ParallelPipeFactory = func() piper.Pipe
 // parallelizer implements a parallel processing [piper.Pipe] that distributes work
 // across multiple identical pipes running concurrently.
 type parallelizer struct {
-	in        chan any            // in is the input channel that receives data to be processed
-	out       chan any            // out is the output channel that sends processed results
-	size      int                 // size determines the number of parallel pipes to create
-	generator ParallelPipeFactory // generator is the function used to create new pipes for parallel processing
+	*stage
+	in      chan any     // in is the input channel that receives data to be processed
+	out     chan any     // out is the output channel that sends processed results
+	workers []piper.Pipe // workers are the pipes processing data in parallel, created by the ParallelPipeFactory
 }
 
 // Parallelize creates a [piper.Pipe] that processes data concurrently across
@@ -37,16 +38,30 @@ func Parallelize(size int, factory ParallelPipeFactory) piper.Pipe {
 	}
 
 	pipe := &parallelizer{
-		in:        make(chan any),
-		out:       make(chan any),
-		size:      size,
-		generator: factory,
+		stage:   newStage(fmt.Sprintf("parallelize(%d)", size)),
+		in:      make(chan any),
+		out:     make(chan any),
+		workers: make([]piper.Pipe, size),
+	}
+
+	for i := range pipe.workers {
+		pipe.workers[i] = factory()
 	}
 
 	go pipe.start()
 	return pipe
 }
 
+// instrument attaches the instruments of a [Flow] to the parallelizer and each of its workers.
+func (p parallelizer) instrument(i instruments) {
+	p.stage.instrument(i)
+	for _, worker := range p.workers {
+		if s, ok := worker.(instrumentable); ok {
+			s.instrument(i)
+		}
+	}
+}
+
 // In returns the input channel for the parallelizer.
 // This channel is used to send data into the parallel processing pipeline.
 func (p parallelizer) In() chan<- any { return p.in }
@@ -55,17 +70,18 @@ func (p parallelizer) In() chan<- any { return p.in }
 // This channel receives the results from all parallel processing pipes.
 func (p parallelizer) Out() <-chan any { return p.out }
 
-// start initializes and manages the parallel processing operation.
-// It creates the specified number of worker pipes and coordinates their execution.
+// start manages the parallel processing operation.
+// It puts each of the worker pipes to work and coordinates their execution.
 // The function ensures all workers are properly started and cleaned up.
 func (p parallelizer) start() {
 	wg := sync.WaitGroup{}
 	defer close(p.out)
+	defer p.finish()
 
-	// create size number of workers, and immediately put them to work
-	for i := 0; i < p.size; i++ {
+	// put each worker to work
+	for _, worker := range p.workers {
 		wg.Add(1)
-		go p.work(p.generator(), &wg)
+		go p.work(worker, &wg)
 	}
 
 	// wait for all work to be completed.
@@ -82,8 +98,9 @@ func (p parallelizer) work(pipe piper.Pipe, wg *sync.WaitGroup) {
 	defer wg.Done()
 	defer close(pipe.In())
 	for input := range p.in {
-		pipe.In() <- input     // process upstream input
-		output := <-pipe.Out() // get output
-		p.out <- output        // send output downstream
+		rec := p.receive(input)
+		pipe.In() <- prepare(pipe, rec.carry(input)) // process upstream input
+		output := <-pipe.Out()                       // get output
+		p.emit(p.out, input, output, rec)            // send output downstream
 	}
 }
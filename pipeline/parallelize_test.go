@@ -2,6 +2,7 @@ package pipeline_test
 
 import (
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/nisimpson/piper"
@@ -33,6 +34,18 @@ func TestParallelize(t *testing.T) {
 		}
 	})
 
+	t.Run("passes items to custom pipes under a tracer", func(t *testing.T) {
+		var (
+			pipe = pipeline.Parallelize(2, func() piper.Pipe { return Doubler() })
+			flow = pipeline.FromSlice(1, 2, 3).WithTracer(&FakeTracer{}).Thru(pipe)
+			want = []int{2, 4, 6}
+		)
+
+		if got := slices.Sorted(slices.Values(Consume[int](flow))); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
 	t.Run("no parallization", func(t *testing.T) {
 		var (
 			source = pipeline.FromSlice(1, 2, 3, 4)
@@ -5,31 +5,23 @@ import "github.com/nisimpson/piper"
 // passthroughPipe implements a pipeline component that forwards items without modification.
 // It acts as a simple relay between pipeline segments.
 type passthroughPipe struct {
-	// in receives items to be forwarded
-	in chan any
-	// out sends received items without modification
-	out chan any
+	*stage
 }
 
 // Passthrough creates a new [piper.Pipe] component that forwards items without modification.
 // This can be useful for debugging or when you need to maintain the pipeline structure without processing.
 func Passthrough() piper.Pipe {
 	pipe := passthroughPipe{
-		in:  make(chan any),
-		out: make(chan any),
+		stage: newStage("passthrough"),
 	}
-	go pipe.start()
+	fuse(pipe)
 	return pipe
 }
 
-func (p passthroughPipe) In() chan<- any  { return p.in }
-func (p passthroughPipe) Out() <-chan any { return p.out }
+func (p passthroughPipe) In() chan<- any  { return p.launch().in }
+func (p passthroughPipe) Out() <-chan any { return p.launch().out }
 
-// start begins forwarding items from the input channel to the output channel.
-// Each item is passed through unchanged.
-func (p passthroughPipe) start() {
-	defer close(p.out)
-	for v := range p.in {
-		p.out <- v
-	}
+// process implements processor, passing the input downstream unchanged.
+func (p passthroughPipe) process(input any, next func(any)) {
+	p.forward(next, input, input, p.receive(input))
 }
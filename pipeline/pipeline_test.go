@@ -40,6 +40,11 @@ func (s *Fixture[In]) consume(pipeline pipeline.Flow) []In {
 func (s *Fixture[In]) start() {
 	defer s.wg.Done()
 	for item := range s.in {
+		if msg, ok := item.(pipeline.Message); ok {
+			s.items = append(s.items, msg.Payload.(In))
+			msg.Ack()
+			continue
+		}
 		s.items = append(s.items, item.(In))
 	}
 }
@@ -0,0 +1,186 @@
+package pipetest
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// FakeClock is a manual [pipeline.Clock] for testing time-based stages instantly and
+// deterministically. Its time only moves forward with [FakeClock.Advance], firing the
+// timers and tickers that are due along the way.
+//
+// Stages create their timers once started; call [FakeClock.BlockUntil] before advancing
+// the clock, to ensure the timers under test are scheduled.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+	// waiters are the scheduled timers and tickers.
+	waiters []*fakeWaiter
+	// changed is closed and replaced whenever a waiter is scheduled.
+	changed chan struct{}
+}
+
+// NewFakeClock creates a new [FakeClock] set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+// Now implements [pipeline.Clock].
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After implements [pipeline.Clock].
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// NewTimer implements [pipeline.Clock].
+func (c *FakeClock) NewTimer(d time.Duration) pipeline.Timer {
+	w := &fakeWaiter{clock: c, c: make(chan time.Time, 1)}
+	w.Reset(d)
+	return fakeTimer{w}
+}
+
+// NewTicker implements [pipeline.Clock]. It panics if d is not positive, as [time.NewTicker] does.
+func (c *FakeClock) NewTicker(d time.Duration) pipeline.Ticker {
+	if d <= 0 {
+		panic("pipetest: non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{clock: c, c: make(chan time.Time, 1), period: d}
+	w.Reset(d)
+	return fakeTicker{w}
+}
+
+// Advance moves the clock forward by d, firing the timers and tickers due by the new time
+// in chronological order. As with [time.Ticker], ticks are dropped for slow receivers.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	end := c.now.Add(d)
+	for {
+		var next *fakeWaiter
+		for _, w := range c.waiters {
+			if !w.at.After(end) && (next == nil || w.at.Before(next.at)) {
+				next = w
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		c.now = next.at
+		select {
+		case next.c <- c.now:
+		default:
+		}
+		if next.period > 0 {
+			next.at = next.at.Add(next.period)
+		} else {
+			c.unschedule(next)
+		}
+	}
+	c.now = end
+}
+
+// Waiters returns the number of scheduled timers and tickers.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil blocks until at least n timers and tickers are scheduled.
+func (c *FakeClock) BlockUntil(n int) {
+	c.blockUntil(func() bool { return len(c.waiters) >= n })
+}
+
+// BlockUntilDue blocks until a timer or ticker is scheduled to fire at or after t. Use it to
+// wait for a stage to restart a timer, which leaves the number of scheduled timers unchanged.
+func (c *FakeClock) BlockUntilDue(t time.Time) {
+	c.blockUntil(func() bool {
+		return slices.ContainsFunc(c.waiters, func(w *fakeWaiter) bool { return !w.at.Before(t) })
+	})
+}
+
+// blockUntil blocks until ready, called with the clock locked, reports true.
+func (c *FakeClock) blockUntil(ready func() bool) {
+	for {
+		c.mu.Lock()
+		ok, changed := ready(), c.changed
+		c.mu.Unlock()
+		if ok {
+			return
+		}
+		<-changed
+	}
+}
+
+// schedule schedules the waiter to fire once d has elapsed, resetting the period of tickers to d.
+// It reports whether the waiter was already scheduled.
+func (c *FakeClock) schedule(w *fakeWaiter, d time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := c.unschedule(w)
+	if w.period == 0 && d <= 0 {
+		// as with time.Timer, timers fire immediately for non-positive durations
+		select {
+		case w.c <- c.now:
+		default:
+		}
+		return active
+	}
+	if w.period > 0 {
+		w.period = d
+	}
+	w.at = c.now.Add(d)
+	c.waiters = append(c.waiters, w)
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return active
+}
+
+// stop unschedules the waiter, reporting whether it was scheduled.
+func (c *FakeClock) stop(w *fakeWaiter) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.unschedule(w)
+}
+
+func (c *FakeClock) unschedule(w *fakeWaiter) bool {
+	i := slices.Index(c.waiters, w)
+	if i < 0 {
+		return false
+	}
+	c.waiters = slices.Delete(c.waiters, i, i+1)
+	return true
+}
+
+// fakeWaiter is a timer or ticker scheduled by a [FakeClock].
+type fakeWaiter struct {
+	clock *FakeClock
+	c     chan time.Time
+	// at is the time the waiter fires next.
+	at time.Time
+	// period is the period of a ticker, or zero for a timer.
+	period time.Duration
+}
+
+func (w *fakeWaiter) C() <-chan time.Time { return w.c }
+
+func (w *fakeWaiter) Reset(d time.Duration) bool { return w.clock.schedule(w, d) }
+
+type fakeTimer struct{ *fakeWaiter }
+
+func (t fakeTimer) Stop() bool { return t.clock.stop(t.fakeWaiter) }
+
+type fakeTicker struct{ *fakeWaiter }
+
+func (t fakeTicker) Stop()                 { t.clock.stop(t.fakeWaiter) }
+func (t fakeTicker) Reset(d time.Duration) { t.fakeWaiter.Reset(d) }
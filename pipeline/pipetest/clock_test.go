@@ -0,0 +1,141 @@
+package pipetest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline/pipetest"
+)
+
+// Fired reports whether the channel has received a time, returning it.
+func Fired(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case at := <-c:
+		return at, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("moves forward when advanced", func(t *testing.T) {
+		clock := pipetest.NewFakeClock(start)
+		clock.Advance(time.Minute)
+		if got, want := clock.Now(), start.Add(time.Minute); !got.Equal(want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("fires timers when due", func(t *testing.T) {
+		var (
+			clock = pipetest.NewFakeClock(start)
+			timer = clock.NewTimer(time.Second)
+			after = clock.After(2 * time.Second)
+		)
+
+		clock.Advance(999 * time.Millisecond)
+		if _, ok := Fired(timer.C()); ok {
+			t.Fatal("timer fired early")
+		}
+
+		clock.Advance(2 * time.Second)
+		if at, ok := Fired(timer.C()); !ok || !at.Equal(start.Add(time.Second)) {
+			t.Errorf("got %v, %v; want timer fired at %v", at, ok, start.Add(time.Second))
+		}
+		if at, ok := Fired(after); !ok || !at.Equal(start.Add(2*time.Second)) {
+			t.Errorf("got %v, %v; want after fired at %v", at, ok, start.Add(2*time.Second))
+		}
+		if n := clock.Waiters(); n != 0 {
+			t.Errorf("got %d waiters, want 0", n)
+		}
+	})
+
+	t.Run("fires timers immediately for non-positive durations", func(t *testing.T) {
+		clock := pipetest.NewFakeClock(start)
+		if _, ok := Fired(clock.After(0)); !ok {
+			t.Error("timer did not fire")
+		}
+	})
+
+	t.Run("stops and resets timers", func(t *testing.T) {
+		var (
+			clock = pipetest.NewFakeClock(start)
+			timer = clock.NewTimer(time.Second)
+		)
+
+		if !timer.Stop() {
+			t.Error("got inactive timer, want active")
+		}
+		clock.Advance(time.Second)
+		if _, ok := Fired(timer.C()); ok {
+			t.Error("stopped timer fired")
+		}
+
+		if timer.Reset(time.Second) {
+			t.Error("got active timer, want inactive")
+		}
+		clock.Advance(time.Second)
+		if _, ok := Fired(timer.C()); !ok {
+			t.Error("reset timer did not fire")
+		}
+	})
+
+	t.Run("ticks every period, dropping ticks for slow receivers", func(t *testing.T) {
+		var (
+			clock  = pipetest.NewFakeClock(start)
+			ticker = clock.NewTicker(time.Second)
+		)
+
+		clock.Advance(3 * time.Second)
+		if at, ok := Fired(ticker.C()); !ok || !at.Equal(start.Add(time.Second)) {
+			t.Errorf("got %v, %v; want tick at %v", at, ok, start.Add(time.Second))
+		}
+		if _, ok := Fired(ticker.C()); ok {
+			t.Error("got a second tick, want it dropped")
+		}
+
+		ticker.Reset(time.Minute)
+		clock.Advance(time.Second)
+		if _, ok := Fired(ticker.C()); ok {
+			t.Error("ticker fired before its new period")
+		}
+
+		ticker.Stop()
+		clock.Advance(time.Hour)
+		if _, ok := Fired(ticker.C()); ok {
+			t.Error("stopped ticker fired")
+		}
+	})
+
+	t.Run("blocks until waiters are scheduled", func(t *testing.T) {
+		clock := pipetest.NewFakeClock(start)
+		go func() {
+			clock.NewTimer(time.Second)
+			clock.NewTicker(time.Second)
+		}()
+
+		clock.BlockUntil(2)
+		if n := clock.Waiters(); n != 2 {
+			t.Errorf("got %d waiters, want 2", n)
+		}
+	})
+	t.Run("blocks until a timer is due", func(t *testing.T) {
+		var (
+			clock = pipetest.NewFakeClock(start)
+			timer = clock.NewTimer(time.Second)
+		)
+		go func() {
+			clock.Advance(time.Second)
+			timer.Reset(time.Second)
+		}()
+
+		clock.BlockUntilDue(start.Add(2 * time.Second))
+		if got, want := clock.Now(), start.Add(time.Second); !got.Equal(want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
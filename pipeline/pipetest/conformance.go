@@ -0,0 +1,181 @@
+package pipetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper"
+)
+
+// ConformanceOptions configure the suite run by [Conformance].
+type ConformanceOptions struct {
+	// Input returns the i-th item sent to the pipe. Defaults to returning i, an int.
+	Input func(i int) any
+	// Output returns the number of items the pipe sends once it received n items, checked once
+	// its output is closed. Nil skips the check, such as for pipes sending items on a timer.
+	Output func(n int) int
+	// Large is the number of items sent by the large input test. Defaults to 1000.
+	Large int
+	// Delay is the time the slow consumer waits before receiving each item. Defaults to 1ms.
+	Delay time.Duration
+	// Settle is the time the output is watched once closed, failing the test if the pipe sends
+	// an item after closing it. Defaults to 10ms.
+	Settle time.Duration
+	// Timeout is the time allowed for each test to complete. Defaults to [DefaultTimeout].
+	Timeout time.Duration
+	// Cancellable requires the pipe to close its output once the context passed to the
+	// factory is done, even while its input remains open. The early cancellation test is
+	// skipped for other pipes.
+	Cancellable bool
+}
+
+// Conformance runs the conformance suite against the pipes created by the factory, verifying
+// the rules every [piper.Pipe] must obey to be composed with other components:
+//
+//   - the pipe accepts every item sent to its input, until the input is closed;
+//   - the pipe closes its output once its input is closed and its items are processed,
+//     having sent the number of items expected by [ConformanceOptions.Output], if set;
+//   - the pipe never sends after closing its output, checked for [ConformanceOptions.Settle]
+//     (sending on the closed channel itself panics, failing the test binary);
+//   - the pipe keeps up with slow consumers without deadlocking;
+//   - the pipe closes its output once its context is done, if [ConformanceOptions.Cancellable].
+//
+// The factory is called for each test, and must create a new pipe bound to ctx.
+func Conformance(t *testing.T, factory func(ctx context.Context) piper.Pipe, opts ...func(*ConformanceOptions)) {
+	t.Helper()
+
+	options := ConformanceOptions{
+		Input:   func(i int) any { return i },
+		Large:   1000,
+		Delay:   time.Millisecond,
+		Settle:  10 * time.Millisecond,
+		Timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	c := conformance{options: options}
+
+	t.Run("empty input", func(t *testing.T) {
+		c.exchange(t, factory(context.Background()), 0, 0)
+	})
+
+	t.Run("single item", func(t *testing.T) {
+		c.exchange(t, factory(context.Background()), 1, 0)
+	})
+
+	t.Run("large input", func(t *testing.T) {
+		c.exchange(t, factory(context.Background()), options.Large, 0)
+	})
+
+	t.Run("slow consumer", func(t *testing.T) {
+		c.exchange(t, factory(context.Background()), 10, options.Delay)
+	})
+
+	t.Run("early cancellation", func(t *testing.T) {
+		if !options.Cancellable {
+			t.Skip("pipe is not cancellable")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		pipe := factory(ctx)
+		closed := c.send(t, pipe, 1)
+		cancel()
+
+		select {
+		case <-closed:
+		case <-time.After(options.Timeout):
+			t.Fatalf("pipe did not close its output within %v of cancellation", options.Timeout)
+		}
+
+		close(pipe.In())
+		AssertStaysClosed(t, pipe, options.Settle)
+	})
+}
+
+// conformance runs the tests of the conformance suite.
+type conformance struct {
+	options ConformanceOptions
+}
+
+// send sends n items to the pipe while draining its output in the background, leaving its input
+// open. It returns a channel closed once the output is closed. The test fails if the pipe does not
+// accept the items within the timeout.
+func (c conformance) send(t *testing.T, pipe piper.Pipe, n int) <-chan struct{} {
+	t.Helper()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for range pipe.Out() {
+		}
+	}()
+
+	timer := time.NewTimer(c.options.Timeout)
+	defer timer.Stop()
+	for i := 0; i < n; i++ {
+		select {
+		case pipe.In() <- c.options.Input(i):
+		case <-timer.C:
+			t.Fatalf("pipe did not accept item %d within %v", i, c.options.Timeout)
+		}
+	}
+	return closed
+}
+
+// exchange sends n items to the pipe and closes its input, while receiving its output
+// with the delay before each item. The test fails unless the input accepts every item
+// and the output is closed within the timeout.
+func (c conformance) exchange(t *testing.T, pipe piper.Pipe, n int, delay time.Duration) {
+	t.Helper()
+
+	var (
+		ctx, cancel = context.WithTimeout(context.Background(), c.options.Timeout)
+		sent        = make(chan int, 1)
+		received    = 0
+	)
+	defer cancel()
+
+	go func() {
+		for i := 0; i < n; i++ {
+			select {
+			case pipe.In() <- c.options.Input(i):
+			case <-ctx.Done():
+				sent <- i
+				return
+			}
+		}
+		close(pipe.In())
+		sent <- n
+	}()
+
+	for {
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		select {
+		case _, ok := <-pipe.Out():
+			if !ok {
+				if count := <-sent; count < n {
+					t.Fatalf("pipe accepted %d of %d items before closing its output", count, n)
+				}
+				if c.options.Output != nil && received != c.options.Output(n) {
+					t.Fatalf("pipe sent %d items for %d items received, want %d", received, n, c.options.Output(n))
+				}
+				AssertStaysClosed(t, pipe, c.options.Settle)
+				return
+			}
+			received++
+		case <-ctx.Done():
+			// the sender shares the timeout; report whether it got stuck
+			if count := <-sent; count < n {
+				t.Fatalf("pipe did not accept item %d of %d within %v", count, n, c.options.Timeout)
+			}
+			t.Fatalf("pipe did not close its output within %v; received %d items", c.options.Timeout, received)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Package pipetest provides helpers for testing [piper.Pipe] implementations, without
+// hand-rolling goroutines, channels and timeouts in each test. Every helper fails the test,
+// rather than hanging it, when a pipe stops accepting or emitting items.
+//
+//	func TestDouble(t *testing.T) {
+//		pipetest.VerifyNoLeaks(t)
+//		pipetest.AssertEmits(t, Double(), []any{1, 2, 3}, []any{2, 4, 6})
+//	}
+//
+// [Conformance] verifies that a custom pipe obeys the rules every pipe must follow to be
+// composed with other components, such as closing its output once its input is closed:
+//
+//	func TestMyPipe(t *testing.T) {
+//		pipetest.Conformance(t, func(ctx context.Context) piper.Pipe { return NewMyPipe(ctx) })
+//	}
+//
+// A [Harness] steps a pipe through a scenario one item at a time, for pipes whose output
+// depends on when items arrive:
+//
+//	h := pipetest.NewHarness(t, pipeline.BatchN[int](2))
+//	h.Send(1, 2)
+//	h.Expect([]int{1, 2})
+//	h.Send(3)
+//	h.ExpectNothing(10 * time.Millisecond)
+//	h.Close()
+//	h.Expect([]int{3})
+//
+// A [FakeClock] drives time-based stages, such as [pipeline.Batch], without waiting on the
+// wall clock:
+//
+//	clock := pipetest.NewFakeClock(time.Time{})
+//	h := pipetest.NewHarness(t, pipeline.BatchEvery[int](time.Second, func(bo *pipeline.BatcherOptions) {
+//		bo.Clock = clock
+//	}))
+//	clock.BlockUntil(1)
+//	h.Send(1, 2)
+//	clock.Advance(time.Second)
+//	h.Expect([]int{1, 2})
+//
+// A [RecordingHandler] captures the records logged by the stages of a flow:
+//
+//	handler := pipetest.NewRecordingHandler()
+//	pipeline.FromSlice(1, 2, 3).WithLogger(slog.New(handler)).To(sink)
+//	records := handler.Records()
+package pipetest
@@ -0,0 +1,10 @@
+package pipetest
+
+import "time"
+
+// SetLeakTimeout sets the time allowed for goroutines to exit, restoring it with the returned function.
+func SetLeakTimeout(d time.Duration) (restore func()) {
+	previous := leakTimeout
+	leakTimeout = d
+	return func() { leakTimeout = previous }
+}
@@ -0,0 +1,150 @@
+package pipetest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper"
+)
+
+// Harness steps a pipe through a test scenario. Items emitted by the pipe are recorded as
+// soon as they are emitted, so that the pipe never blocks on its output; [Harness.Expect]
+// then consumes the recorded items in order.
+type Harness struct {
+	// Timeout is the time allowed for the pipe to accept, emit or close. Defaults to [DefaultTimeout].
+	Timeout time.Duration
+	t       testing.TB
+	pipe    piper.Pipe
+	mu      sync.Mutex
+	items   []any
+	// notify is signaled when an item is recorded.
+	notify chan struct{}
+	// done is closed once the output of the pipe is closed.
+	done chan struct{}
+	// closed reports whether the input of the pipe is closed.
+	closed bool
+}
+
+// NewHarness creates a new [Harness] for the pipe, recording its output in the background.
+// The payloads of [pipeline.Message] items are recorded, and the messages acknowledged.
+func NewHarness(t testing.TB, pipe piper.Pipe) *Harness {
+	h := &Harness{
+		Timeout: DefaultTimeout,
+		t:       t,
+		pipe:    pipe,
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go h.record()
+	return h
+}
+
+// record records the output of the pipe until it is closed.
+func (h *Harness) record() {
+	defer close(h.done)
+	for item := range h.pipe.Out() {
+		h.mu.Lock()
+		h.items = append(h.items, payload(item))
+		h.mu.Unlock()
+		select {
+		case h.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Send sends the items to the pipe, in order. The test fails if the pipe does not
+// accept an item within the timeout.
+func (h *Harness) Send(items ...any) {
+	h.t.Helper()
+	for _, item := range items {
+		timer := time.NewTimer(h.Timeout)
+		select {
+		case h.pipe.In() <- item:
+			timer.Stop()
+		case <-timer.C:
+			h.t.Fatalf("pipe did not accept %v within %v", item, h.Timeout)
+		}
+	}
+}
+
+// Expect consumes the next items emitted by the pipe, marking the test as failed if they
+// are not the wanted items. The test fails if the items are not emitted within the timeout.
+func (h *Harness) Expect(want ...any) {
+	h.t.Helper()
+	got := h.next(len(want))
+	if len(got) < len(want) {
+		h.t.Fatalf("got %v within %v, want %v", got, h.Timeout, want)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		h.t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// ExpectNothing marks the test as failed if the pipe emits an item within d.
+func (h *Harness) ExpectNothing(d time.Duration) {
+	h.t.Helper()
+	h.wait(1, d)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.items) > 0 {
+		h.t.Errorf("got %v, want nothing", h.items)
+	}
+}
+
+// Close closes the input of the pipe and waits for its output to be closed, returning the
+// items emitted and not yet consumed by [Harness.Expect]; these remain available to Expect.
+// The test fails if the output is not closed within the timeout.
+func (h *Harness) Close() []any {
+	h.t.Helper()
+	if !h.closed {
+		h.closed = true
+		close(h.pipe.In())
+	}
+	timer := time.NewTimer(h.Timeout)
+	defer timer.Stop()
+	select {
+	case <-h.done:
+	case <-timer.C:
+		h.t.Fatalf("pipe not closed within %v", h.Timeout)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append(make([]any, 0, len(h.items)), h.items...)
+}
+
+// next consumes up to n recorded items, waiting for them within the timeout.
+func (h *Harness) next(n int) []any {
+	h.wait(n, h.Timeout)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n = min(n, len(h.items))
+	items := h.items[:n:n]
+	h.items = h.items[n:]
+	return items
+}
+
+// wait waits until n items are recorded, the pipe is closed, or d has elapsed.
+func (h *Harness) wait(n int, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		h.mu.Lock()
+		count := len(h.items)
+		h.mu.Unlock()
+		if count >= n {
+			return
+		}
+		select {
+		case <-h.notify:
+		case <-h.done:
+			// every item is recorded before the output is closed
+			return
+		case <-timer.C:
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package pipetest
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leakTimeout is the time allowed for goroutines to exit once a test completes.
+var leakTimeout = DefaultTimeout
+
+// VerifyNoLeaks marks the test as failed if goroutines started during the test are still
+// running once it completes, allowing them [DefaultTimeout] to exit. Goroutines are compared
+// process-wide: do not combine VerifyNoLeaks with [testing.T.Parallel].
+func VerifyNoLeaks(t testing.TB) {
+	t.Helper()
+	before := goroutines()
+	t.Cleanup(func() {
+		t.Helper()
+		deadline := time.Now().Add(leakTimeout)
+		for {
+			leaked := make([]string, 0)
+			for id, stack := range goroutines() {
+				if _, ok := before[id]; !ok {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Errorf("found %d leaked goroutines:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+// goroutines returns the stacks of the running goroutines, keyed by their header.
+// Goroutines run by the testing package itself are omitted.
+func goroutines() map[string]string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	stacks := make(map[string]string)
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		s := string(stack)
+		if strings.Contains(s, "testing.tRunner(") || strings.Contains(s, "testing.(*M).") ||
+			strings.Contains(s, "os/signal.") {
+			continue
+		}
+		// "goroutine 12 [running]:" identifies the goroutine, regardless of its state
+		header, _, _ := strings.Cut(s, " [")
+		stacks[header] = s
+	}
+	return stacks
+}
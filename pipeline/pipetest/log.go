@@ -0,0 +1,63 @@
+package pipetest
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// RecordingHandler is a [slog.Handler] that keeps every record it handles in memory.
+// It is intended for tests asserting on the records emitted by a [pipeline.Flow]:
+//
+//	handler := pipetest.NewRecordingHandler()
+//	pipeline.FromSlice(1, 2, 3).WithLogger(slog.New(handler)).To(sink)
+//	records := handler.Records()
+type RecordingHandler struct {
+	// state is shared by the handlers derived with WithAttrs and WithGroup.
+	state *recordings
+	// attrs are added to each handled record.
+	attrs []slog.Attr
+}
+
+// recordings holds the records captured by a [RecordingHandler].
+type recordings struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+// NewRecordingHandler creates a new [RecordingHandler] that handles records of all levels.
+func NewRecordingHandler() *RecordingHandler {
+	return &RecordingHandler{state: &recordings{}}
+}
+
+// Enabled implements [slog.Handler]. All levels are enabled.
+func (h *RecordingHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+// Handle implements [slog.Handler], capturing the record.
+func (h *RecordingHandler) Handle(_ context.Context, r slog.Record) error {
+	r = r.Clone()
+	r.AddAttrs(h.attrs...)
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	h.state.records = append(h.state.records, r)
+	return nil
+}
+
+// WithAttrs implements [slog.Handler]. The returned handler shares the records of h.
+func (h *RecordingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &RecordingHandler{
+		state: h.state,
+		attrs: append(slices.Clone(h.attrs), attrs...),
+	}
+}
+
+// WithGroup implements [slog.Handler]. Groups are not recorded; the returned handler is h.
+func (h *RecordingHandler) WithGroup(string) slog.Handler { return h }
+
+// Records returns the records captured so far, in the order they were handled.
+func (h *RecordingHandler) Records() []slog.Record {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	return slices.Clone(h.state.records)
+}
@@ -0,0 +1,90 @@
+package pipetest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper"
+)
+
+// DefaultTimeout is the time allowed for a pipe to accept, emit or close before
+// the helpers fail the test.
+const DefaultTimeout = 5 * time.Second
+
+// RunPipe sends the inputs to the pipe, closes its input and returns every item emitted
+// until its output is closed. The payloads of [pipeline.Message] items are returned, and
+// the messages acknowledged. The test fails if the pipe does not close within [DefaultTimeout].
+func RunPipe(t testing.TB, pipe piper.Pipe, inputs ...any) []any {
+	t.Helper()
+	h := NewHarness(t, pipe)
+	h.Send(inputs...)
+	return h.Close()
+}
+
+// AssertEmits runs the pipe with the inputs and reports whether it emitted the wanted items,
+// in order. The test is marked as failed otherwise.
+func AssertEmits(t testing.TB, pipe piper.Pipe, inputs []any, want []any) bool {
+	t.Helper()
+	got := RunPipe(t, pipe, inputs...)
+	if len(got) == 0 && len(want) == 0 {
+		return true
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+		return false
+	}
+	return true
+}
+
+// AssertClosesWithin drains the outlet, returning the items received before it closed.
+// The test fails if the outlet is not closed within d.
+func AssertClosesWithin(t testing.TB, outlet piper.Outlet, d time.Duration) []any {
+	t.Helper()
+	var (
+		items = make([]any, 0)
+		timer = time.NewTimer(d)
+	)
+	defer timer.Stop()
+	for {
+		select {
+		case item, ok := <-outlet.Out():
+			if !ok {
+				return items
+			}
+			items = append(items, payload(item))
+		case <-timer.C:
+			t.Fatalf("outlet not closed within %v; received %v", d, items)
+			return items
+		}
+	}
+}
+
+// AssertStaysClosed watches the closed outlet for d, failing the test if it sends an item, such as
+// an outlet sending on a new channel after closing its own. Call it once the outlet is closed.
+func AssertStaysClosed(t testing.TB, outlet piper.Outlet, d time.Duration) {
+	t.Helper()
+	var (
+		timer  = time.NewTimer(d)
+		ticker = time.NewTicker(max(d/10, time.Millisecond))
+	)
+	defer timer.Stop()
+	defer ticker.Stop()
+	for {
+		select {
+		case item, ok := <-outlet.Out():
+			if ok {
+				t.Fatalf("outlet sent %v after closing", payload(item))
+				return
+			}
+		default:
+			t.Fatalf("outlet not closed")
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			return
+		}
+	}
+}
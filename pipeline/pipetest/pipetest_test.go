@@ -0,0 +1,281 @@
+package pipetest_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+)
+
+// FakeT is a [testing.TB] recording the failures of a test instead of reporting them.
+type FakeT struct {
+	testing.TB
+	mu       sync.Mutex
+	failures []string
+	cleanups []func()
+}
+
+func (f *FakeT) Helper() {}
+
+func (f *FakeT) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+
+func (f *FakeT) Errorf(format string, args ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func (f *FakeT) Fatalf(format string, args ...any) {
+	f.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// Failures runs the test function with a [FakeT], returning its failures once its cleanup
+// functions have run.
+func Failures(t *testing.T, test func(t testing.TB)) []string {
+	var (
+		fake = &FakeT{TB: t}
+		done = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		defer func() {
+			for i := len(fake.cleanups) - 1; i >= 0; i-- {
+				fake.cleanups[i]()
+			}
+		}()
+		test(fake)
+	}()
+	<-done
+	return fake.failures
+}
+
+// AssertFailure marks the test as failed unless the only failure contains want.
+func AssertFailure(t *testing.T, failures []string, want string) {
+	t.Helper()
+	if len(failures) != 1 || !strings.Contains(failures[0], want) {
+		t.Errorf("got failures %q, want one containing %q", failures, want)
+	}
+}
+
+// StuckPipe is a pipe that never accepts nor emits items.
+type StuckPipe struct {
+	in  chan any
+	out chan any
+}
+
+func NewStuckPipe() StuckPipe {
+	return StuckPipe{in: make(chan any), out: make(chan any)}
+}
+
+func (p StuckPipe) In() chan<- any  { return p.in }
+func (p StuckPipe) Out() <-chan any { return p.out }
+
+func double(i int) int { return i * 2 }
+
+func TestRunPipe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the emitted items", func(t *testing.T) {
+		got := pipetest.RunPipe(t, pipeline.Map(double), 1, 2, 3)
+		if fmt.Sprint(got) != "[2 4 6]" {
+			t.Errorf("got %v, want [2 4 6]", got)
+		}
+	})
+
+	t.Run("acknowledges messages", func(t *testing.T) {
+		var acked []int
+		msg := pipeline.NewMessage(1, func() { acked = append(acked, 1) }, nil)
+		got := pipetest.RunPipe(t, pipeline.Passthrough(), msg)
+		if fmt.Sprint(got, acked) != "[1] [1]" {
+			t.Errorf("got %v with acked %v, want [1] with acked [1]", got, acked)
+		}
+	})
+}
+
+func TestAssertEmits(t *testing.T) {
+	t.Parallel()
+
+	t.Run("passes with the wanted items", func(t *testing.T) {
+		failures := Failures(t, func(t testing.TB) {
+			pipetest.AssertEmits(t, pipeline.TakeN(2), []any{1, 2, 3}, []any{1, 2})
+			pipetest.AssertEmits(t, pipeline.TakeN(0), []any{1, 2, 3}, nil)
+		})
+		if len(failures) > 0 {
+			t.Errorf("got failures %q", failures)
+		}
+	})
+
+	t.Run("fails with other items", func(t *testing.T) {
+		failures := Failures(t, func(t testing.TB) {
+			pipetest.AssertEmits(t, pipeline.TakeN(2), []any{1, 2, 3}, []any{1, 2, 3})
+		})
+		AssertFailure(t, failures, "got [1 2], want [1 2 3]")
+	})
+}
+
+func TestAssertClosesWithin(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns the drained items", func(t *testing.T) {
+		got := pipetest.AssertClosesWithin(t, pipeline.FromSlice(1, 2), time.Second)
+		if fmt.Sprint(got) != "[1 2]" {
+			t.Errorf("got %v, want [1 2]", got)
+		}
+	})
+
+	t.Run("fails when the outlet stays open", func(t *testing.T) {
+		failures := Failures(t, func(t testing.TB) {
+			pipetest.AssertClosesWithin(t, NewStuckPipe(), 10*time.Millisecond)
+		})
+		AssertFailure(t, failures, "outlet not closed within 10ms")
+	})
+}
+
+// ReopeningOutlet is an outlet closing its output, then sending an item on a new channel.
+type ReopeningOutlet struct {
+	calls *atomic.Int32
+}
+
+func (o ReopeningOutlet) Out() <-chan any {
+	out := make(chan any, 1)
+	if o.calls.Add(1) == 1 {
+		close(out)
+	} else {
+		out <- "late"
+	}
+	return out
+}
+
+func TestAssertStaysClosed(t *testing.T) {
+	t.Parallel()
+
+	t.Run("passes once the outlet is closed", func(t *testing.T) {
+		flow := pipeline.FromSlice(1)
+		pipetest.AssertClosesWithin(t, flow, time.Second)
+		pipetest.AssertStaysClosed(t, flow, 10*time.Millisecond)
+	})
+
+	t.Run("fails when the outlet sends after closing", func(t *testing.T) {
+		failures := Failures(t, func(t testing.TB) {
+			outlet := ReopeningOutlet{calls: &atomic.Int32{}}
+			pipetest.AssertClosesWithin(t, outlet, time.Second)
+			pipetest.AssertStaysClosed(t, outlet, 10*time.Millisecond)
+		})
+		AssertFailure(t, failures, "outlet sent late after closing")
+	})
+}
+
+func TestHarness(t *testing.T) {
+	t.Parallel()
+
+	t.Run("steps through a scenario", func(t *testing.T) {
+		h := pipetest.NewHarness(t, pipeline.BatchN[int](2))
+		h.Send(1, 2)
+		h.Expect([]int{1, 2})
+		h.Send(3)
+		h.ExpectNothing(10 * time.Millisecond)
+		if got := h.Close(); fmt.Sprint(got) != "[[3]]" {
+			t.Errorf("got %v, want [[3]]", got)
+		}
+		h.Expect([]int{3})
+	})
+
+	tests := []struct {
+		name string
+		test func(h *pipetest.Harness)
+		want string
+	}{
+		{
+			name: "fails when the pipe blocks its input",
+			test: func(h *pipetest.Harness) { h.Send(1) },
+			want: "pipe did not accept 1 within 10ms",
+		},
+		{
+			name: "fails when the pipe emits nothing",
+			test: func(h *pipetest.Harness) { h.Expect(1) },
+			want: "got [] within 10ms, want [1]",
+		},
+		{
+			name: "fails when the pipe stays open",
+			test: func(h *pipetest.Harness) { h.Close() },
+			want: "pipe not closed within 10ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := Failures(t, func(t testing.TB) {
+				h := pipetest.NewHarness(t, NewStuckPipe())
+				h.Timeout = 10 * time.Millisecond
+				tt.test(h)
+			})
+			AssertFailure(t, failures, tt.want)
+		})
+	}
+
+	t.Run("fails with other items", func(t *testing.T) {
+		failures := Failures(t, func(t testing.TB) {
+			h := pipetest.NewHarness(t, pipeline.Map(double))
+			h.Send(1, 2)
+			h.Expect(2, 3)
+			h.Close()
+		})
+		AssertFailure(t, failures, "got [2 4], want [2 3]")
+	})
+
+	t.Run("fails when the pipe emits unexpected items", func(t *testing.T) {
+		failures := Failures(t, func(t testing.TB) {
+			h := pipetest.NewHarness(t, pipeline.Map(double))
+			h.Send(1)
+			h.ExpectNothing(10 * time.Millisecond)
+			h.Close()
+		})
+		AssertFailure(t, failures, "got [2], want nothing")
+	})
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	recorder := pipetest.NewRecorder()
+	pipeline.FromSlice(1, 2, 3).Thru(pipeline.Map(double)).To(recorder)
+
+	if got := recorder.Wait(); fmt.Sprint(got) != "[2 4 6]" {
+		t.Errorf("got %v, want [2 4 6]", got)
+	}
+}
+
+// TestVerifyNoLeaks may not run in parallel, as goroutines are compared process-wide.
+func TestVerifyNoLeaks(t *testing.T) {
+	defer pipetest.SetLeakTimeout(50 * time.Millisecond)()
+
+	t.Run("passes once the pipe is closed", func(t *testing.T) {
+		failures := Failures(t, func(t testing.TB) {
+			pipetest.VerifyNoLeaks(t)
+			pipetest.RunPipe(t, pipeline.Map(double), 1, 2)
+		})
+		if len(failures) > 0 {
+			t.Errorf("got failures %q", failures)
+		}
+	})
+
+	t.Run("fails with a pipe left open", func(t *testing.T) {
+		pipe := pipeline.Map(double)
+		defer close(pipe.In())
+
+		failures := Failures(t, func(t testing.TB) {
+			pipetest.VerifyNoLeaks(t)
+			h := pipetest.NewHarness(t, pipe)
+			h.Send(1)
+			h.Expect(2)
+		})
+		AssertFailure(t, failures, "leaked goroutines")
+	})
+}
@@ -0,0 +1,62 @@
+package pipetest
+
+import (
+	"sync"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// Recorder is a [piper.Sink] recording every item it receives. The payloads of
+// [pipeline.Message] items are recorded, and the messages acknowledged.
+type Recorder struct {
+	in    chan any
+	mu    sync.Mutex
+	items []any
+	done  chan struct{}
+}
+
+// NewRecorder creates a new [Recorder], ready to receive items.
+func NewRecorder() *Recorder {
+	r := &Recorder{in: make(chan any), items: make([]any, 0), done: make(chan struct{})}
+	go r.start()
+	return r
+}
+
+func (r *Recorder) In() chan<- any { return r.in }
+
+// Name implements [pipeline.Namer].
+func (r *Recorder) Name() string { return "recorder" }
+
+// Items returns the items recorded so far.
+func (r *Recorder) Items() []any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(make([]any, 0, len(r.items)), r.items...)
+}
+
+// Wait blocks until the input of the recorder is closed, returning every item recorded.
+func (r *Recorder) Wait() []any {
+	<-r.done
+	return r.Items()
+}
+
+// Done returns a channel closed once the input of the recorder is closed.
+func (r *Recorder) Done() <-chan struct{} { return r.done }
+
+func (r *Recorder) start() {
+	defer close(r.done)
+	for item := range r.in {
+		r.mu.Lock()
+		r.items = append(r.items, payload(item))
+		r.mu.Unlock()
+	}
+}
+
+// payload returns the payload of a [pipeline.Message], acknowledging it, or the item itself.
+func payload(item any) any {
+	if msg, ok := item.(pipeline.Message); ok {
+		msg.Ack()
+		return msg.Payload
+	}
+	return item
+}
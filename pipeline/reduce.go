@@ -9,6 +9,7 @@ type ReduceFunction[T any] func(acc T, item T) T
 // reducer implements a pipeline component that combines multiple items into a single accumulated result.
 // It processes items one at a time, maintaining and updating an accumulator value.
 type reducer[T any] struct {
+	*stage
 	// in receives items to be reduced.
 	in chan any
 	// out sends the current accumulated value after each reduction.
@@ -23,6 +24,7 @@ type reducer[T any] struct {
 // The function is called for each item with the current accumulated value and the new item.
 func Reduce[T any](fn ReduceFunction[T]) piper.Pipe {
 	pipe := &reducer[T]{
+		stage:          newStage("reduce"),
 		in:             make(chan any),
 		out:            make(chan any),
 		reduceFunction: fn,
@@ -39,15 +41,17 @@ func (r *reducer[T]) Out() <-chan any { return r.out }
 // accumulated value downstream after each combination.
 func (r *reducer[T]) start() {
 	defer close(r.out)
+	defer r.finish()
 
 	for item := range r.in {
+		rec := r.receive(item)
 		if r.acc == nil {
-			r.acc = item
-			r.out <- item
+			r.acc = unwrap(item)
+			r.emit(r.out, item, item, rec)
 			continue
 		}
-		acc := r.reduceFunction(r.acc.(T), item.(T))
+		acc := r.reduceFunction(r.acc.(T), unwrap(item).(T))
 		r.acc = acc
-		r.out <- acc
+		r.emit(r.out, item, rewrap(item, acc), rec)
 	}
 }
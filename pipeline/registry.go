@@ -0,0 +1,83 @@
+package pipeline
+
+import "fmt"
+
+// StageState is the lifecycle state of a pipeline component.
+type StageState int
+
+const (
+	// StageUnknown is the state of custom components, which do not report their state.
+	StageUnknown StageState = iota
+	// StageIdle is the state of a component waiting for its first item.
+	StageIdle
+	// StageRunning is the state of a component processing its items.
+	StageRunning
+	// StageStopped is the state of a component that has processed all of its items.
+	StageStopped
+)
+
+// String returns the name of the state.
+func (s StageState) String() string {
+	switch s {
+	case StageUnknown:
+		return "unknown"
+	case StageIdle:
+		return "idle"
+	case StageRunning:
+		return "running"
+	case StageStopped:
+		return "stopped"
+	default:
+		return fmt.Sprintf("StageState(%d)", int(s))
+	}
+}
+
+// StageStatus is a snapshot of the status of a pipeline component, as returned by [Flow.Stages].
+// Custom components only report their name and kind.
+type StageStatus struct {
+	// Name is the name of the component. See [Namer].
+	Name string
+	// Kind classifies the component.
+	Kind NodeKind
+	// State is the lifecycle state of the component.
+	State StageState
+	// Received is the number of items received by the component.
+	Received uint64
+	// Emitted is the number of items the component processed and sent downstream.
+	Emitted uint64
+	// Dropped is the number of items the component discarded.
+	Dropped uint64
+	// Errors is the number of items the component failed to process.
+	Errors uint64
+}
+
+// reporter is implemented by components reporting their status.
+type reporter interface {
+	status() StageStatus
+}
+
+// statusOf returns the status of the component.
+func statusOf(component any) StageStatus {
+	if r, ok := component.(reporter); ok {
+		return r.status()
+	}
+	return StageStatus{Name: nameOf(component)}
+}
+
+// Stages returns the status of every component of the pipeline, in the order of the nodes
+// of [Flow.Graph]. The registry includes the components added to the pipeline so far, and
+// may be queried at any time while the pipeline is running.
+func (f Flow) Stages() []StageStatus {
+	return f.topology.statuses()
+}
+
+// Stage returns the status of the first component of the pipeline with the given name,
+// and whether such a component was found.
+func (f Flow) Stage(name string) (StageStatus, bool) {
+	for _, status := range f.Stages() {
+		if status.Name == name {
+			return status, true
+		}
+	}
+	return StageStatus{}, false
+}
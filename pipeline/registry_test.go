@@ -0,0 +1,70 @@
+package pipeline_test
+
+import (
+	"testing"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+func TestFlowStages(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports state and counters", func(t *testing.T) {
+		var (
+			input = make(chan int)
+			sink  = pipeline.ToSlice[int]()
+			evens = pipeline.KeepIf(func(i int) bool { return i%2 == 0 })
+			flow  = pipeline.FromChannel(input).Thru(evens)
+		)
+
+		flow.To(sink)
+
+		if status, _ := flow.Stage("filter"); status.State != pipeline.StageIdle {
+			t.Errorf("got state %v, want %v", status.State, pipeline.StageIdle)
+		}
+
+		input <- 1
+		Eventually(t, func() bool {
+			status, _ := flow.Stage("filter")
+			return status.State == pipeline.StageRunning && status.Dropped == 1
+		})
+
+		input <- 2
+		input <- 3
+		close(input)
+		sink.Slice()
+
+		want := []pipeline.StageStatus{
+			{Name: "channel", Kind: pipeline.SourceNode, State: pipeline.StageStopped, Received: 3, Emitted: 3},
+			{Name: "filter", Kind: pipeline.PipeNode, State: pipeline.StageStopped, Received: 3, Emitted: 1, Dropped: 2},
+			{Name: "slice", Kind: pipeline.SinkNode, State: pipeline.StageStopped, Received: 1, Emitted: 1},
+		}
+
+		Eventually(t, func() bool {
+			got := flow.Stages()
+			if len(got) != len(want) {
+				return false
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					return false
+				}
+			}
+			return true
+		})
+	})
+
+	t.Run("reports unknown components", func(t *testing.T) {
+		flow := pipeline.FromSlice(1)
+		Consume[int](flow)
+
+		if _, ok := flow.Stage("missing"); ok {
+			t.Errorf("found a missing stage")
+		}
+
+		status, ok := flow.Stage("slice")
+		if !ok || status.State != pipeline.StageUnknown || status.Kind != pipeline.SourceNode {
+			t.Errorf("got status %+v", status)
+		}
+	})
+}
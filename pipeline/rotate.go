@@ -0,0 +1,341 @@
+package pipeline
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/internal/must"
+)
+
+// RotateOptions configure when the files written by [RotateFile] and [ToRotatingFile] are rolled,
+// and how they are finalized. Files are rolled once any of the limits is reached.
+type RotateOptions struct {
+	// MaxSize is the size, in bytes, past which a file is rolled. Zero disables the limit.
+	MaxSize int64
+	// MaxItems is the number of items after which a file is rolled. Zero disables the limit.
+	MaxItems int
+	// Interval rolls the current file on every tick of the interval, if it holds any items.
+	// Zero disables the interval.
+	Interval time.Duration
+	// Compress compresses each file once rolled, replacing it with a gzip file of the same name
+	// ending with ".gz".
+	Compress bool
+	// Delimiter is written after each item. Defaults to a newline.
+	Delimiter []byte
+	// Clock schedules the interval and formats the {time} of file names. Defaults to [SystemClock].
+	Clock Clock
+	// HandleError is called when an item cannot be written, or a file cannot be rolled.
+	HandleError func(error)
+}
+
+// rotator implements a pipeline component that writes items to files rolled by size, item count
+// or time, sending the path of each file once finalized.
+type rotator[In any] struct {
+	*stage
+	// in receives the items to be written.
+	in chan any
+	// out sends the path of each finalized file.
+	out chan any
+	// dir and pattern name the files.
+	dir     string
+	pattern string
+	// encode encodes each item.
+	encode EncodeFunction[In]
+	// options configure the rollover.
+	options RotateOptions
+	// seq is the sequence number of the last file created.
+	seq int
+	// file is the current file, if any, buffered by w.
+	file *os.File
+	w    *bufio.Writer
+	// size and count are the number of bytes and items written to the current file.
+	size  int64
+	count int
+	// pending holds the items written to the current file, reported and settled with its path.
+	pending []written
+	// err is the first error encountered while writing or rolling.
+	err error
+}
+
+// RotateFile creates a new [piper.Pipe] that writes each item to a file in dir, encoded by the
+// [EncodeFunction] and followed by the delimiter, rolling to a new file by size, item count or time.
+// A nil encode function encodes items like [ToWriter]. Once rolled, each file is synced to disk,
+// closed and optionally compressed, and its path is sent downstream, so a following stage may
+// upload it. Items are reported as emitted once their file is finalized. Acknowledging the path
+// acknowledges every item written to the file, and items fail, negatively acknowledged, if it
+// cannot be rolled. The current file is rolled once the input closes.
+//
+// Files are named by the pattern, where {seq} is replaced by a sequence number and {time} by the
+// time the file is created, formatted like 20060102T150405Z in UTC. If the pattern has no {seq},
+// one is inserted before its extension, so "events.jsonl" names files like "events-000001.jsonl".
+// Existing files are never overwritten; their sequence numbers are skipped. See [RotateOptions]
+// for details.
+func RotateFile[In any](dir, pattern string, encode EncodeFunction[In], opts ...func(*RotateOptions)) piper.Pipe {
+	return newRotator(dir, pattern, encode, opts...)
+}
+
+// newRotator creates and starts a new rotator.
+func newRotator[In any](dir, pattern string, encode EncodeFunction[In], opts ...func(*RotateOptions)) *rotator[In] {
+	options := RotateOptions{
+		Delimiter:   []byte("\n"),
+		Clock:       SystemClock(),
+		HandleError: must.IgnoreError,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Clock == nil {
+		options.Clock = SystemClock()
+	}
+	if encode == nil {
+		encode = encodeAny[In]
+	}
+	if !strings.Contains(pattern, "{seq}") {
+		ext := filepath.Ext(pattern)
+		pattern = strings.TrimSuffix(pattern, ext) + "-{seq}" + ext
+	}
+
+	pipe := &rotator[In]{
+		stage:   newStage("rotate"),
+		in:      make(chan any),
+		out:     make(chan any),
+		dir:     dir,
+		pattern: pattern,
+		encode:  encode,
+		options: options,
+	}
+	go pipe.start()
+	return pipe
+}
+
+func (r *rotator[In]) In() chan<- any  { return r.in }
+func (r *rotator[In]) Out() <-chan any { return r.out }
+
+// start writes each item received, rolling the current file as needed.
+func (r *rotator[In]) start() {
+	defer close(r.out)
+	defer r.finish()
+	defer r.roll()
+
+	var tick <-chan time.Time
+	if r.options.Interval > 0 {
+		ticker := r.options.Clock.NewTicker(r.options.Interval)
+		defer ticker.Stop()
+		tick = ticker.C()
+	}
+
+	for {
+		select {
+		case item, ok := <-r.in:
+			if !ok {
+				return
+			}
+			r.write(item)
+			if (r.options.MaxItems > 0 && r.count >= r.options.MaxItems) ||
+				(r.options.MaxSize > 0 && r.size >= r.options.MaxSize) {
+				r.roll()
+			}
+		case <-tick:
+			r.roll()
+		}
+	}
+}
+
+// write encodes and writes the item to the current file, creating it if needed.
+func (r *rotator[In]) write(item any) {
+	rec := r.receive(item)
+	data, err := r.encode(unwrap(item).(In))
+	if err == nil && r.file == nil {
+		err = r.create()
+	}
+	if err == nil {
+		_, err = r.w.Write(data)
+	}
+	if err == nil {
+		_, err = r.w.Write(r.options.Delimiter)
+	}
+	if err != nil {
+		r.failed(err)
+		r.fail(item, rec, err)
+		return
+	}
+	r.size += int64(len(data) + len(r.options.Delimiter))
+	r.count++
+	r.pending = append(r.pending, written{item: item, rec: rec})
+}
+
+// create creates the next file, skipping the names of existing files.
+func (r *rotator[In]) create() error {
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return err
+	}
+	for {
+		r.seq++
+		name := strings.NewReplacer(
+			"{seq}", fmt.Sprintf("%06d", r.seq),
+			"{time}", r.options.Clock.Now().UTC().Format("20060102T150405Z"),
+		).Replace(r.pattern)
+		path := filepath.Join(r.dir, name)
+		if r.options.Compress {
+			// the compressed file replaces the file once rolled
+			if _, err := os.Stat(path + ".gz"); err == nil {
+				continue
+			}
+		}
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		r.file, r.w, r.size, r.count = file, bufio.NewWriter(file), 0, 0
+		return nil
+	}
+}
+
+// roll finalizes the current file, if any, sending its path downstream. The items written to the
+// file are reported as emitted and settled with the path, or failed if it cannot be finalized.
+func (r *rotator[In]) roll() {
+	if r.file == nil {
+		return
+	}
+	var (
+		file, w = r.file, r.w
+		pending = r.pending
+		path    = file.Name()
+	)
+	r.file, r.w, r.pending = nil, nil, nil
+
+	err := finalizeFile(file, w)
+	if err == nil && r.options.Compress {
+		path, err = compressFile(path)
+	}
+	if err != nil {
+		r.log(slog.LevelError, "roll failed", slog.String("path", path), slog.Any("error", err))
+		r.failed(err)
+		for _, w := range pending {
+			r.fail(w.item, w.rec, err)
+		}
+		return
+	}
+
+	r.log(slog.LevelDebug, "file rolled", slog.String("path", path))
+	items := make([]any, len(pending))
+	for i, w := range pending {
+		items[i] = w.item
+		r.emitted(w.item, w.rec, w.rec.elapsed())
+	}
+	r.push(r.out, merge(items, path))
+}
+
+// finalizeFile flushes the writer buffering the file, then syncs and closes the file.
+func finalizeFile(file *os.File, w *bufio.Writer) error {
+	err := w.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	return errors.Join(err, file.Close())
+}
+
+// failed records the error, passing it to the error handler.
+func (r *rotator[In]) failed(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.options.HandleError(err)
+}
+
+// compressFile replaces the file at path with a gzip file, returning its path.
+func compressFile(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return path, err
+	}
+	defer src.Close()
+
+	gzPath := path + ".gz"
+	dst, err := os.OpenFile(gzPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return path, err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(path)
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if err = errors.Join(err, dst.Close()); err != nil {
+		os.Remove(gzPath)
+		return path, err
+	}
+	return gzPath, os.Remove(path)
+}
+
+// rotatingSink implements a pipeline sink that writes items to rotating files.
+type rotatingSink[In any] struct {
+	*stage
+	// pipe writes the items, sending the path of each finalized file.
+	pipe *rotator[In]
+	// wg is used to signal when all files have been finalized.
+	wg *sync.WaitGroup
+	// mu guards files, the paths of the finalized files.
+	mu    sync.Mutex
+	files []string
+}
+
+// ToRotatingFile creates a new [piper.Sink] that writes each item to rotating files in dir, like
+// [RotateFile], acknowledging the items once their file is finalized. Use the Wait method of the sink
+// to wait for its input to be written, and the Files method to list the finalized files.
+func ToRotatingFile[In any](dir, pattern string, encode EncodeFunction[In], opts ...func(*RotateOptions)) *rotatingSink[In] {
+	pipe := newRotator(dir, pattern, encode, opts...)
+	sink := &rotatingSink[In]{
+		stage: pipe.stage,
+		pipe:  pipe,
+		wg:    &sync.WaitGroup{},
+	}
+	sink.wg.Add(1)
+	go func() {
+		defer sink.wg.Done()
+		for item := range pipe.out {
+			sink.mu.Lock()
+			sink.files = append(sink.files, unwrap(item).(string))
+			sink.mu.Unlock()
+			ack(item)
+		}
+	}()
+	return sink
+}
+
+func (s *rotatingSink[In]) In() chan<- any { return s.pipe.in }
+
+// Wait blocks until every item has been written and every file finalized, returning the first error
+// encountered while writing or rolling, if any.
+func (s *rotatingSink[In]) Wait() error {
+	s.wg.Wait()
+	return s.pipe.err
+}
+
+// Files returns a copy of the paths of the files finalized so far, in order. Call it after Wait
+// to list every file.
+func (s *rotatingSink[In]) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.files)
+}
@@ -0,0 +1,296 @@
+package pipeline_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+)
+
+// ReadFiles returns the content of each file, decompressing gzip files.
+func ReadFiles(t *testing.T, paths []string) []string {
+	t.Helper()
+	contents := make([]string, len(paths))
+	for i, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		var r io.Reader = f
+		if filepath.Ext(path) == ".gz" {
+			if r, err = gzip.NewReader(f); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[i] = string(data)
+	}
+	return contents
+}
+
+func TestToRotatingFile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		pattern string
+		opts    func(*pipeline.RotateOptions)
+		files   []string
+		want    []string
+	}{
+		{
+			name:    "rolls by item count",
+			pattern: "events.log",
+			opts:    func(o *pipeline.RotateOptions) { o.MaxItems = 2 },
+			files:   []string{"events-000001.log", "events-000002.log", "events-000003.log"},
+			want:    []string{"1\n2\n", "3\n4\n", "5\n"},
+		},
+		{
+			name:    "rolls by size",
+			pattern: "{seq}.txt",
+			opts:    func(o *pipeline.RotateOptions) { o.MaxSize = 5 },
+			files:   []string{"000001.txt", "000002.txt"},
+			want:    []string{"1\n2\n3\n", "4\n5\n"},
+		},
+		{
+			name:    "compresses files",
+			pattern: "events.log",
+			opts: func(o *pipeline.RotateOptions) {
+				o.MaxItems = 3
+				o.Compress = true
+				o.Delimiter = []byte(";")
+			},
+			files: []string{"events-000001.log.gz", "events-000002.log.gz"},
+			want:  []string{"1;2;3;", "4;5;"},
+		},
+		{
+			name:    "writes a single file without limits",
+			pattern: "events-{seq}.log",
+			opts:    func(*pipeline.RotateOptions) {},
+			files:   []string{"events-000001.log"},
+			want:    []string{"1\n2\n3\n4\n5\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				dir  = t.TempDir()
+				sink = pipeline.ToRotatingFile[int](dir, tt.pattern, nil, tt.opts)
+			)
+			pipeline.FromSlice(1, 2, 3, 4, 5).To(sink)
+			if err := sink.Wait(); err != nil {
+				t.Fatal(err)
+			}
+
+			var want []string
+			for _, name := range tt.files {
+				want = append(want, filepath.Join(dir, name))
+			}
+			if got := sink.Files(); !reflect.DeepEqual(got, want) {
+				t.Fatalf("got files %v, want %v", got, want)
+			}
+			if got := ReadFiles(t, sink.Files()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got contents %q, want %q", got, tt.want)
+			}
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != len(tt.files) {
+				t.Errorf("got %d files in the directory, want %d", len(entries), len(tt.files))
+			}
+		})
+	}
+
+	t.Run("skips existing files", func(t *testing.T) {
+		dir := t.TempDir()
+		Append(t, filepath.Join(dir, "events-000001.log"), "old\n")
+
+		sink := pipeline.ToRotatingFile[string](dir, "events.log", nil)
+		pipeline.FromSlice("new").To(sink)
+		if err := sink.Wait(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{filepath.Join(dir, "events-000002.log")}
+		if got := sink.Files(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("got files %v, want %v", got, want)
+		}
+		if got := ReadFiles(t, []string{filepath.Join(dir, "events-000001.log")}); got[0] != "old\n" {
+			t.Errorf("got %q, want the existing file unchanged", got[0])
+		}
+	})
+
+	t.Run("lists files while running", func(t *testing.T) {
+		var (
+			dir   = t.TempDir()
+			input = make(chan string)
+			sink  = pipeline.ToRotatingFile[string](dir, "events.log", nil, func(o *pipeline.RotateOptions) {
+				o.MaxItems = 1
+			})
+		)
+		pipeline.FromChannel(input).To(sink)
+
+		input <- "a"
+		Eventually(t, func() bool { return len(sink.Files()) == 1 })
+		input <- "b"
+		close(input)
+		if err := sink.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if got := ReadFiles(t, sink.Files()); !reflect.DeepEqual(got, []string{"a\n", "b\n"}) {
+			t.Errorf("got contents %q", got)
+		}
+	})
+
+	t.Run("reports encoding errors", func(t *testing.T) {
+		var (
+			dir        = t.TempDir()
+			deliveries = &Deliveries{}
+			errs       []error
+			encode     = func(i int) ([]byte, error) {
+				if i == 2 {
+					return nil, strconv.ErrSyntax
+				}
+				return []byte(strconv.Itoa(i)), nil
+			}
+			sink = pipeline.ToRotatingFile(dir, "events.log", encode, func(o *pipeline.RotateOptions) {
+				o.HandleError = func(err error) { errs = append(errs, err) }
+			})
+		)
+		pipeline.FromSlice(deliveries.Messages(1, 2, 3)...).To(sink)
+
+		if err := sink.Wait(); err != strconv.ErrSyntax {
+			t.Errorf("got error %v, want %v", err, strconv.ErrSyntax)
+		}
+		if got := ReadFiles(t, sink.Files()); !reflect.DeepEqual(got, []string{"1\n3\n"}) {
+			t.Errorf("got contents %q", got)
+		}
+		if got, want := deliveries.Acked(), []int{1, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got acked %v, want %v", got, want)
+		}
+		if got, want := deliveries.Nacked(), []int{2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got nacked %v, want %v", got, want)
+		}
+	})
+
+	t.Run("reports items once their file is rolled", func(t *testing.T) {
+		for _, tt := range []struct {
+			name   string
+			remove bool
+			want   Counts
+		}{
+			{name: "rolled", want: Counts{Received: 2, Emitted: 2}},
+			{name: "failed", remove: true, want: Counts{Received: 2, Errors: 2}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				var (
+					dir       = t.TempDir()
+					collector = pipeline.NewCollector()
+					items     = make(chan int)
+					sink      = pipeline.ToRotatingFile[int](dir, "events.log", nil, func(o *pipeline.RotateOptions) {
+						o.Compress = true
+					})
+				)
+				pipeline.FromChannel(items).WithObserver(collector).To(sink)
+
+				items <- 1
+				items <- 2
+				Eventually(t, func() bool {
+					return CountsOf(collector.Snapshot())["rotate"].Received == 2
+				})
+				if got := CountsOf(collector.Snapshot())["rotate"]; got.Emitted != 0 {
+					t.Errorf("got %d items emitted before the file was rolled", got.Emitted)
+				}
+				if tt.remove {
+					// the file cannot be compressed once removed
+					if err := os.Remove(filepath.Join(dir, "events-000001.log")); err != nil {
+						t.Fatal(err)
+					}
+				}
+				close(items)
+
+				if err := sink.Wait(); (err != nil) != tt.remove {
+					t.Errorf("got error %v", err)
+				}
+				if got := CountsOf(collector.Snapshot())["rotate"]; got != tt.want {
+					t.Errorf("got %+v, want %+v", got, tt.want)
+				}
+			})
+		}
+	})
+}
+
+func TestRotateFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("rolls on the interval", func(t *testing.T) {
+		var (
+			dir   = t.TempDir()
+			clock = pipetest.NewFakeClock(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC))
+			pipe  = pipeline.RotateFile[string](dir, "{time}.log", nil, func(o *pipeline.RotateOptions) {
+				o.Interval = time.Minute
+				o.Clock = clock
+			})
+		)
+		defer close(pipe.In())
+
+		// the second item is received once the first is written
+		clock.BlockUntil(1)
+		pipe.In() <- "a"
+		pipe.In() <- "b"
+		clock.Advance(time.Minute)
+
+		select {
+		case got := <-pipe.Out():
+			want := filepath.Join(dir, "20240501T123000Z-000001.log")
+			if got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got := ReadFiles(t, []string{want}); got[0] != "a\nb\n" {
+				t.Errorf("got contents %q, want %q", got[0], "a\nb\n")
+			}
+		case <-time.After(pipetest.DefaultTimeout):
+			t.Fatal("no file rolled")
+		}
+	})
+
+	t.Run("acknowledges items with their file", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			pipe       = pipeline.RotateFile[int](t.TempDir(), "events.log", nil, func(o *pipeline.RotateOptions) {
+				o.MaxItems = 2
+			})
+			flow = pipeline.FromSlice(deliveries.Messages(1, 2, 3)...).Thru(pipe)
+		)
+
+		var files []pipeline.Message
+		for item := range flow.Out() {
+			files = append(files, item.(pipeline.Message))
+		}
+		if len(files) != 2 {
+			t.Fatalf("got %d files, want 2", len(files))
+		}
+		if got := deliveries.Acked(); len(got) != 0 {
+			t.Fatalf("got acked %v before the files were acknowledged", got)
+		}
+
+		files[0].Ack()
+		if got, want := deliveries.Acked(), []int{1, 2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got acked %v, want %v", got, want)
+		}
+		files[1].Nack(io.ErrUnexpectedEOF)
+		if got, want := deliveries.Nacked(), []int{3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got nacked %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sync"
+)
+
+// ErrStopped negatively acknowledges the messages discarded by a [Flow] once a range loop over
+// [Flow.All] or [Seq] stops early, and the items sources were sending when stopped.
+var ErrStopped = errors.New("iteration stopped")
+
+// KeyValue is a key/value pair, sent by [FromSeq2] for each pair of its sequence.
+type KeyValue[K any, V any] struct {
+	Key   K
+	Value V
+}
+
+// stopper is implemented by sources that may stop sending before they are exhausted.
+type stopper interface {
+	stop()
+}
+
+// binder is implemented by sources bound to the context of a [Flow], set by [Flow.WithContext].
+type binder interface {
+	bind(ctx context.Context)
+}
+
+// seqSource implements a pipeline source that sends the values of an iterator. The iterator
+// is started once the output is first requested, and stops once the source is stopped or
+// its context is done.
+type seqSource[T any] struct {
+	*stage
+	// seq returns the iterator yielding the values to send, stopping once ctx is done.
+	seq func(ctx context.Context) iter.Seq[T]
+	// out is the channel where the values are sent.
+	out chan any
+	// once starts the iterator at most once.
+	once sync.Once
+	// mu guards the fields below.
+	mu sync.Mutex
+	// ctx is the context the source is bound to.
+	ctx context.Context
+	// cancel stops the iterator, once started.
+	cancel context.CancelCauseFunc
+	// stopped is set once the source is stopped.
+	stopped bool
+}
+
+// FromSeq creates a new [Flow] that sends the values yielded by the iterator, in order.
+// The iterator is pulled as the pipeline receives its values, and is stopped early once
+// the context of the flow is done, or a range loop over the [Flow.All] or [Seq] of a flow
+// downstream breaks.
+func FromSeq[T any](seq iter.Seq[T]) Flow {
+	return fromSeq("seq", func(context.Context) iter.Seq[T] { return seq })
+}
+
+// FromSeqContext creates a new [Flow] like [FromSeq], with the iterator returned by seq once the
+// pipeline receives its first value. The context passed to seq is done once the iterator is stopped,
+// such as to cancel the requests made by the iterator.
+func FromSeqContext[T any](seq func(ctx context.Context) iter.Seq[T]) Flow {
+	return fromSeq("seq", seq)
+}
+
+// FromSeq2 creates a new [Flow] that sends each pair yielded by the iterator, in order,
+// as a [KeyValue]. See [FromSeq] for details.
+func FromSeq2[K any, V any](seq iter.Seq2[K, V]) Flow {
+	return fromSeq("seq2", func(context.Context) iter.Seq[KeyValue[K, V]] {
+		return func(yield func(KeyValue[K, V]) bool) {
+			for k, v := range seq {
+				if !yield(KeyValue[K, V]{Key: k, Value: v}) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// fromSeq creates a new [Flow] starting from a source with the given name, sending the values of
+// the iterator returned by seq.
+func fromSeq[T any](name string, seq func(ctx context.Context) iter.Seq[T]) Flow {
+	return From(newSeqSource(name, seq))
+}
+
+// newSeqSource creates a new source with the given name, sending the values of the iterator
+// returned by seq.
+func newSeqSource[T any](name string, seq func(ctx context.Context) iter.Seq[T]) *seqSource[T] {
+	return &seqSource[T]{
+		stage: newStage(name),
+		seq:   seq,
+		out:   make(chan any),
+		ctx:   context.Background(),
+	}
+}
+
+// Out starts the iterator, if not already started, and returns the channel receiving its values.
+func (s *seqSource[T]) Out() <-chan any {
+	s.once.Do(func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		ctx, cancel := context.WithCancelCause(s.ctx)
+		s.cancel = cancel
+		if s.stopped {
+			cancel(ErrStopped)
+		}
+		go s.start(ctx)
+	})
+	return s.out
+}
+
+// bind implements binder, stopping the iterator once ctx is done. It has no effect once the
+// iterator is started.
+func (s *seqSource[T]) bind(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx = ctx
+}
+
+// stop implements stopper, stopping the iterator before its next value is sent.
+func (s *seqSource[T]) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	if s.cancel != nil {
+		s.cancel(ErrStopped)
+	}
+}
+
+// start sends each value of the iterator until it is exhausted or ctx is done.
+func (s *seqSource[T]) start(ctx context.Context) {
+	defer close(s.out)
+	defer s.finish()
+	for value := range s.seq(ctx) {
+		rec := s.receive(value)
+		latency := rec.elapsed()
+		select {
+		case s.out <- rec.carry(value):
+			s.emitted(value, rec, latency)
+		case <-ctx.Done():
+			// stopping is not a failure of the value in flight
+			s.discard(value, rec, context.Cause(ctx))
+			return
+		}
+	}
+}
+
+// All returns an iterator over the payloads of the items sent by the [Flow], acknowledging each
+// [Message] once the body of the range loop returns. If the loop breaks early, the pipeline upstream
+// is stopped: generator sources, such as [FromSeq] and [FromReader], stop generating, stages stop
+// receiving items, and the items still in flight are discarded; discarded messages are negatively
+// acknowledged with [ErrStopped]. Every other branch of the pipeline, such as those created by
+// [Flow.Tee], is stopped too. Sources reading a channel, such as [FromChannel], are drained and
+// discarded in the background until the channel is closed. Like [Flow.Out], the iterator must only
+// be used once.
+func (f Flow) All() iter.Seq[any] {
+	return func(yield func(any) bool) {
+		for item := range f.Out() {
+			more := yield(unwrap(item))
+			ack(item)
+			if !more {
+				f.stop()
+				return
+			}
+		}
+	}
+}
+
+// Seq returns an iterator over the payloads of the items sent by the flow, which must all be of
+// type T. See [Flow.All] for details.
+func Seq[T any](flow Flow) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for item := range flow.All() {
+			if !yield(item.(T)) {
+				return
+			}
+		}
+	}
+}
+
+// stop stops the pipeline upstream of the flow, cancelling its context and stopping its generator
+// sources, then discards its remaining items in the background.
+func (f Flow) stop() {
+	for _, component := range f.topology.upstream(f.node) {
+		if s, ok := component.(stopper); ok {
+			s.stop()
+		}
+	}
+	f.cancel(ErrStopped)
+	go discardAll(f.Out(), ErrStopped)
+}
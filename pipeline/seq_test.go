@@ -0,0 +1,247 @@
+package pipeline_test
+
+import (
+	"context"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+)
+
+// Naturals returns an endless sequence of the natural numbers, closing stopped once the
+// sequence is stopped.
+func Naturals(stopped chan<- struct{}) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func TestFromSeq(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sends each value", func(t *testing.T) {
+		flow := pipeline.FromSeq(slices.Values([]int{1, 2, 3}))
+		if got, want := Consume[int](flow), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("sends nothing for an empty sequence", func(t *testing.T) {
+		flow := pipeline.FromSeq(slices.Values([]int{}))
+		if got := Consume[int](flow); len(got) != 0 {
+			t.Errorf("got %v, want nothing", got)
+		}
+	})
+
+	t.Run("passes the context of the source", func(t *testing.T) {
+		var (
+			ctx, cancel = context.WithCancel(context.Background())
+			done        = make(chan struct{})
+			flow        = pipeline.FromSeqContext(func(ctx context.Context) iter.Seq[int] {
+				return func(yield func(int) bool) {
+					defer close(done)
+					if yield(1) {
+						<-ctx.Done()
+					}
+				}
+			}).WithContext(ctx)
+			out = flow.Out()
+		)
+
+		<-out
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(pipetest.DefaultTimeout):
+			t.Error("expected the context of the source to be done")
+		}
+	})
+
+	t.Run("sends each pair", func(t *testing.T) {
+		var (
+			flow = pipeline.FromSeq2(slices.All([]string{"a", "b"}))
+			want = []pipeline.KeyValue[int, string]{{Key: 0, Value: "a"}, {Key: 1, Value: "b"}}
+		)
+		if got := Consume[pipeline.KeyValue[int, string]](flow); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestSeq(t *testing.T) {
+	t.Parallel()
+
+	t.Run("yields each item", func(t *testing.T) {
+		flow := pipeline.FromSlice(1, 2, 3).
+			Thru(pipeline.Map(strconv.Itoa))
+
+		if got, want := slices.Collect(pipeline.Seq[string](flow)), []string{"1", "2", "3"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("yields the payload of messages", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			flow       = pipeline.FromSlice(deliveries.Messages(1, 2, 3)...).
+					Thru(pipeline.Map(func(i int) int { return i * 2 }))
+			got []any
+		)
+
+		for item := range flow.All() {
+			got = append(got, item)
+		}
+		if want := []any{2, 4, 6}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got, want := deliveries.Acked(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got acked %v, want %v", got, want)
+		}
+	})
+
+	t.Run("stops the sequence upstream on break", func(t *testing.T) {
+		var (
+			stopped = make(chan struct{})
+			flow    = pipeline.FromSeq(Naturals(stopped)).
+				Thru(pipeline.KeepIf(func(i int) bool { return i%2 == 0 })).
+				Thru(pipeline.Map(func(i int) int { return i * 10 }))
+			got []int
+		)
+
+		for item := range pipeline.Seq[int](flow) {
+			if len(got) == 3 {
+				break
+			}
+			got = append(got, item)
+		}
+		if want := []int{0, 20, 40}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+
+		<-stopped
+		Eventually(t, func() bool {
+			status, _ := flow.Stage("map")
+			return status.State == pipeline.StageStopped
+		})
+	})
+
+	t.Run("stops without reporting errors", func(t *testing.T) {
+		var (
+			stopped = make(chan struct{})
+			flow    = pipeline.FromSeq(Naturals(stopped))
+		)
+
+		for range flow.All() {
+			break
+		}
+
+		<-stopped
+		Eventually(t, func() bool {
+			status, _ := flow.Stage("seq")
+			return status.State == pipeline.StageStopped
+		})
+		if status, _ := flow.Stage("seq"); status.Errors != 0 {
+			t.Errorf("got %d errors, want none", status.Errors)
+		}
+	})
+
+	t.Run("drains sources that cannot be stopped", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			input      = make(chan pipeline.Message)
+			flow       = pipeline.FromChannel(input)
+			sent       = make(chan struct{})
+		)
+
+		go func() {
+			defer close(sent)
+			for _, msg := range deliveries.Messages(1, 2, 3) {
+				input <- msg
+			}
+		}()
+		for range flow.All() {
+			break
+		}
+		<-sent
+
+		// the channel keeps being drained until closed
+		for _, msg := range deliveries.Messages(4, 5) {
+			input <- msg
+		}
+		close(input)
+		Eventually(t, func() bool {
+			return reflect.DeepEqual(deliveries.Acked(), []int{1}) &&
+				reflect.DeepEqual(deliveries.Nacked(), []int{2, 3, 4, 5})
+		})
+	})
+
+	t.Run("stops the stages upstream on break", func(t *testing.T) {
+		var (
+			input = make(chan int)
+			done  = make(chan struct{})
+			flow  = pipeline.FromChannel(input).
+				Thru(pipeline.Map(func(i int) int { return i }))
+		)
+		t.Cleanup(func() { close(done) })
+		go func() {
+			for i := 0; ; i++ {
+				select {
+				case input <- i:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		for range flow.All() {
+			break
+		}
+
+		// the channel is never closed, so the stage only stops if cancelled
+		Eventually(t, func() bool {
+			status, _ := flow.Stage("map")
+			return status.State == pipeline.StageStopped
+		})
+	})
+
+	t.Run("negatively acknowledges discarded messages", func(t *testing.T) {
+		var (
+			deliveries = &Deliveries{}
+			flow       = pipeline.FromSlice(deliveries.Messages(1, 2, 3)...)
+		)
+
+		for range flow.All() {
+			break
+		}
+		Eventually(t, func() bool {
+			return reflect.DeepEqual(deliveries.Acked(), []int{1}) &&
+				reflect.DeepEqual(deliveries.Nacked(), []int{2, 3})
+		})
+	})
+
+	t.Run("composes with other iterators", func(t *testing.T) {
+		var (
+			input = map[string]int{"a": 1, "b": 2}
+			flow  = pipeline.FromSeq2(maps.All(input)).
+				Thru(pipeline.Map(func(kv pipeline.KeyValue[string, int]) string {
+					return kv.Key + "=" + strconv.Itoa(kv.Value)
+				}))
+		)
+
+		got := slices.Sorted(pipeline.Seq[string](flow))
+		if want := []string{"a=1", "b=2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
@@ -25,9 +25,13 @@ func FromSlice[In any](items ...In) Flow {
 
 func (s source) Out() <-chan any { return s.out }
 
+// Name implements [Namer].
+func (s source) Name() string { return "slice" }
+
 // sink implements a pipeline sink that collects all received items into a slice.
 // It provides synchronization capabilities to wait for and access the final slice.
 type sink[In any] struct {
+	*stage
 	// wg is used to signal when all items have been collected
 	wg sync.WaitGroup
 	// in receives items to be collected
@@ -40,8 +44,9 @@ type sink[In any] struct {
 // The slice can be accessed using the [Slice] method after the pipeline completes.
 func ToSlice[In any]() *sink[In] {
 	sink := &sink[In]{
-		wg: sync.WaitGroup{},
-		in: make(chan any),
+		stage: newStage("slice"),
+		wg:    sync.WaitGroup{},
+		in:    make(chan any),
 	}
 
 	sink.wg.Add(1)
@@ -62,7 +67,11 @@ func (s *sink[In]) Slice() []In {
 // Each received item is appended to the output slice in order.
 func (s *sink[In]) start() {
 	defer s.wg.Done()
+	defer s.finish()
 	for data := range s.in {
-		s.output = append(s.output, data.(In))
+		rec := s.receive(data)
+		s.output = append(s.output, unwrap(data).(In))
+		s.emitted(data, rec, rec.elapsed())
+		ack(data)
 	}
 }
@@ -1,6 +1,8 @@
 package pipeline
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/nisimpson/piper"
@@ -12,13 +14,16 @@ type SlidingWindowOptions struct {
 	WindowSize int
 	// StepSize is how many items to slide forward for each new window
 	StepSize int
-	// Interval is the maximum time to wait before sliding the window
+	// Interval is the maximum time to wait before sliding the window, restarting whenever an item is received
 	// To disable time-based sliding, set Interval to zero
 	Interval time.Duration
+	// Clock schedules the interval. Defaults to [SystemClock].
+	Clock Clock
 }
 
 // slidingWindow implements a pipeline component that groups items using a sliding window approach
 type slidingWindow[In any] struct {
+	*stage
 	in      chan any
 	out     chan any
 	options SlidingWindowOptions
@@ -30,6 +35,7 @@ func SlidingWindow[In any](opts ...func(*SlidingWindowOptions)) piper.Pipe {
 	options := SlidingWindowOptions{
 		WindowSize: 2,
 		StepSize:   1,
+		Clock:      SystemClock(),
 	}
 	for _, opt := range opts {
 		opt(&options)
@@ -41,8 +47,12 @@ func SlidingWindow[In any](opts ...func(*SlidingWindowOptions)) piper.Pipe {
 	if options.WindowSize < options.StepSize {
 		options.WindowSize = options.StepSize
 	}
+	if options.Clock == nil {
+		options.Clock = SystemClock()
+	}
 
 	pipe := slidingWindow[In]{
+		stage:   newStage(fmt.Sprintf("sliding_window(%d, %d)", options.WindowSize, options.StepSize)),
 		in:      make(chan any),
 		out:     make(chan any),
 		options: options,
@@ -57,64 +67,157 @@ func (sw slidingWindow[In]) Out() <-chan any { return sw.out }
 
 func (sw slidingWindow[In]) start() {
 	defer close(sw.out)
-
-	// buffer stores all items that might be needed for future windows
-	buffer := make([]In, 0, sw.options.WindowSize)
-
-	if sw.options.Interval == 0 {
+	defer sw.finish()
+
+	var (
+		// buffer stores all items that might be needed for future windows
+		buffer = make([]In, 0, sw.options.WindowSize)
+		// members stores the received items in buffer order, including any envelopes
+		members = make([]member, 0, sw.options.WindowSize)
+		// tail settles the members still in the last window sent, if any, once the input closes
+		tail *windowTail
+	)
+
+	if sw.options.Interval <= 0 {
 		for {
 			input, ok := <-sw.in
 			if !ok {
-				sw.emitRemainingWindows(buffer)
+				sw.emitRemainingWindows(buffer, members, tail)
 				return
 			}
 
-			buffer = append(buffer, input.(In))
+			members = append(members, member{item: input, receipt: sw.receive(input)})
+			buffer = append(buffer, unwrap(input).(In))
 			if len(buffer) >= sw.options.WindowSize {
-				// Create and send the current window
-				window := make([]In, sw.options.WindowSize)
-				copy(window, buffer)
-				sw.out <- window
-
-				// Slide the window forward
-				buffer = buffer[sw.options.StepSize:]
+				// Send the current window and slide it forward
+				buffer, members, tail = sw.slide(buffer, members)
 			}
 		}
 	}
 
+	timer := sw.options.Clock.NewTimer(sw.options.Interval)
+	defer timer.Stop()
+
 	for {
 		select {
 		case input, ok := <-sw.in:
 			if !ok {
-				sw.emitRemainingWindows(buffer)
+				sw.emitRemainingWindows(buffer, members, tail)
 				return
 			}
 
-			buffer = append(buffer, input.(In))
+			members = append(members, member{item: input, receipt: sw.receive(input)})
+			buffer = append(buffer, unwrap(input).(In))
 			if len(buffer) >= sw.options.WindowSize {
-				window := make([]In, sw.options.WindowSize)
-				copy(window, buffer)
-				sw.out <- window
-				buffer = buffer[sw.options.StepSize:]
+				buffer, members, tail = sw.slide(buffer, members)
 			}
 
-		case <-time.After(sw.options.Interval):
+		case <-timer.C():
 			if len(buffer) >= sw.options.WindowSize {
-				window := make([]In, sw.options.WindowSize)
-				copy(window, buffer)
-				sw.out <- window
-				buffer = buffer[sw.options.StepSize:]
+				buffer, members, tail = sw.slide(buffer, members)
 			}
 		}
+		if restart(timer, sw.options.Interval) && len(buffer) >= sw.options.WindowSize {
+			buffer, members, tail = sw.slide(buffer, members)
+		}
+	}
+}
+
+// slide sends the current window downstream, then slides the window forward by StepSize items.
+// Settling the window settles the items that slide out of it, which are fully processed.
+func (sw slidingWindow[In]) slide(buffer []In, members []member) ([]In, []member, *windowTail) {
+	window := make([]In, sw.options.WindowSize)
+	copy(window, buffer)
+
+	var (
+		tail      = &windowTail{}
+		items     = make([]any, 0, sw.options.StepSize+1)
+		latencies = make([]time.Duration, sw.options.StepSize)
+	)
+	for i, m := range members[:sw.options.StepSize] {
+		items = append(items, m.item)
+		latencies[i] = m.receipt.elapsed()
+	}
+	for _, m := range members[:sw.options.WindowSize] {
+		if _, ok := m.item.(Message); ok {
+			items = append(items, tail.message())
+			break
+		}
+	}
+
+	sw.push(sw.out, merge(items, window))
+	for i, m := range members[:sw.options.StepSize] {
+		sw.emitted(m.item, m.receipt, latencies[i])
 	}
+	return buffer[sw.options.StepSize:], members[sw.options.StepSize:], tail
 }
 
-func (sw slidingWindow[In]) emitRemainingWindows(buffer []In) {
+func (sw slidingWindow[In]) emitRemainingWindows(buffer []In, members []member, tail *windowTail) {
 	// Emit any remaining complete windows
 	for len(buffer) >= sw.options.WindowSize {
-		window := make([]In, sw.options.WindowSize)
-		copy(window, buffer)
-		sw.out <- window
-		buffer = buffer[sw.options.StepSize:]
+		buffer, members, tail = sw.slide(buffer, members)
+	}
+
+	// Items that were part of the last window are fully processed, and are settled with it;
+	// the rest never made it into a window and are dropped.
+	windowed := 0
+	if tail != nil {
+		windowed = min(len(members), sw.options.WindowSize-sw.options.StepSize)
+	}
+	items := make([]any, windowed)
+	for i, m := range members[:windowed] {
+		items[i] = m.item
+		sw.emitted(m.item, m.receipt, m.receipt.elapsed())
+	}
+	if tail != nil {
+		tail.join(items)
+	}
+	for _, m := range members[windowed:] {
+		sw.drop(m.item, m.receipt)
+	}
+}
+
+// windowTail settles the items that remain in a window when the input closes, once the window
+// is settled. Items joining a window that is already settled are settled immediately.
+type windowTail struct {
+	mu      sync.Mutex
+	settled bool
+	err     error
+	items   []any
+}
+
+// message returns a [Message] settling the tail, to be merged into the envelope of the window.
+func (t *windowTail) message() Message {
+	return NewMessage(nil, func() { t.settle(nil) }, t.settle)
+}
+
+func (t *windowTail) settle(err error) {
+	t.mu.Lock()
+	items := t.items
+	t.settled, t.err, t.items = true, err, nil
+	t.mu.Unlock()
+	settleAll(items, err)
+}
+
+func (t *windowTail) join(items []any) {
+	t.mu.Lock()
+	if !t.settled {
+		t.items = append(t.items, items...)
+		t.mu.Unlock()
+		return
+	}
+	err := t.err
+	t.mu.Unlock()
+	settleAll(items, err)
+}
+
+// settleAll acknowledges each item, or negatively acknowledges them with err if not nil.
+func settleAll(items []any, err error) {
+	for _, item := range items {
+		if err != nil {
+			nack(item, err)
+		} else {
+			ack(item)
+		}
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
 )
 
 func TestSlidingWindow(t *testing.T) {
@@ -93,45 +94,23 @@ func TestSlidingWindow(t *testing.T) {
 	})
 
 	t.Run("with interval", func(t *testing.T) {
-		// Create a sliding window with size 3 and step 1
-		pipe := pipeline.SlidingWindow[int](func(o *pipeline.SlidingWindowOptions) {
+		// Create a sliding window with size 3 and step 1, ticking on a fake clock
+		clock := pipetest.NewFakeClock(time.Time{})
+		h := pipetest.NewHarness(t, pipeline.SlidingWindow[int](func(o *pipeline.SlidingWindowOptions) {
 			o.WindowSize = 3
 			o.StepSize = 1
 			o.Interval = 20 * time.Millisecond
-		})
-
-		// Send input
-		in := pipe.In()
-		go func() {
-			for i := 1; i <= 5; i++ {
-				in <- i
-				time.Sleep(30 * time.Millisecond)
-			}
-			close(in)
-		}()
-
-		var (
-			want = [][]int{
-				{1, 2, 3},
-				{2, 3, 4},
-				{3, 4, 5},
-			}
-			got = make([][]int, 0)
-		)
-
-		// Receive windows
-		for window := range pipe.Out() {
-			got = append(got, window.([]int))
+			o.Clock = clock
+		}))
+
+		// Ticks between items do not slide incomplete windows
+		clock.BlockUntil(1)
+		for i := 1; i <= 5; i++ {
+			h.Send(i)
+			clock.Advance(30 * time.Millisecond)
 		}
 
-		if len(got) != len(want) {
-			t.Fatalf("got %v, want %v", got, want)
-			return
-		}
-
-		if !reflect.DeepEqual(got, want) {
-			t.Fatalf("got %v, want %v", got, want)
-			return
-		}
+		h.Close()
+		h.Expect([]int{1, 2, 3}, []int{2, 3, 4}, []int{3, 4, 5})
 	})
 }
@@ -0,0 +1,44 @@
+/*
+Package spec builds pipelines from declarative YAML or JSON definitions, so that simple
+pipelines can be described without writing Go.
+
+A spec describes a source, a sequence of stages and a sink, each identified by its type
+and configured with its parameters:
+
+	name: uppercase
+	source:
+	  type: slice
+	  items: [a, b, c]
+	stages:
+	  - type: shell
+	    command: [tr, a-z, A-Z]
+	  - type: map
+	    func: exclaim
+	sink:
+	  type: stdout
+
+The supported components are:
+
+  - sources: slice (items), channel (name), http (method, url, body) and cmd (command)
+  - stages: batch (size, interval), take (count), drop (count), unique, sliding_window
+    (size, step, interval), limit (rate, burst), http (method, url), shell (command),
+    map (func) and filter (func)
+  - sinks: null, stdout and channel (name)
+
+Shell stages write each item to the standard input of their command, as a line, and send its
+output downstream. Items whose command fails are dropped, and reported to [BuildOptions.HandleError].
+
+Functions and channels are referenced by name, and registered by the application:
+
+	registry := spec.NewRegistry()
+	spec.RegisterMap(registry, "exclaim", func(s string) string { return s + "!" })
+
+	p, err := spec.Load(data, registry)
+	if err != nil {
+		// each problem is reported at its line, such as
+		// line 9: unknown map function "exclam"
+		log.Fatal(err)
+	}
+	p.Wait()
+*/
+package spec
@@ -0,0 +1,52 @@
+package spec_test
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nisimpson/piper/pipeline/spec"
+)
+
+// ExampleLoad demonstrates building a pipeline from a YAML spec
+func ExampleLoad() {
+	registry := spec.NewRegistry()
+	spec.RegisterMap(registry, "shout", func(s string) string { return strings.ToUpper(s) + "!" })
+
+	p, err := spec.Load([]byte(`
+name: shout
+source:
+  type: slice
+  items: [hello, world]
+stages:
+  - type: map
+    func: shout
+sink:
+  type: stdout
+`), registry)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	p.Wait()
+	// Output:
+	// HELLO!
+	// WORLD!
+}
+
+// ExampleParse demonstrates the validation errors reported for an invalid spec
+func ExampleParse() {
+	_, err := spec.Parse([]byte(`
+source:
+  type: slice
+stages:
+  - type: take
+    count: -1
+sink:
+  type: console
+`))
+	fmt.Println(err)
+	// Output:
+	// line 6: stage "take": count must not be negative
+	// line 8: unknown sink type "console"; expected one of channel, null, stdout
+}
@@ -0,0 +1,17 @@
+module github.com/nisimpson/piper/pipeline/spec
+
+go 1.23.2
+
+require (
+	github.com/nisimpson/piper v0.3.0
+	github.com/nisimpson/piper/throttle v0.2.0
+	golang.org/x/time v0.9.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+// The required releases are tagged in one series by `make release`. Build against the modules
+// of this repository, so that a checkout builds as a whole.
+replace (
+	github.com/nisimpson/piper => ../..
+	github.com/nisimpson/piper/throttle => ../../throttle
+)
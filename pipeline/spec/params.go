@@ -0,0 +1,147 @@
+package spec
+
+import "time"
+
+// params are the decoded parameters of a component. Each field is decoded from the
+// document key named by its yaml tag.
+type params interface {
+	// validate returns the offending field and a description of the problem,
+	// or an empty description if the parameters are valid.
+	validate() (field string, msg string)
+}
+
+// noParams are the parameters of components without parameters.
+type noParams struct{}
+
+func (*noParams) validate() (string, string) { return "", "" }
+
+// sliceParams are the parameters of the slice source.
+type sliceParams struct {
+	// Items are the items sent by the source.
+	Items []any `yaml:"items"`
+}
+
+func (*sliceParams) validate() (string, string) { return "", "" }
+
+// channelParams are the parameters of the channel source and sink.
+type channelParams struct {
+	// Name is the name of the channel, registered with [RegisterChannel].
+	Name string `yaml:"name"`
+}
+
+func (p *channelParams) validate() (string, string) {
+	if p.Name == "" {
+		return "name", "name is required"
+	}
+	return "", ""
+}
+
+// httpParams are the parameters of the http source and stage.
+type httpParams struct {
+	// Method is the method of the requests.
+	Method string `yaml:"method"`
+	// URL is the target of the requests.
+	URL string `yaml:"url"`
+	// Body is the body of the request sent by the source.
+	Body string `yaml:"body"`
+}
+
+func (p *httpParams) validate() (string, string) {
+	if p.URL == "" {
+		return "url", "url is required"
+	}
+	return "", ""
+}
+
+// commandParams are the parameters of the cmd source and shell stage.
+type commandParams struct {
+	// Command is the program to execute, followed by its arguments. Shell stages write each
+	// item to its standard input.
+	Command []string `yaml:"command"`
+}
+
+func (p *commandParams) validate() (string, string) {
+	if len(p.Command) == 0 {
+		return "command", "command is required"
+	}
+	return "", ""
+}
+
+// batchParams are the parameters of the batch stage.
+type batchParams struct {
+	// Size is the maximum size of a batch. Batches are unbounded if Size is not positive.
+	Size int `yaml:"size"`
+	// Interval is the maximum time to wait before sending a batch.
+	Interval time.Duration `yaml:"interval"`
+}
+
+func (p *batchParams) validate() (string, string) {
+	if p.Size < 1 && p.Interval <= 0 {
+		return "size", "size or interval is required"
+	}
+	return "", ""
+}
+
+// countParams are the parameters of the take and drop stages.
+type countParams struct {
+	// Count is the number of items to take or drop.
+	Count int `yaml:"count"`
+}
+
+func (p *countParams) validate() (string, string) {
+	if p.Count < 0 {
+		return "count", "count must not be negative"
+	}
+	return "", ""
+}
+
+// windowParams are the parameters of the sliding_window stage.
+type windowParams struct {
+	// Size is the size of the window.
+	Size int `yaml:"size"`
+	// Step is the number of items the window slides forward.
+	Step int `yaml:"step"`
+	// Interval is the maximum time to wait before sliding the window.
+	Interval time.Duration `yaml:"interval"`
+}
+
+func (p *windowParams) validate() (string, string) {
+	if p.Size < 1 {
+		return "size", "size must be positive"
+	}
+	if p.Step < 1 {
+		return "step", "step must be positive"
+	}
+	return "", ""
+}
+
+// limitParams are the parameters of the limit stage.
+type limitParams struct {
+	// Rate is the number of items allowed per second.
+	Rate float64 `yaml:"rate"`
+	// Burst is the number of items allowed at once.
+	Burst int `yaml:"burst"`
+}
+
+func (p *limitParams) validate() (string, string) {
+	if p.Rate <= 0 {
+		return "rate", "rate must be positive"
+	}
+	if p.Burst < 1 {
+		return "burst", "burst must be positive"
+	}
+	return "", ""
+}
+
+// funcParams are the parameters of the map and filter stages.
+type funcParams struct {
+	// Func is the name of the function, registered with [RegisterMap] or [RegisterFilter].
+	Func string `yaml:"func"`
+}
+
+func (p *funcParams) validate() (string, string) {
+	if p.Func == "" {
+		return "func", "func is required"
+	}
+	return "", ""
+}
@@ -0,0 +1,423 @@
+package spec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/throttle"
+	"golang.org/x/time/rate"
+)
+
+// Registry holds the functions and channels referenced by name in pipeline specs,
+// and builds specs into running pipelines. Register functions and channels before
+// building any spec; a Registry is not safe for concurrent registration.
+type Registry struct {
+	// maps are the factories of the registered map stages.
+	maps map[string]func() piper.Pipe
+	// filters are the factories of the registered filter stages.
+	filters map[string]func() piper.Pipe
+	// sources are the factories of the registered channel sources.
+	sources map[string]func() pipeline.Flow
+	// sinks are the factories of the registered channel sinks, returning the sink
+	// along with a function waiting for it to finish.
+	sinks map[string]func() (piper.Sink, func())
+}
+
+// NewRegistry creates a new empty [Registry].
+func NewRegistry() *Registry {
+	return &Registry{
+		maps:    make(map[string]func() piper.Pipe),
+		filters: make(map[string]func() piper.Pipe),
+		sources: make(map[string]func() pipeline.Flow),
+		sinks:   make(map[string]func() (piper.Sink, func())),
+	}
+}
+
+// RegisterMap registers the function under the name, for use by "map" stages. The items
+// received by the stage must be of type In; items decoded from a spec, such as the items
+// of a "slice" source, have the types decoded by YAML (int, float64, string, bool,
+// []any or map[string]any).
+func RegisterMap[In any, Out any](r *Registry, name string, fn pipeline.MapFunction[In, Out]) {
+	r.maps[name] = func() piper.Pipe { return pipeline.Named(name, pipeline.Map(fn)) }
+}
+
+// RegisterFilter registers the function under the name, for use by "filter" stages. Items
+// for which the function returns true are kept. See [RegisterMap] for the types of the items.
+func RegisterFilter[In any](r *Registry, name string, fn pipeline.FilterFunction[In]) {
+	r.filters[name] = func() piper.Pipe { return pipeline.Named(name, pipeline.KeepIf(fn)) }
+}
+
+// RegisterChannel registers the channel under the name, for use by "channel" sources and sinks.
+// A channel source reads items from ch until it is closed; a channel sink sends items to ch,
+// and closes it once the pipeline is done.
+func RegisterChannel[T any](r *Registry, name string, ch chan T) {
+	r.sources[name] = func() pipeline.Flow { return pipeline.FromChannel(ch) }
+	r.sinks[name] = func() (piper.Sink, func()) {
+		var (
+			relay = make(chan T)
+			done  = make(chan struct{})
+		)
+		go func() {
+			defer close(done)
+			defer close(ch)
+			for item := range relay {
+				ch <- item
+			}
+		}()
+		return pipeline.ToChannel(relay), func() { <-done }
+	}
+}
+
+// BuildOptions configure how a [Spec] is built into a [Pipeline].
+type BuildOptions struct {
+	// Context is the context of the pipeline, used for cancellation. Once it is done, the items in
+	// flight are discarded. Defaults to [context.Background].
+	Context context.Context
+	// Stop, if set, stops reading the source once done. Unlike Context, it does not cancel the
+	// stages, which drain the items already read before the pipeline ends.
+	Stop context.Context
+	// Stdout is the writer of the "stdout" sink. Defaults to [os.Stdout].
+	Stdout io.Writer
+	// Stderr receives the standard error of the commands run by "cmd" sources and "shell"
+	// stages. Defaults to [os.Stderr].
+	Stderr io.Writer
+	// HandleError is called with the errors of the components while the pipeline runs, such
+	// as commands exiting with a non-zero status or failed HTTP requests; the failed items are
+	// dropped. Defaults to writing each error to Stderr, on its own line.
+	HandleError func(error)
+	// DryRun builds the pipeline without running it, to inspect its topology with
+	// [pipeline.Flow.Graph]: sources send no items, and channel sinks are replaced by sinks of
+	// the same name, so no command is run, no request is sent and no channel is read or closed.
+	DryRun bool
+}
+
+// Pipeline is a running pipeline built from a [Spec].
+type Pipeline struct {
+	// Name is the name of the spec.
+	Name string
+	// flow ends with the last stage of the pipeline.
+	flow pipeline.Flow
+	// wait waits for the sink to finish.
+	wait func()
+}
+
+// Flow returns the [pipeline.Flow] ending with the last stage of the pipeline, to query
+// its [pipeline.Flow.Graph] or [pipeline.Flow.Stages].
+func (p *Pipeline) Flow() pipeline.Flow { return p.flow }
+
+// Wait blocks until the sink has consumed every item of the pipeline.
+func (p *Pipeline) Wait() { p.wait() }
+
+// Load parses the spec from the YAML or JSON document with [Parse], and builds it with the registry.
+func Load(data []byte, r *Registry, opts ...func(*BuildOptions)) (*Pipeline, error) {
+	s, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return r.Build(s, opts...)
+}
+
+// Build builds the spec into a running [Pipeline], configured with the provided option functions.
+// Every component is resolved before any is started; the returned error joins an [*Error] for each
+// component referencing a function or channel missing from the registry.
+func (r *Registry) Build(s *Spec, opts ...func(*BuildOptions)) (*Pipeline, error) {
+	options := BuildOptions{
+		Context: context.Background(),
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.HandleError == nil {
+		options.HandleError = func(err error) { fmt.Fprintln(options.Stderr, err) }
+	}
+
+	b := builder{registry: r, options: options}
+
+	var (
+		source = b.source(s.Source)
+		stages = make([]func() piper.Pipe, 0, len(s.Stages))
+		sink   = b.sink(s.Sink)
+	)
+	for _, c := range s.Stages {
+		stages = append(stages, b.stage(c))
+	}
+
+	if err := errors.Join(b.errs...); err != nil {
+		return nil, err
+	}
+
+	flow := source().WithContext(options.Context)
+	for _, stage := range stages {
+		flow = flow.Thru(stage())
+	}
+
+	out, wait := sink()
+	flow.To(out)
+	return &Pipeline{Name: s.Name, flow: flow, wait: wait}, nil
+}
+
+// builder resolves the components of a spec into factories, collecting the problems found.
+type builder struct {
+	registry *Registry
+	options  BuildOptions
+	errs     []error
+}
+
+// fail records a problem with the field of the component.
+func (b *builder) fail(c Component, field string, format string, args ...any) {
+	line, ok := c.lines[field]
+	if !ok {
+		line = c.Line
+	}
+	b.errs = append(b.errs, &Error{Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+// source resolves the source component.
+func (b *builder) source(c Component) func() pipeline.Flow {
+	switch p := c.params.(type) {
+	case *sliceParams:
+		return b.start("slice", func() pipeline.Flow { return pipeline.FromSlice(p.Items...) })
+	case *channelParams:
+		source, ok := b.registry.sources[p.Name]
+		if !ok {
+			b.fail(c, "name", "unknown channel %q", p.Name)
+		}
+		return b.start("channel", source)
+	case *httpParams:
+		return b.start(pipeline.HttpName(p.Method, p.URL), func() pipeline.Flow {
+			return pipeline.FromHTTP(p.Method, p.URL, strings.NewReader(p.Body), b.readBody)
+		})
+	case *commandParams:
+		return b.start("command", func() pipeline.Flow {
+			return pipeline.FromCmd(b.shell(p.Command), b.handleCommandError)
+		})
+	default:
+		b.fail(c, "type", "invalid source")
+		return nil
+	}
+}
+
+// start returns the factory of the source, or of a source named name sending no items
+// if the pipeline is a dry run. Sources are read until the Stop context, if any, is done.
+func (b *builder) start(name string, source func() pipeline.Flow) func() pipeline.Flow {
+	switch {
+	case b.options.DryRun:
+		return func() pipeline.Flow { return pipeline.From(idleSource(name)) }
+	case b.options.Stop != nil && source != nil:
+		return func() pipeline.Flow {
+			return pipeline.From(newStoppableSource(name, source(), b.options.Stop))
+		}
+	default:
+		return source
+	}
+}
+
+// stage resolves the stage component.
+func (b *builder) stage(c Component) func() piper.Pipe {
+	switch p := c.params.(type) {
+	case *batchParams:
+		return func() piper.Pipe {
+			return pipeline.Batch[any](func(bo *pipeline.BatcherOptions) {
+				bo.MaxSize = p.Size
+				bo.Interval = p.Interval
+			})
+		}
+	case *countParams:
+		if c.Type == "drop" {
+			return func() piper.Pipe { return pipeline.DropN(p.Count) }
+		}
+		return func() piper.Pipe { return pipeline.TakeN(p.Count) }
+	case *noParams:
+		return func() piper.Pipe { return pipeline.Unique[any]() }
+	case *windowParams:
+		return func() piper.Pipe {
+			return pipeline.SlidingWindow[any](func(so *pipeline.SlidingWindowOptions) {
+				so.WindowSize = p.Size
+				so.StepSize = p.Step
+				so.Interval = p.Interval
+			})
+		}
+	case *limitParams:
+		return func() piper.Pipe {
+			limiter := rate.NewLimiter(rate.Limit(p.Rate), p.Burst)
+			return pipeline.Named(fmt.Sprintf("limit(%g/s)", p.Rate), throttle.Limit(b.options.Context, limiter))
+		}
+	case *httpParams:
+		return func() piper.Pipe { return pipeline.SendHTTP(p.Method, p.URL, b.readBody) }
+	case *commandParams:
+		return func() piper.Pipe { return pipeline.ExecCmd(b.shell(p.Command), b.handleCommandError) }
+	case *funcParams:
+		functions := b.registry.maps
+		if c.Type == "filter" {
+			functions = b.registry.filters
+		}
+		stage, ok := functions[p.Func]
+		if !ok {
+			b.fail(c, "func", "unknown %s function %q", c.Type, p.Func)
+		}
+		return stage
+	default:
+		b.fail(c, "type", "invalid stage")
+		return nil
+	}
+}
+
+// sink resolves the sink component.
+func (b *builder) sink(c Component) func() (piper.Sink, func()) {
+	switch p := c.params.(type) {
+	case *noParams:
+		if c.Type == "stdout" {
+			return func() (piper.Sink, func()) {
+				sink := pipeline.ToWriter[any](b.options.Stdout, nil, func(o *pipeline.WriterOptions) {
+					o.HandleError = b.options.HandleError
+				})
+				return namedSink{sink, "stdout"}, func() { sink.Wait() }
+			}
+		}
+		return func() (piper.Sink, func()) {
+			sink := pipeline.ToNull()
+			return sink, sink.Wait
+		}
+	case *channelParams:
+		sink, ok := b.registry.sinks[p.Name]
+		if !ok {
+			b.fail(c, "name", "unknown channel %q", p.Name)
+		}
+		if b.options.DryRun {
+			return func() (piper.Sink, func()) {
+				sink := newDiscardSink("channel")
+				return sink, sink.Wait
+			}
+		}
+		return sink
+	default:
+		b.fail(c, "type", "invalid sink")
+		return nil
+	}
+}
+
+// readBody is a [pipeline.HttpPipeOptions] function sending the body of each response
+// downstream as a string, and reporting failed requests.
+func (b *builder) readBody(o *pipeline.HttpPipeOptions) {
+	o.HandleError = b.options.HandleError
+	o.HandleResponse = func(res *http.Response) (any, error) {
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		return string(body), err
+	}
+}
+
+// shell returns a [pipeline.Command] running the program with its arguments for each item,
+// writing the item to its standard input as a line. The trailing newline of the output is
+// removed. Commands exiting with a non-zero status fail with an error naming the program.
+func (b *builder) shell(args []string) pipeline.Command[any, string] {
+	return pipeline.CommandFunc(func(input any) (string, int, error) {
+		cmd := exec.Command(args[0], args[1:]...)
+		if input != nil {
+			cmd.Stdin = strings.NewReader(fmt.Sprintln(input))
+		}
+		cmd.Stderr = b.options.Stderr
+		out, err := cmd.Output()
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return "", exit.ExitCode(), fmt.Errorf("%s: %w", args[0], err)
+		}
+		return strings.TrimSuffix(string(out), "\n"), 0, err
+	})
+}
+
+// handleCommandError is a [pipeline.CommandPipeOptions] function reporting failed commands.
+func (b *builder) handleCommandError(o *pipeline.CommandPipeOptions[string]) {
+	o.HandleError = b.options.HandleError
+}
+
+// namedSink is a [piper.Sink] named after the sink component it implements.
+type namedSink struct {
+	piper.Sink
+	name string
+}
+
+// Name implements [pipeline.Namer].
+func (s namedSink) Name() string { return s.name }
+
+// idleSource is a [piper.Source] named after the source it replaces in a dry run, sending no items.
+type idleSource string
+
+func (s idleSource) Out() <-chan any {
+	out := make(chan any)
+	close(out)
+	return out
+}
+
+// Name implements [pipeline.Namer].
+func (s idleSource) Name() string { return string(s) }
+
+// stoppableSource is a [piper.Source] named after the source it relays, until a context is done.
+type stoppableSource struct {
+	name string
+	out  chan any
+}
+
+// newStoppableSource creates a new stoppableSource relaying the items of the source until stop
+// is done. The source is then no longer read, and the output is closed once the item being
+// relayed, if any, is sent.
+func newStoppableSource(name string, source piper.Source, stop context.Context) stoppableSource {
+	s := stoppableSource{name: name, out: make(chan any)}
+	go func() {
+		defer close(s.out)
+		in := source.Out()
+		for {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					return
+				}
+				s.out <- item
+			case <-stop.Done():
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func (s stoppableSource) Out() <-chan any { return s.out }
+
+// Name implements [pipeline.Namer].
+func (s stoppableSource) Name() string { return s.name }
+
+// discardSink is a [piper.Sink] named after the sink it replaces in a dry run, discarding its items.
+type discardSink struct {
+	name string
+	in   chan any
+	done chan struct{}
+}
+
+// newDiscardSink creates a new discardSink with the given name.
+func newDiscardSink(name string) discardSink {
+	sink := discardSink{name: name, in: make(chan any), done: make(chan struct{})}
+	go func() {
+		defer close(sink.done)
+		for range sink.in {
+		}
+	}()
+	return sink
+}
+
+func (s discardSink) In() chan<- any { return s.in }
+
+// Name implements [pipeline.Namer].
+func (s discardSink) Name() string { return s.name }
+
+// Wait blocks until the input of the sink is closed.
+func (s discardSink) Wait() { <-s.done }
@@ -0,0 +1,351 @@
+package spec_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nisimpson/piper/pipeline/spec"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	t.Run("builds registered functions and channels", func(t *testing.T) {
+		var (
+			registry = spec.NewRegistry()
+			input    = make(chan int, 4)
+			output   = make(chan int)
+		)
+
+		spec.RegisterMap(registry, "double", func(i int) int { return i * 2 })
+		spec.RegisterFilter(registry, "small", func(i int) bool { return i < 6 })
+		spec.RegisterChannel(registry, "input", input)
+		spec.RegisterChannel(registry, "output", output)
+
+		p, err := spec.Load([]byte(`
+source: {type: channel, name: input}
+stages:
+  - {type: map, func: double}
+  - {type: filter, func: small}
+sink: {type: channel, name: output}
+`), registry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 1; i <= 4; i++ {
+			input <- i
+		}
+		close(input)
+
+		var got []int
+		for i := range output {
+			got = append(got, i)
+		}
+		p.Wait()
+
+		if want := []int{2, 4}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+
+		var names []string
+		for _, node := range p.Flow().Graph().Nodes {
+			names = append(names, node.Name)
+		}
+		if want := []string{"channel", "double", "small", "channel"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("got nodes %v, want %v", names, want)
+		}
+	})
+
+	t.Run("drains the items read once stopped", func(t *testing.T) {
+		var (
+			registry    = spec.NewRegistry()
+			input       = make(chan int)
+			output      = make(chan int, 1)
+			started     = make(chan struct{})
+			release     = make(chan struct{})
+			ctx, cancel = context.WithCancel(context.Background())
+		)
+		defer close(input)
+
+		spec.RegisterMap(registry, "slow", func(i int) int {
+			close(started)
+			<-release
+			return i
+		})
+		spec.RegisterChannel(registry, "input", input)
+		spec.RegisterChannel(registry, "output", output)
+
+		p, err := spec.Load([]byte(`
+source: {type: channel, name: input}
+stages:
+  - {type: map, func: slow}
+sink: {type: channel, name: output}
+`), registry, func(bo *spec.BuildOptions) {
+			bo.Stop = ctx
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// stop reading while the input remains open, with an item in flight
+		input <- 1
+		<-started
+		cancel()
+		close(release)
+		p.Wait()
+
+		if got, ok := <-output; !ok || got != 1 {
+			t.Errorf("got %v, want the item in flight", got)
+		}
+	})
+
+	t.Run("writes to stdout", func(t *testing.T) {
+		var (
+			registry = spec.NewRegistry()
+			stdout   = &bytes.Buffer{}
+		)
+
+		p, err := spec.Load([]byte(`
+source: {type: slice, items: [a, b, b, c, d]}
+stages:
+  - {type: unique}
+  - {type: drop, count: 1}
+  - {type: limit, rate: 1000}
+  - {type: sliding_window, size: 2}
+  - {type: batch, size: 2}
+  - {type: take, count: 1}
+sink: {type: stdout}
+`), registry, func(bo *spec.BuildOptions) {
+			bo.Stdout = stdout
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p.Wait()
+
+		if got, want := stdout.String(), "[[b c] [c d]]\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("sends http requests", func(t *testing.T) {
+		var (
+			registry = spec.NewRegistry()
+			stdout   = &bytes.Buffer{}
+			server   = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				w.Write(bytes.ToUpper(body))
+			}))
+		)
+
+		defer server.Close()
+
+		p, err := spec.Load([]byte(`
+source: {type: http, method: POST, url: `+server.URL+`, body: hello}
+stages:
+  - {type: http, url: `+server.URL+`}
+sink: {type: stdout}
+`), registry, func(bo *spec.BuildOptions) {
+			bo.Stdout = stdout
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p.Wait()
+
+		if got, want := stdout.String(), "\"HELLO\"\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("runs shell commands", func(t *testing.T) {
+		if os.Getenv("SKIP_SHELL_TESTS") != "" {
+			t.Skip("skipping shell tests")
+		}
+
+		var (
+			registry = spec.NewRegistry()
+			stdout   = &bytes.Buffer{}
+			errs     []error
+		)
+
+		spec.RegisterMap(registry, "exclaim", func(s string) string { return s + "!" })
+
+		// the example of the package documentation
+		p, err := spec.Load([]byte(`
+name: uppercase
+source:
+  type: slice
+  items: [a, b, c]
+stages:
+  - type: shell
+    command: [tr, a-z, A-Z]
+  - type: map
+    func: exclaim
+sink:
+  type: stdout
+`), registry, func(bo *spec.BuildOptions) {
+			bo.Stdout = stdout
+			bo.HandleError = func(err error) { errs = append(errs, err) }
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p.Wait()
+
+		if got, want := stdout.String(), "A!\nB!\nC!\n"; got != want || len(errs) != 0 {
+			t.Errorf("got %q and errors %v, want %q", got, errs, want)
+		}
+	})
+
+	t.Run("runs shell sources", func(t *testing.T) {
+		if os.Getenv("SKIP_SHELL_TESTS") != "" {
+			t.Skip("skipping shell tests")
+		}
+
+		var (
+			registry = spec.NewRegistry()
+			stdout   = &bytes.Buffer{}
+		)
+
+		p, err := spec.Load([]byte(`
+source: {type: cmd, command: [echo, hello]}
+stages:
+  - {type: shell, command: [cat]}
+sink: {type: stdout}
+`), registry, func(bo *spec.BuildOptions) {
+			bo.Stdout = stdout
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p.Wait()
+
+		if got, want := stdout.String(), "hello\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("reports failed commands", func(t *testing.T) {
+		if os.Getenv("SKIP_SHELL_TESTS") != "" {
+			t.Skip("skipping shell tests")
+		}
+
+		var (
+			registry = spec.NewRegistry()
+			stdout   = &bytes.Buffer{}
+			stderr   = &bytes.Buffer{}
+		)
+
+		p, err := spec.Load([]byte(`
+source: {type: slice, items: [a, b]}
+stages:
+  - {type: shell, command: [grep, b]}
+sink: {type: stdout}
+`), registry, func(bo *spec.BuildOptions) {
+			bo.Stdout = stdout
+			bo.Stderr = stderr
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p.Wait()
+
+		if got, want := stdout.String(), "b\n"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+		if got, want := stderr.String(), "grep: exit status 1\n"; got != want {
+			t.Errorf("got stderr %q, want %q", got, want)
+		}
+	})
+
+	t.Run("builds dry runs without running components", func(t *testing.T) {
+		var (
+			registry = spec.NewRegistry()
+			input    = make(chan int, 1)
+			output   = make(chan int)
+			requests atomic.Int32
+			server   = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+			}))
+		)
+
+		defer server.Close()
+		spec.RegisterChannel(registry, "input", input)
+		spec.RegisterChannel(registry, "output", output)
+		input <- 1
+
+		sources := map[string]string{
+			"{type: channel, name: input}":                "channel",
+			"{type: http, url: " + server.URL + "/items}": "http GET /items",
+			"{type: cmd, command: [false]}":               "command",
+		}
+		for source, name := range sources {
+			p, err := spec.Load([]byte(`
+source: `+source+`
+stages:
+  - {type: http, url: `+server.URL+`}
+sink: {type: channel, name: output}
+`), registry, func(bo *spec.BuildOptions) {
+				bo.DryRun = true
+				bo.HandleError = func(err error) { t.Errorf("unexpected error %v", err) }
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Wait()
+
+			var names []string
+			for _, node := range p.Flow().Graph().Nodes {
+				names = append(names, node.Name)
+			}
+			if want := []string{name, "http POST /", "channel"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("got nodes %v, want %v", names, want)
+			}
+		}
+
+		if requests.Load() != 0 || len(input) != 1 {
+			t.Errorf("got %d requests and %d items left, want none sent and read", requests.Load(), len(input))
+		}
+		select {
+		case <-output:
+			t.Errorf("output channel is closed")
+		default:
+		}
+	})
+
+	t.Run("reports unregistered names at their line", func(t *testing.T) {
+		_, err := spec.Load([]byte(`
+source:
+  type: channel
+  name: input
+stages:
+  - type: map
+    func: double
+sink:
+  type: null
+`), spec.NewRegistry())
+
+		want := []spec.Error{
+			{Line: 4, Msg: `unknown channel "input"`},
+			{Line: 7, Msg: `unknown map function "double"`},
+		}
+
+		if got := Problems(err); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
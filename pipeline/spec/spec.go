@@ -0,0 +1,300 @@
+package spec
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes a pipeline: a source, followed by a sequence of stages, ending in a sink.
+// Specs are parsed from YAML or JSON documents with [Parse], and built with [Registry.Build].
+type Spec struct {
+	// Name is the optional name of the pipeline.
+	Name string
+	// Source is the component producing the items of the pipeline.
+	Source Component
+	// Stages are the components processing the items, in order.
+	Stages []Component
+	// Sink is the component consuming the items of the pipeline.
+	Sink Component
+}
+
+// Component is a source, stage or sink of a [Spec], identified by its type.
+type Component struct {
+	// Type is the type of the component, such as "batch" or "http".
+	Type string
+	// Line and Column locate the component in the parsed document.
+	Line, Column int
+	// params holds the decoded parameters of the component.
+	params any
+	// lines locates each parameter of the component in the parsed document.
+	lines map[string]int
+}
+
+// Error is a problem found in a pipeline spec, located at the offending line.
+type Error struct {
+	// Line is the line of the document where the problem was found.
+	Line int
+	// Msg describes the problem.
+	Msg string
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// role is the position of a component within a pipeline.
+type role string
+
+const (
+	sourceRole role = "source"
+	stageRole  role = "stage"
+	sinkRole   role = "sink"
+)
+
+// definitions lists the parameters accepted by each component type, for each role.
+var definitions = map[role]map[string]func() params{
+	sourceRole: {
+		"slice":   func() params { return &sliceParams{} },
+		"channel": func() params { return &channelParams{} },
+		"http":    func() params { return &httpParams{Method: "GET"} },
+		"cmd":     func() params { return &commandParams{} },
+	},
+	stageRole: {
+		"batch":          func() params { return &batchParams{} },
+		"take":           func() params { return &countParams{} },
+		"drop":           func() params { return &countParams{} },
+		"unique":         func() params { return &noParams{} },
+		"sliding_window": func() params { return &windowParams{Step: 1} },
+		"limit":          func() params { return &limitParams{Burst: 1} },
+		"http":           func() params { return &httpParams{Method: "POST"} },
+		"shell":          func() params { return &commandParams{} },
+		"map":            func() params { return &funcParams{} },
+		"filter":         func() params { return &funcParams{} },
+	},
+	sinkRole: {
+		"null":    func() params { return &noParams{} },
+		"stdout":  func() params { return &noParams{} },
+		"channel": func() params { return &channelParams{} },
+	},
+}
+
+// Parse parses a pipeline spec from a YAML or JSON document, such as
+//
+//	name: uppercase
+//	source:
+//	  type: slice
+//	  items: [a, b, c]
+//	stages:
+//	  - type: shell
+//	    command: [tr, a-z, A-Z]
+//	  - type: batch
+//	    size: 2
+//	sink:
+//	  type: stdout
+//
+// The spec is validated as it is parsed; the returned error joins an [*Error] for each
+// problem found, located at the offending line. Functions and channels referenced by name
+// are validated when the spec is built by a [Registry].
+func Parse(data []byte) (*Spec, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, &Error{Line: 1, Msg: "empty spec"}
+	}
+
+	var (
+		p    = parser{}
+		spec = &Spec{}
+		root = doc.Content[0]
+	)
+
+	if root.Kind != yaml.MappingNode {
+		return nil, &Error{Line: root.Line, Msg: "spec must be a mapping"}
+	}
+
+	var hasSource, hasSink bool
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "name":
+			p.decode(value, &spec.Name, "name")
+		case "source":
+			hasSource = true
+			spec.Source = p.component(value, sourceRole)
+		case "stages":
+			if value.Kind != yaml.SequenceNode {
+				p.fail(value.Line, "stages must be a list")
+				continue
+			}
+			for _, node := range value.Content {
+				spec.Stages = append(spec.Stages, p.component(node, stageRole))
+			}
+		case "sink":
+			hasSink = true
+			spec.Sink = p.component(value, sinkRole)
+		default:
+			p.fail(key.Line, "unknown field %q", key.Value)
+		}
+	}
+
+	if !hasSource {
+		p.fail(root.Line, "missing source")
+	}
+	if !hasSink {
+		p.fail(root.Line, "missing sink")
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// parser collects the problems found while parsing a spec.
+type parser struct {
+	errs []error
+}
+
+// fail records a problem found at the line.
+func (p *parser) fail(line int, format string, args ...any) {
+	p.errs = append(p.errs, &Error{Line: line, Msg: fmt.Sprintf(format, args...)})
+}
+
+// err returns the problems found, if any.
+func (p *parser) err() error {
+	return errors.Join(p.errs...)
+}
+
+// decode decodes the node into the value, recording a problem if it has the wrong type.
+func (p *parser) decode(node *yaml.Node, value any, field string) bool {
+	if err := node.Decode(value); err != nil {
+		p.fail(node.Line, "%s must be %s", field, describe(reflect.TypeOf(value).Elem()))
+		return false
+	}
+	return true
+}
+
+// component parses a component of the given role.
+func (p *parser) component(node *yaml.Node, r role) Component {
+	c := Component{Line: node.Line, Column: node.Column, lines: make(map[string]int)}
+	if node.Kind != yaml.MappingNode {
+		p.fail(node.Line, "%s must be a mapping", r)
+		return c
+	}
+
+	values := make(map[string]*yaml.Node)
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		values[key.Value] = value
+		c.lines[key.Value] = key.Line
+	}
+
+	typ, ok := values["type"]
+	if !ok {
+		p.fail(node.Line, "%s is missing a type", r)
+		return c
+	}
+	if typ.Kind != yaml.ScalarNode {
+		p.fail(typ.Line, "type must be a string")
+		return c
+	}
+	// use the raw value, so that types such as null are not decoded as YAML values
+	c.Type = typ.Value
+
+	define, ok := definitions[r][c.Type]
+	if !ok {
+		p.fail(typ.Line, "unknown %s type %q; expected one of %s", r, c.Type, strings.Join(types(r), ", "))
+		return c
+	}
+
+	var (
+		params = define()
+		fields = fieldsOf(params)
+		failed = false
+	)
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "type" {
+			continue
+		}
+		field, ok := fields[key.Value]
+		if !ok {
+			p.fail(key.Line, "unknown field %q for %s type %q", key.Value, r, c.Type)
+			failed = true
+			continue
+		}
+		if !p.decode(value, field.Addr().Interface(), key.Value) {
+			failed = true
+		}
+	}
+
+	if failed {
+		return c
+	}
+	if field, msg := params.validate(); msg != "" {
+		line, ok := c.lines[field]
+		if !ok {
+			line = c.Line
+		}
+		p.fail(line, "%s %q: %s", r, c.Type, msg)
+		return c
+	}
+
+	c.params = params
+	return c
+}
+
+// types returns the sorted component types of the role.
+func types(r role) []string {
+	names := make([]string, 0, len(definitions[r]))
+	for name := range definitions[r] {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// fieldsOf maps the yaml field names of the params struct to its fields.
+func fieldsOf(p params) map[string]reflect.Value {
+	var (
+		v      = reflect.ValueOf(p).Elem()
+		fields = make(map[string]reflect.Value, v.NumField())
+	)
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Tag.Get("yaml"); name != "" {
+			fields[name] = v.Field(i)
+		}
+	}
+	return fields
+}
+
+// describe describes the expected type of a field in a problem message.
+func describe(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return "a duration, such as 1s"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int:
+		return "an integer"
+	case reflect.Float64:
+		return "a number"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return "a list of strings"
+		}
+		return "a list"
+	default:
+		return "a " + t.String()
+	}
+}
@@ -0,0 +1,135 @@
+package spec_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nisimpson/piper/pipeline/spec"
+)
+
+// Problems returns the problems joined in err.
+func Problems(err error) []spec.Error {
+	var problems []spec.Error
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		var e *spec.Error
+		if errors.As(err, &e) {
+			problems = append(problems, *e)
+		}
+		return problems
+	}
+	for _, err := range joined.Unwrap() {
+		problems = append(problems, Problems(err)...)
+	}
+	return problems
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("yaml", func(t *testing.T) {
+		s, err := spec.Parse([]byte(`
+name: example
+source:
+  type: slice
+  items: [1, 2, 3]
+stages:
+  - type: batch
+    size: 2
+    interval: 1s
+  - type: take
+    count: 1
+sink:
+  type: stdout
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.Name != "example" || s.Source.Type != "slice" || s.Sink.Type != "stdout" {
+			t.Errorf("got %+v", s)
+		}
+
+		var types []string
+		for _, stage := range s.Stages {
+			types = append(types, stage.Type)
+		}
+		if want := []string{"batch", "take"}; !reflect.DeepEqual(types, want) {
+			t.Errorf("got stages %v, want %v", types, want)
+		}
+		if got := s.Stages[1].Line; got != 10 {
+			t.Errorf("got line %d, want 10", got)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		s, err := spec.Parse([]byte(`{
+  "source": {"type": "channel", "name": "events"},
+  "stages": [{"type": "unique"}],
+  "sink": {"type": "null"}
+}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Source.Type != "channel" || len(s.Stages) != 1 || s.Sink.Type != "null" {
+			t.Errorf("got %+v", s)
+		}
+	})
+
+	t.Run("reports problems at their line", func(t *testing.T) {
+		_, err := spec.Parse([]byte(`
+source:
+  type: slice
+  items: [1, 2]
+stages:
+  - type: batch
+  - type: take
+    count: ten
+  - type: sliding_window
+    size: 3
+    color: red
+  - type: zip
+  - size: 2
+sink:
+  type: http
+extra: true
+`))
+
+		want := []spec.Error{
+			{Line: 6, Msg: `stage "batch": size or interval is required`},
+			{Line: 8, Msg: "count must be an integer"},
+			{Line: 11, Msg: `unknown field "color" for stage type "sliding_window"`},
+			{Line: 12, Msg: `unknown stage type "zip"; expected one of batch, drop, filter, http, limit, map, shell, sliding_window, take, unique`},
+			{Line: 13, Msg: "stage is missing a type"},
+			{Line: 15, Msg: `unknown sink type "http"; expected one of channel, null, stdout`},
+			{Line: 16, Msg: `unknown field "extra"`},
+		}
+
+		if got := Problems(err); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("reports missing components", func(t *testing.T) {
+		_, err := spec.Parse([]byte(`name: empty`))
+
+		want := []spec.Error{
+			{Line: 1, Msg: "missing source"},
+			{Line: 1, Msg: "missing sink"},
+		}
+
+		if got := Problems(err); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("invalid document", func(t *testing.T) {
+		if _, err := spec.Parse([]byte("source: [")); err == nil {
+			t.Error("expected an error")
+		}
+		if _, err := spec.Parse(nil); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,13 @@
+// Package sqlpipe provides [database/sql] integration for the piper pipeline framework.
+// It implements pipeline components to stream the rows of a query, execute a statement for
+// each item, insert batches of items with multi-row inserts inside a transaction, and
+// enrich items with the rows of a prepared lookup query. It works with any driver, and
+// with a [sql.DB], [sql.Conn] or [sql.Tx] where possible.
+//
+//	pipeline.FromChannel(orders).
+//		Thru(pipeline.BatchN[Order](500)).
+//		Thru(sqlpipe.InsertBatch(ctx, db, "orders", []string{"id", "total"},
+//			func(o Order) []any { return []any{o.ID, o.Total} },
+//		)).
+//		To(pipeline.ToNull())
+package sqlpipe
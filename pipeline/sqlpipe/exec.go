@@ -0,0 +1,28 @@
+package sqlpipe
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// Execer defines an interface for SQL statements, implemented by [sql.DB], [sql.Conn] and [sql.Tx].
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Exec creates a [piper.Pipe] that executes the statement for each upstream item, with the
+// arguments returned by the [ArgsFunction], then sends the item downstream. Items whose statement
+// fails are passed to the error handler and negatively acknowledged.
+func Exec[In any](ctx context.Context, db Execer, stmt string, args ArgsFunction[In], opts ...func(*Options)) piper.Pipe {
+	options := newOptions(opts)
+	return pipeline.Named("sql exec", pipeline.ExecCmd(
+		pipeline.CommandFunc(func(item In) (In, int, error) {
+			_, err := db.ExecContext(ctx, stmt, args(item)...)
+			return item, 0, err
+		}),
+		func(o *pipeline.CommandPipeOptions[In]) { o.HandleError = options.HandleError },
+	))
+}
@@ -0,0 +1,91 @@
+package sqlpipe
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// TxBeginner defines an interface for SQL transactions, implemented by [sql.DB] and [sql.Conn].
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// InsertBatch creates a [piper.Pipe] that inserts each upstream batch of items, such as those sent
+// by [pipeline.Batch], into the columns of the table, with the values returned by the [ArgsFunction]
+// for each item. Each batch is inserted inside a transaction by multi-row INSERT statements, then
+// sent downstream once committed. If the batch cannot be inserted, the transaction is rolled back,
+// and the batch is passed to the error handler and negatively acknowledged, along with its items.
+// Empty batches are sent downstream as is.
+//
+// The table and column names are written to the statements as is, and must not come from untrusted
+// input. See [Options] for the placeholder style and the maximum number of rows per statement.
+func InsertBatch[In any](ctx context.Context, db TxBeginner, table string, columns []string, values ArgsFunction[In], opts ...func(*Options)) piper.Pipe {
+	options := newOptions(opts)
+	return pipeline.Named("sql insert "+table, pipeline.ExecCmd(
+		pipeline.CommandFunc(func(batch []In) ([]In, int, error) {
+			return batch, 0, insert(ctx, db, table, columns, values, batch, options)
+		}),
+		func(o *pipeline.CommandPipeOptions[[]In]) { o.HandleError = options.HandleError },
+	))
+}
+
+// insert inserts the batch inside a transaction.
+func insert[In any](ctx context.Context, db TxBeginner, table string, columns []string, values ArgsFunction[In], batch []In, options *Options) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	tx, err := db.BeginTx(ctx, options.TxOptions)
+	if err != nil {
+		return err
+	}
+
+	size := len(batch)
+	if options.MaxRows > 0 {
+		size = min(size, options.MaxRows)
+	}
+	for start := 0; start < len(batch); start += size {
+		rows := batch[start:min(start+size, len(batch))]
+		stmt, args, err := insertStatement(table, columns, values, rows, options.Placeholder)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, stmt, args...)
+		}
+		if err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+	}
+	return tx.Commit()
+}
+
+// insertStatement builds a multi-row INSERT statement for the rows, returning the statement and its arguments.
+func insertStatement[In any](table string, columns []string, values ArgsFunction[In], rows []In, placeholder func(int) string) (string, []any, error) {
+	var (
+		b    strings.Builder
+		args = make([]any, 0, len(rows)*len(columns))
+	)
+	b.WriteString("INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES ")
+	for i, row := range rows {
+		rowArgs := values(row)
+		if len(rowArgs) != len(columns) {
+			return "", nil, fmt.Errorf("sqlpipe: got %d values for %d columns", len(rowArgs), len(columns))
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for j := range rowArgs {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(placeholder(len(args) + j + 1))
+		}
+		b.WriteString(")")
+		args = append(args, rowArgs...)
+	}
+	return b.String(), args, nil
+}
@@ -0,0 +1,31 @@
+package sqlpipe
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// LookupFunction enriches an item of type In with the row returned by a lookup query, converting
+// it to an item of type Out. The function must scan the row, releasing its connection; scanning
+// returns [sql.ErrNoRows] if the query returned no row, which the function may ignore to send the
+// item unenriched.
+type LookupFunction[In any, Out any] func(item In, row Scanner) (Out, error)
+
+// Lookup creates a [piper.Pipe] that enriches each upstream item with the first row returned by the
+// prepared statement, queried with the arguments returned by the [ArgsFunction]. Items the
+// [LookupFunction] fails to enrich are passed to the error handler and negatively acknowledged.
+// The statement is owned by the caller, who prepares it, such as with [sql.DB.PrepareContext],
+// and closes it once the pipeline is done.
+func Lookup[In any, Out any](ctx context.Context, stmt *sql.Stmt, args ArgsFunction[In], enrich LookupFunction[In, Out], opts ...func(*Options)) piper.Pipe {
+	options := newOptions(opts)
+	return pipeline.Named("sql lookup", pipeline.ExecCmd(
+		pipeline.CommandFunc(func(item In) (Out, int, error) {
+			out, err := enrich(item, stmt.QueryRowContext(ctx, args(item)...))
+			return out, 0, err
+		}),
+		func(o *pipeline.CommandPipeOptions[Out]) { o.HandleError = options.HandleError },
+	))
+}
@@ -0,0 +1,53 @@
+package sqlpipe
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/nisimpson/piper/internal/must"
+)
+
+// Options defines the configuration settings for the SQL pipeline operations.
+type Options struct {
+	// HandleError is called with the errors encountered while querying or executing statements,
+	// including rows that cannot be scanned.
+	HandleError func(error)
+	// Placeholder returns the placeholder of the nth argument, counting from 1, of the statements
+	// built by [InsertBatch]. Defaults to [Question]; use [Dollar] for drivers such as PostgreSQL.
+	Placeholder func(n int) string
+	// MaxRows is the maximum number of rows inserted by each statement built by [InsertBatch];
+	// larger batches are inserted by several statements within the same transaction. Zero inserts
+	// each batch with a single statement.
+	MaxRows int
+	// TxOptions configure the transactions started by [InsertBatch].
+	TxOptions *sql.TxOptions
+}
+
+// newOptions returns the default options, configured with the provided option functions.
+func newOptions(opts []func(*Options)) *Options {
+	options := &Options{
+		HandleError: must.IgnoreError,
+		Placeholder: Question,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// Question is a placeholder style numbering no arguments, such as "?" for MySQL and SQLite.
+func Question(int) string { return "?" }
+
+// Dollar is a placeholder style numbering each argument, such as "$1" for PostgreSQL.
+func Dollar(n int) string { return "$" + strconv.Itoa(n) }
+
+// Scanner reads the columns of a row, such as a [sql.Rows] or [sql.Row].
+type Scanner interface {
+	Scan(dest ...any) error
+}
+
+// ScanFunction converts a row into an item of type T, scanning its columns.
+type ScanFunction[T any] func(row Scanner) (T, error)
+
+// ArgsFunction returns the arguments of a statement or query for an item of type In.
+type ArgsFunction[In any] func(In) []any
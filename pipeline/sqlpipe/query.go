@@ -0,0 +1,52 @@
+package sqlpipe
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+
+	"github.com/nisimpson/piper/pipeline"
+)
+
+// Queryer defines an interface for SQL queries, implemented by [sql.DB], [sql.Conn] and [sql.Tx].
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// FromQuery creates a [pipeline.Flow] that runs the query with the arguments, sending each row
+// converted by the [ScanFunction] downstream as it is read. The query runs once the pipeline
+// receives its first row, and the rows are closed once read, or once a range loop over the flow
+// breaks. Rows that cannot be scanned are passed to the error handler and skipped; if the query
+// fails, the error is passed to the error handler and the flow sends no rows. The query is canceled
+// once ctx or the context of the flow is done.
+func FromQuery[T any](ctx context.Context, db Queryer, query string, args []any, scan ScanFunction[T], opts ...func(*Options)) pipeline.Flow {
+	options := newOptions(opts)
+	return pipeline.FromSeqContext(func(source context.Context) iter.Seq[T] {
+		return func(yield func(T) bool) {
+			source, cancel := context.WithCancel(source)
+			defer cancel()
+			defer context.AfterFunc(ctx, cancel)()
+
+			rows, err := db.QueryContext(source, query, args...)
+			if err != nil {
+				options.HandleError(err)
+				return
+			}
+			defer rows.Close()
+
+			for rows.Next() {
+				item, err := scan(rows)
+				if err != nil {
+					options.HandleError(err)
+					continue
+				}
+				if !yield(item) {
+					return
+				}
+			}
+			if err := rows.Err(); err != nil {
+				options.HandleError(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,490 @@
+package sqlpipe_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+	"github.com/nisimpson/piper/pipeline/sqlpipe"
+)
+
+// FakeDB is an in-memory database/sql driver recording the statements it executes. Queries
+// return the rows of their table, filtered by the first argument if any.
+type FakeDB struct {
+	mu sync.Mutex
+	// Log records each statement executed, and each transaction committed or rolled back.
+	log []string
+	// Tables holds the columns and rows returned by each query.
+	Tables map[string]FakeTable
+	// Fail fails the statements and queries containing the text.
+	Fail string
+}
+
+// FakeTable holds the columns and rows of a query result.
+type FakeTable struct {
+	Columns []string
+	Rows    [][]driver.Value
+}
+
+// Open opens a new [sql.DB] connected to the fake database.
+func (f *FakeDB) Open(t *testing.T) *sql.DB {
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Log returns the entries of the log.
+func (f *FakeDB) Log() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.log...)
+}
+
+func (f *FakeDB) record(format string, args ...any) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log = append(f.log, fmt.Sprintf(format, args...))
+}
+
+func (f *FakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *FakeDB) Driver() driver.Driver                        { return fakeDriver{f} }
+
+type fakeDriver struct{ db *FakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn(d), nil }
+
+type fakeConn struct{ db *FakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if c.db.Fail != "" && strings.Contains(query, c.db.Fail) {
+		return nil, errors.New("prepare failed")
+	}
+	return fakeStmt{db: c.db, query: query}, nil
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { c.db.record("begin"); return fakeTx(c), nil }
+
+type fakeTx struct{ db *FakeDB }
+
+func (tx fakeTx) Commit() error   { tx.db.record("commit"); return nil }
+func (tx fakeTx) Rollback() error { tx.db.record("rollback"); return nil }
+
+type fakeStmt struct {
+	db    *FakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	for _, arg := range args {
+		if s.db.Fail != "" && arg == s.db.Fail {
+			return nil, errors.New("exec failed")
+		}
+	}
+	s.db.record("%s %v", s.query, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	table := s.db.Tables[s.query]
+	rows := &fakeRows{columns: table.Columns}
+	for _, row := range table.Rows {
+		if len(args) == 0 || row[0] == args[0] {
+			rows.rows = append(rows.rows, row)
+		}
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// User is a row of the users table.
+type User struct {
+	ID   int64
+	Name string
+}
+
+func ScanUser(row sqlpipe.Scanner) (User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Name)
+	return u, err
+}
+
+// Consume reads the payload of every item of the flow, acknowledging messages.
+func Consume[T any](flow pipeline.Flow) []T {
+	return slices.Collect(pipeline.Seq[T](flow))
+}
+
+// Messages wraps each payload in a message, recording the nacked payloads.
+func Messages[T any](nacked *[]T, mu *sync.Mutex, payloads ...T) []pipeline.Message {
+	msgs := make([]pipeline.Message, len(payloads))
+	for i, payload := range payloads {
+		msgs[i] = pipeline.NewMessage(payload, nil, func(error) {
+			mu.Lock()
+			defer mu.Unlock()
+			*nacked = append(*nacked, payload)
+		})
+	}
+	return msgs
+}
+
+var users = FakeTable{
+	Columns: []string{"id", "name"},
+	Rows:    [][]driver.Value{{int64(1), "ada"}, {int64(2), "grace"}, {int64(3), "bad"}},
+}
+
+// ContextQueryer is a [sqlpipe.Queryer] recording the context of each query, which fails.
+type ContextQueryer struct {
+	contexts chan context.Context
+}
+
+func (q ContextQueryer) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	q.contexts <- ctx
+	return nil, errors.New("query failed")
+}
+
+func TestFromQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("streams rows", func(t *testing.T) {
+		var (
+			fake = &FakeDB{Tables: map[string]FakeTable{"SELECT id, name FROM users": users}}
+			db   = fake.Open(t)
+			errs []error
+			scan = func(row sqlpipe.Scanner) (User, error) {
+				u, err := ScanUser(row)
+				if err == nil && u.Name == "bad" {
+					err = errors.New("bad user")
+				}
+				return u, err
+			}
+			flow = sqlpipe.FromQuery(context.Background(), db, "SELECT id, name FROM users", nil, scan,
+				func(o *sqlpipe.Options) { o.HandleError = func(err error) { errs = append(errs, err) } })
+		)
+
+		if got, want := Consume[User](flow), []User{{1, "ada"}, {2, "grace"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if len(errs) != 1 {
+			t.Errorf("got errors %v, want one for the bad row", errs)
+		}
+	})
+
+	t.Run("cancels the query with the flow", func(t *testing.T) {
+		var (
+			queryer     = ContextQueryer{contexts: make(chan context.Context, 1)}
+			ctx, cancel = context.WithCancel(context.Background())
+			flow        = sqlpipe.FromQuery(context.Background(), queryer, "SELECT id, name FROM users", nil, ScanUser).WithContext(ctx)
+		)
+
+		Consume[User](flow)
+		queryCtx := <-queryer.contexts
+		cancel()
+		select {
+		case <-queryCtx.Done():
+		case <-time.After(pipetest.DefaultTimeout):
+			t.Error("expected the query to be canceled")
+		}
+	})
+
+	t.Run("passes arguments", func(t *testing.T) {
+		var (
+			fake = &FakeDB{Tables: map[string]FakeTable{"SELECT id, name FROM users WHERE id = ?": users}}
+			flow = sqlpipe.FromQuery(context.Background(), fake.Open(t), "SELECT id, name FROM users WHERE id = ?", []any{2}, ScanUser)
+		)
+		if got, want := Consume[User](flow), []User{{2, "grace"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("stops early", func(t *testing.T) {
+		var (
+			fake = &FakeDB{Tables: map[string]FakeTable{"SELECT id, name FROM users": users}}
+			db   = fake.Open(t)
+			got  []User
+		)
+		for u := range pipeline.Seq[User](sqlpipe.FromQuery(context.Background(), db, "SELECT id, name FROM users", nil, ScanUser)) {
+			got = append(got, u)
+			break
+		}
+		if want := []User{{1, "ada"}}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("reports query errors", func(t *testing.T) {
+		var (
+			fake = &FakeDB{Fail: "users"}
+			errs []error
+			flow = sqlpipe.FromQuery(context.Background(), fake.Open(t), "SELECT id, name FROM users", nil, ScanUser,
+				func(o *sqlpipe.Options) { o.HandleError = func(err error) { errs = append(errs, err) } })
+		)
+		if got := Consume[User](flow); len(got) != 0 {
+			t.Errorf("got %v, want no rows", got)
+		}
+		if len(errs) != 1 {
+			t.Errorf("got errors %v, want 1", errs)
+		}
+	})
+}
+
+func TestExec(t *testing.T) {
+	t.Parallel()
+
+	var (
+		fake   = &FakeDB{Fail: "mallory"}
+		mu     sync.Mutex
+		nacked []string
+		flow   = pipeline.FromSlice(Messages(&nacked, &mu, "ada", "mallory", "grace")...).
+			Thru(sqlpipe.Exec(context.Background(), fake.Open(t), "INSERT INTO users (name) VALUES (?)",
+				func(name string) []any { return []any{name} }))
+	)
+
+	if got, want := Consume[string](flow), []string{"ada", "grace"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	want := []string{"INSERT INTO users (name) VALUES (?) [ada]", "INSERT INTO users (name) VALUES (?) [grace]"}
+	if got := fake.Log(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got log %q, want %q", got, want)
+	}
+	if want := []string{"mallory"}; !reflect.DeepEqual(nacked, want) {
+		t.Errorf("got nacked %v, want %v", nacked, want)
+	}
+}
+
+func TestInsertBatch(t *testing.T) {
+	t.Parallel()
+
+	values := func(u User) []any { return []any{u.ID, u.Name} }
+
+	tests := []struct {
+		name  string
+		input [][]User
+		opts  func(*sqlpipe.Options)
+		fail  string
+		want  []string
+	}{
+		{
+			name:  "inserts batches in transactions",
+			input: [][]User{{{1, "ada"}, {2, "grace"}}, {{3, "edsger"}}, {}},
+			opts:  func(*sqlpipe.Options) {},
+			want: []string{
+				"begin",
+				"INSERT INTO users (id, name) VALUES (?, ?), (?, ?) [1 ada 2 grace]",
+				"commit",
+				"begin",
+				"INSERT INTO users (id, name) VALUES (?, ?) [3 edsger]",
+				"commit",
+			},
+		},
+		{
+			name:  "splits batches into statements",
+			input: [][]User{{{1, "ada"}, {2, "grace"}, {3, "edsger"}}},
+			opts: func(o *sqlpipe.Options) {
+				o.MaxRows = 2
+				o.Placeholder = sqlpipe.Dollar
+			},
+			want: []string{
+				"begin",
+				"INSERT INTO users (id, name) VALUES ($1, $2), ($3, $4) [1 ada 2 grace]",
+				"INSERT INTO users (id, name) VALUES ($1, $2) [3 edsger]",
+				"commit",
+			},
+		},
+		{
+			name:  "rolls back failed batches",
+			input: [][]User{{{1, "ada"}, {2, "grace"}, {3, "mallory"}}, {{4, "alan"}}},
+			opts:  func(o *sqlpipe.Options) { o.MaxRows = 2 },
+			fail:  "mallory",
+			want: []string{
+				"begin",
+				"INSERT INTO users (id, name) VALUES (?, ?), (?, ?) [1 ada 2 grace]",
+				"rollback",
+				"begin",
+				"INSERT INTO users (id, name) VALUES (?, ?) [4 alan]",
+				"commit",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				fake   = &FakeDB{Fail: tt.fail}
+				errs   []error
+				mu     sync.Mutex
+				nacked [][]User
+				flow   = pipeline.FromSlice(Messages(&nacked, &mu, tt.input...)...).
+					Thru(sqlpipe.InsertBatch(context.Background(), fake.Open(t), "users", []string{"id", "name"}, values,
+						tt.opts, func(o *sqlpipe.Options) { o.HandleError = func(err error) { errs = append(errs, err) } }))
+			)
+
+			got := Consume[[]User](flow)
+			if got := fake.Log(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got log %q, want %q", got, tt.want)
+			}
+			if len(got)+len(nacked) != len(tt.input) || len(errs) != len(nacked) {
+				t.Errorf("got %d batches, %d nacked and errors %v, want %d batches", len(got), len(nacked), errs, len(tt.input))
+			}
+			if tt.fail != "" && len(nacked) != 1 {
+				t.Errorf("got nacked %v, want the failed batch", nacked)
+			}
+		})
+	}
+
+	t.Run("rejects rows with missing values", func(t *testing.T) {
+		var (
+			fake = &FakeDB{}
+			errs []error
+			flow = pipeline.FromSlice([]User{{1, "ada"}}).
+				Thru(sqlpipe.InsertBatch(context.Background(), fake.Open(t), "users", []string{"id", "name", "email"}, values,
+					func(o *sqlpipe.Options) { o.HandleError = func(err error) { errs = append(errs, err) } }))
+		)
+		if got := Consume[[]User](flow); len(got) != 0 {
+			t.Errorf("got %v, want no batches", got)
+		}
+		if want := []string{"begin", "rollback"}; !reflect.DeepEqual(fake.Log(), want) || len(errs) != 1 {
+			t.Errorf("got log %q and errors %v, want %q", fake.Log(), errs, want)
+		}
+	})
+}
+
+// Order is an item enriched with the name of its user.
+type Order struct {
+	ID     int
+	UserID int64
+	User   string
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	var (
+		fake = &FakeDB{Tables: map[string]FakeTable{"SELECT id, name FROM users WHERE id = ?": users}}
+		db   = fake.Open(t)
+		ctx  = context.Background()
+	)
+	stmt, err := db.PrepareContext(ctx, "SELECT id, name FROM users WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+
+	var (
+		errs   []error
+		mu     sync.Mutex
+		nacked []Order
+		enrich = func(o Order, row sqlpipe.Scanner) (Order, error) {
+			u, err := ScanUser(row)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return o, nil
+			case err != nil:
+				return o, err
+			case u.Name == "bad":
+				return o, errors.New("bad user")
+			}
+			o.User = u.Name
+			return o, nil
+		}
+		flow = pipeline.FromSlice(Messages(&nacked, &mu, Order{1, 2, ""}, Order{2, 9, ""}, Order{3, 3, ""}, Order{4, 1, ""})...).
+			Thru(sqlpipe.Lookup(ctx, stmt, func(o Order) []any { return []any{o.UserID} }, enrich,
+				func(o *sqlpipe.Options) { o.HandleError = func(err error) { errs = append(errs, err) } }))
+	)
+
+	want := []Order{{1, 2, "grace"}, {2, 9, ""}, {4, 1, "ada"}}
+	if got := Consume[Order](flow); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if want := []Order{{3, 3, ""}}; !reflect.DeepEqual(nacked, want) || len(errs) != 1 {
+		t.Errorf("got nacked %v and errors %v, want %v", nacked, errs, want)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+
+	var (
+		fake = &FakeDB{Tables: map[string]FakeTable{"SELECT id, name FROM users WHERE id = ?": users}}
+		db   = fake.Open(t)
+		id   = func(i int) []any { return []any{int64(i)} }
+		each = func(n int) int { return n }
+	)
+	stmt, err := db.PrepareContext(context.Background(), "SELECT id, name FROM users WHERE id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the subtests run in parallel once this function returns
+	t.Cleanup(func() { stmt.Close() })
+
+	tests := []struct {
+		name string
+		pipe func(ctx context.Context) piper.Pipe
+		opts []func(*pipetest.ConformanceOptions)
+	}{
+		{
+			name: "Exec",
+			pipe: func(ctx context.Context) piper.Pipe {
+				return sqlpipe.Exec(ctx, db, "DELETE FROM users WHERE id = ?", id)
+			},
+		},
+		{
+			name: "InsertBatch",
+			pipe: func(ctx context.Context) piper.Pipe {
+				return sqlpipe.InsertBatch(ctx, db, "users", []string{"id"}, id)
+			},
+			opts: []func(*pipetest.ConformanceOptions){func(o *pipetest.ConformanceOptions) {
+				o.Input = func(i int) any { return []int{i, i + 1} }
+			}},
+		},
+		{
+			name: "Lookup",
+			pipe: func(ctx context.Context) piper.Pipe {
+				return sqlpipe.Lookup(ctx, stmt, id, func(i int, row sqlpipe.Scanner) (string, error) {
+					u, err := ScanUser(row)
+					if errors.Is(err, sql.ErrNoRows) {
+						return "", nil
+					}
+					return u.Name, err
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			opts := append(tt.opts, func(o *pipetest.ConformanceOptions) { o.Output = each })
+			pipetest.Conformance(t, tt.pipe, opts...)
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package pipeline
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// instruments holds the instrumentation a [Flow] attaches to each of its stages.
+type instruments struct {
+	// observer receives the events reported by the stage.
+	observer Observer
+	// logger receives the records emitted by the stage.
+	logger *slog.Logger
+	// logOptions configure which records are emitted to the logger.
+	logOptions LogOptions
+	// tracer starts a span for each item processed by the stage.
+	tracer Tracer
+}
+
+// timed reports whether the instruments use the latency of items, so that stages only read the
+// clock for each item when needed.
+func (i *instruments) timed() bool {
+	return i != nil && (i.observer != nil || i.logger != nil && i.logOptions.SlowThreshold > 0)
+}
+
+// instrumentable is implemented by components that accept the instruments of a [Flow].
+type instrumentable interface {
+	instrument(instruments)
+}
+
+// stage holds the state shared by the built-in components of a pipeline: the name used to
+// identify the component, the instruments attached to it by a [Flow], and its lifecycle
+// state and counters.
+type stage struct {
+	// name identifies the stage when reporting events. It may be replaced by [Named]
+	// after the stage has started.
+	name atomic.Pointer[string]
+	// instruments are loaded by the stage for each item, as they may be attached
+	// by a flow after the stage has started.
+	instruments atomic.Pointer[instruments]
+	// state is the [StageState] of the stage.
+	state atomic.Int32
+	// counters count the items processed by the stage.
+	counters struct {
+		received, emitted, dropped, failed atomic.Uint64
+	}
+	// fused is the fusion running the stage, if it is a processor.
+	fused atomic.Pointer[fusion]
+}
+
+// newStage creates a new idle stage with the given name.
+func newStage(name string) *stage {
+	s := &stage{}
+	s.name.Store(&name)
+	s.state.Store(int32(StageIdle))
+	return s
+}
+
+// Name implements [Namer].
+func (s *stage) Name() string {
+	return *s.name.Load()
+}
+
+// rename replaces the name of the stage.
+func (s *stage) rename(name string) {
+	s.name.Store(&name)
+}
+
+// status implements reporter.
+func (s *stage) status() StageStatus {
+	return StageStatus{
+		Name:     s.Name(),
+		State:    StageState(s.state.Load()),
+		Received: s.counters.received.Load(),
+		Emitted:  s.counters.emitted.Load(),
+		Dropped:  s.counters.dropped.Load(),
+		Errors:   s.counters.failed.Load(),
+	}
+}
+
+// instrument implements instrumentable, attaching the instruments of a [Flow] to this stage.
+func (s *stage) instrument(i instruments) {
+	s.instruments.Store(&i)
+}
+
+// finish reports that the stage has processed all of its input.
+func (s *stage) finish() {
+	s.state.Store(int32(StageStopped))
+	s.log(slog.LevelInfo, "stage stopped")
+}
+
+// log emits a record to the logger attached to this stage, if the level is enabled.
+func (s *stage) log(level slog.Level, msg string, attrs ...slog.Attr) {
+	i := s.instruments.Load()
+	if i == nil || i.logger == nil || level < i.logOptions.Level.Level() {
+		return
+	}
+	attrs = append([]slog.Attr{slog.String("stage", s.Name())}, attrs...)
+	i.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// observer returns the [Observer] attached to this stage, or a [NopObserver] if there is none.
+func (s *stage) observer() Observer {
+	if i := s.instruments.Load(); i != nil && i.observer != nil {
+		return i.observer
+	}
+	return NopObserver{}
+}
+
+// receipt records the reception of an item by a stage.
+type receipt struct {
+	// since is the time the item was received, or zero if the stage is not timing items.
+	since time.Time
+	// ctx carries the span, if any.
+	ctx context.Context
+	// span is the span tracing the processing of the item by the stage, if a tracer is attached.
+	span Span
+}
+
+// elapsed returns the time elapsed since the item was received, or zero if the item is not timed.
+func (r receipt) elapsed() time.Duration {
+	if r.since.IsZero() {
+		return 0
+	}
+	return time.Since(r.since)
+}
+
+// carry carries output in an envelope holding the trace context of the receipt, if any.
+func (r receipt) carry(output any) any {
+	if r.span == nil {
+		return output
+	}
+	if msg, ok := output.(Message); ok {
+		return msg.WithContext(r.ctx)
+	}
+	return Message{Payload: output, ctx: r.ctx}
+}
+
+// end completes the span of the receipt, if any.
+func (r receipt) end(err error) {
+	if r.span == nil {
+		return
+	}
+	if err != nil {
+		r.span.RecordError(err)
+	}
+	r.span.End()
+}
+
+// tracer returns the [Tracer] attached to this stage, or nil if there is none.
+func (s *stage) tracer() Tracer {
+	if i := s.instruments.Load(); i != nil {
+		return i.tracer
+	}
+	return nil
+}
+
+// receive reports that the stage received item, starting a span for it if a tracer is attached.
+func (s *stage) receive(item any) receipt {
+	s.counters.received.Add(1)
+	if s.state.CompareAndSwap(int32(StageIdle), int32(StageRunning)) {
+		s.log(slog.LevelInfo, "stage started")
+	}
+	s.observer().OnReceive(s.Name(), unwrap(item))
+	var r receipt
+	if s.instruments.Load().timed() {
+		r.since = time.Now()
+	}
+	if tracer := s.tracer(); tracer != nil {
+		name := s.Name()
+		r.ctx, r.span = tracer.Start(contextOf(item), name)
+		r.span.SetAttribute("piper.stage", name)
+	}
+	return r
+}
+
+// push sends item downstream, reporting any time spent blocked waiting for a receiver.
+func (s *stage) push(out chan<- any, item any) {
+	select {
+	case out <- item:
+		return
+	default:
+	}
+	if i := s.instruments.Load(); i == nil || i.observer == nil {
+		out <- item
+		return
+	}
+	start := time.Now()
+	out <- item
+	s.observer().OnBlocked(s.Name(), time.Since(start))
+}
+
+// emit sends the output of src downstream, then reports that src was processed.
+func (s *stage) emit(out chan<- any, src any, output any, r receipt) {
+	latency := r.elapsed()
+	s.push(out, r.carry(output))
+	s.emitted(src, r, latency)
+}
+
+// forward passes the output of src to the next processor of a fusion, then reports that src was processed.
+func (s *stage) forward(next func(any), src any, output any, r receipt) {
+	latency := r.elapsed()
+	next(r.carry(output))
+	s.emitted(src, r, latency)
+}
+
+// emitted reports that the output of src has been sent downstream, ending its span.
+// Items processed slower than the configured threshold are logged.
+func (s *stage) emitted(src any, r receipt, latency time.Duration) {
+	r.end(nil)
+	s.counters.emitted.Add(1)
+	s.observer().OnEmit(s.Name(), unwrap(src), latency)
+	if i := s.instruments.Load(); i != nil && i.logOptions.SlowThreshold > 0 && latency > i.logOptions.SlowThreshold {
+		s.log(slog.LevelWarn, "slow item", slog.Duration("latency", latency))
+	}
+}
+
+// drop discards item, acknowledging it as fully processed.
+func (s *stage) drop(item any, r receipt) {
+	r.end(nil)
+	s.counters.dropped.Add(1)
+	s.observer().OnDrop(s.Name(), unwrap(item))
+	s.log(slog.LevelDebug, "item dropped")
+	ack(item)
+}
+
+// discard discards item without processing it, such as the item in flight when a source is
+// stopped, negatively acknowledging it with err. Unlike [stage.fail], the error is not reported.
+func (s *stage) discard(item any, r receipt, err error) {
+	r.end(nil)
+	s.counters.dropped.Add(1)
+	s.observer().OnDrop(s.Name(), unwrap(item))
+	s.log(slog.LevelDebug, "item discarded", slog.Any("reason", err))
+	nack(item, err)
+}
+
+// fail reports that item could not be processed, negatively acknowledging it.
+func (s *stage) fail(item any, r receipt, err error) {
+	s.reject(r, err)
+	nack(item, err)
+}
+
+// failure reports that the stage failed outside the processing of any item, such as a
+// source failing to read its input.
+func (s *stage) failure(msg string, err error) {
+	s.observer().OnFailure(s.Name(), err)
+	s.log(slog.LevelError, msg, slog.Any("error", err))
+}
+
+// reject reports that an item could not be processed, leaving it to be settled by the caller.
+func (s *stage) reject(r receipt, err error) {
+	r.end(err)
+	s.counters.failed.Add(1)
+	s.observer().OnError(s.Name(), err)
+	s.log(slog.LevelError, "item failed", slog.Any("error", err))
+}
@@ -0,0 +1,310 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/nisimpson/piper/internal/must"
+)
+
+// TailLine is a line read by [FromFileTail].
+type TailLine struct {
+	// Path is the path of the file. Lines read from a file once it was rotated report the path it
+	// was renamed to, if it stayed in the same directory.
+	Path string
+	// Text is the line, without its line ending.
+	Text string
+	// Offset is the byte offset following the line in the file at Path. Set [TailOptions.Offset]
+	// and [TailOptions.Resume] to resume reading after the line. Offsets of lines read from a
+	// rotated file do not resume the file that took its place.
+	Offset int64
+}
+
+// TailOptions configure the source created by [FromFileTail].
+type TailOptions struct {
+	// FromEnd starts reading at the end of the file, skipping its current lines.
+	FromEnd bool
+	// Offset starts reading at the byte offset, such as the [TailLine.Offset] of the last line
+	// processed. A non-zero offset takes precedence over FromEnd. If the file is shorter, it is read
+	// from the start.
+	Offset int64
+	// Resume starts reading at Offset even if it is zero, taking precedence over FromEnd.
+	Resume bool
+	// PollInterval is the time to wait before checking for new lines once the end of the file is
+	// reached. Defaults to 250ms.
+	PollInterval time.Duration
+	// Clock schedules the polling. Defaults to [SystemClock].
+	Clock Clock
+	// HandleError is called when the file cannot be read, ending the source.
+	HandleError func(error)
+}
+
+// FromFileTail creates a new [Flow] that sends each line of the file at path as a [TailLine], then
+// follows the lines appended to the file until the context of the flow is done. The file is polled
+// for changes, waiting for it to be created if it does not exist yet. Lines are sent once complete,
+// ending with a newline.
+//
+// If the file is truncated or rewritten, it is read again from the start; rewrites are detected by
+// comparing the bytes before the offset. If the file is rotated by renaming it and creating a new
+// file at path, the rest of the renamed file is read, including any incomplete last line, before
+// following the new file from its start.
+func FromFileTail(path string, opts ...func(*TailOptions)) Flow {
+	options := TailOptions{
+		PollInterval: 250 * time.Millisecond,
+		Clock:        SystemClock(),
+		HandleError:  must.IgnoreError,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Clock == nil {
+		options.Clock = SystemClock()
+	}
+
+	var source *seqSource[TailLine]
+	source = newSeqSource("tail", func(ctx context.Context) iter.Seq[TailLine] {
+		return func(yield func(TailLine) bool) {
+			t := tailer{path: path, options: options, ctx: ctx}
+			defer t.close()
+			if err := t.follow(yield); err != nil {
+				source.failure("tail failed", err)
+				options.HandleError(err)
+			}
+		}
+	})
+	return From(source)
+}
+
+// tailer follows the lines appended to a file.
+type tailer struct {
+	path    string
+	options TailOptions
+	ctx     context.Context
+	// file is the file being read, and reader buffers its content.
+	file   *os.File
+	reader *bufio.Reader
+	// name is the path lines are reported with: the path followed, or the path the file was
+	// renamed to once rotated.
+	name string
+	// offset is the offset of the next byte read from the file.
+	offset int64
+	// window holds the bytes before the offset, up to tailWindow, compared with the file to
+	// detect it being rewritten.
+	window []byte
+	// pending holds the start of an incomplete line.
+	pending []byte
+}
+
+// follow sends each line of the file, following appended lines, rotation and truncation until
+// the context is done or yield returns false. It returns an error if the file cannot be read.
+func (t *tailer) follow(yield func(TailLine) bool) error {
+	if ok, err := t.open(t.start); !ok || err != nil {
+		return err
+	}
+
+	for {
+		if ok, err := t.read(yield, false); !ok || err != nil {
+			return err
+		}
+		if !t.wait() {
+			return nil
+		}
+
+		// check whether the file was rotated or truncated since it was read
+		rotated, truncated, err := t.check()
+		switch {
+		case err != nil:
+			return err
+		case rotated:
+			// read the rest of the renamed file, including the lines appended since it was read
+			if ok, err := t.read(yield, true); !ok || err != nil {
+				return err
+			}
+			t.close()
+			if ok, err := t.open(func(fs.FileInfo) int64 { return 0 }); !ok || err != nil {
+				return err
+			}
+		case truncated:
+			if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			t.reader.Reset(t.file)
+			t.offset, t.window, t.pending = 0, t.window[:0], nil
+		}
+	}
+}
+
+// read sends each line read up to the end of the file, reporting whether to continue. An
+// incomplete last line is kept for the next read, unless final is set.
+func (t *tailer) read(yield func(TailLine) bool, final bool) (bool, error) {
+	for {
+		chunk, err := t.reader.ReadBytes('\n')
+		t.offset += int64(len(chunk))
+		t.window = append(t.window, chunk...)
+		if n := len(t.window) - tailWindow; n > 0 {
+			t.window = append(t.window[:0], t.window[n:]...)
+		}
+		t.pending = append(t.pending, chunk...)
+		switch {
+		case err == nil:
+			if !t.send(yield) {
+				return false, nil
+			}
+		case errors.Is(err, io.EOF):
+			if final && len(t.pending) > 0 {
+				return t.send(yield), nil
+			}
+			return true, nil
+		default:
+			return false, err
+		}
+	}
+}
+
+// send sends the pending line, reporting whether to continue.
+func (t *tailer) send(yield func(TailLine) bool) bool {
+	text := bytes.TrimSuffix(bytes.TrimSuffix(t.pending, []byte("\n")), []byte("\r"))
+	line := TailLine{Path: t.name, Text: string(text), Offset: t.offset}
+	t.pending = t.pending[:0]
+	return yield(line)
+}
+
+// start returns the offset to start reading the file from, according to the options.
+func (t *tailer) start(info fs.FileInfo) int64 {
+	resume := t.options.Resume || t.options.Offset > 0
+	switch {
+	case resume && t.options.Offset >= 0 && t.options.Offset <= info.Size():
+		return t.options.Offset
+	case resume:
+		// the file was truncated or replaced
+		return 0
+	case t.options.FromEnd:
+		return info.Size()
+	default:
+		return 0
+	}
+}
+
+// open opens the file, waiting for it to exist, and seeks to the offset returned by start.
+// It reports false if the context is done first.
+func (t *tailer) open(start func(fs.FileInfo) int64) (bool, error) {
+	for {
+		file, err := os.Open(t.path)
+		if errors.Is(err, fs.ErrNotExist) {
+			if !t.wait() {
+				return false, nil
+			}
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		t.file, t.name, t.offset, t.window, t.pending = file, t.path, 0, t.window[:0], nil
+		info, err := file.Stat()
+		if err == nil {
+			t.offset = start(info)
+			_, err = file.Seek(t.offset, io.SeekStart)
+		}
+		if err == nil {
+			t.window, err = t.readWindow(t.window)
+		}
+		if err != nil {
+			t.close()
+			return false, err
+		}
+		t.reader = bufio.NewReader(file)
+		return true, nil
+	}
+}
+
+// check reports whether a new file was created at the path, or the file was truncated below the
+// current offset or rewritten, even if it grew past the offset since. A file renamed without a
+// new file taking its place is still followed. Once the file is renamed, its lines are reported
+// with its new path.
+func (t *tailer) check() (rotated, truncated bool, err error) {
+	current, err := t.file.Stat()
+	if err != nil {
+		return false, false, err
+	}
+	info, err := os.Stat(t.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.rename(current)
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if !os.SameFile(current, info) {
+		t.rename(current)
+		return true, false, nil
+	}
+	if current.Size() < t.offset {
+		return false, true, nil
+	}
+	window, err := t.readWindow(nil)
+	return false, !bytes.Equal(window, t.window), err
+}
+
+// tailWindow is the number of bytes before the offset compared with the file to detect it being
+// rewritten.
+const tailWindow = 256
+
+// readWindow appends the bytes before the offset in the file, up to tailWindow, to dst without
+// moving the reader.
+func (t *tailer) readWindow(dst []byte) ([]byte, error) {
+	n := min(t.offset, tailWindow)
+	dst = slices.Grow(dst[:0], int(n))[:n]
+	if _, err := t.file.ReadAt(dst, t.offset-n); err != nil {
+		return dst[:0], err
+	}
+	return dst, nil
+}
+
+// rename reports the lines of the file with the path it was renamed to, if it can be found in
+// the directory of the path followed.
+func (t *tailer) rename(current fs.FileInfo) {
+	if t.name != t.path {
+		return
+	}
+	dir := filepath.Dir(t.path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && os.SameFile(current, info) {
+			t.name = filepath.Join(dir, entry.Name())
+			return
+		}
+	}
+}
+
+// wait waits for the poll interval, reporting false if the context is done first.
+func (t *tailer) wait() bool {
+	timer := t.options.Clock.NewTimer(t.options.PollInterval)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-t.ctx.Done():
+		return false
+	}
+}
+
+// close closes the file, if open.
+func (t *tailer) close() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
@@ -0,0 +1,254 @@
+package pipeline_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+)
+
+// Tail follows a file with a fake clock, for a test.
+type Tail struct {
+	t     *testing.T
+	path  string
+	clock *pipetest.FakeClock
+	out   <-chan any
+}
+
+func NewTail(t *testing.T, path string, opts ...func(*pipeline.TailOptions)) *Tail {
+	var (
+		clock       = pipetest.NewFakeClock(time.Now())
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	opts = append(opts, func(o *pipeline.TailOptions) {
+		o.Clock = clock
+		o.PollInterval = time.Second
+	})
+	flow := pipeline.FromFileTail(path, opts...).WithContext(ctx)
+	tail := &Tail{t: t, path: path, clock: clock, out: flow.Out()}
+	t.Cleanup(func() {
+		cancel()
+		for range tail.out {
+		}
+	})
+	return tail
+}
+
+// Expect receives the next lines, failing the test unless they match want.
+func (tail *Tail) Expect(want ...pipeline.TailLine) {
+	tail.t.Helper()
+	for _, w := range want {
+		select {
+		case item := <-tail.out:
+			if got := item.(pipeline.TailLine); got != w {
+				tail.t.Fatalf("got %+v, want %+v", got, w)
+			}
+		case <-time.After(pipetest.DefaultTimeout):
+			tail.t.Fatalf("no line within %v, want %+v", pipetest.DefaultTimeout, w)
+		}
+	}
+}
+
+// Change waits for the tail to poll the file, changes it, then lets the tail check for changes.
+func (tail *Tail) Change(change func()) {
+	tail.clock.BlockUntil(1)
+	change()
+	tail.clock.Advance(time.Second)
+}
+
+func Append(t *testing.T, path, text string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFromFileTail(t *testing.T) {
+	t.Parallel()
+
+	t.Run("follows appended lines", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		Append(t, path, "a\r\nb\n")
+
+		tail := NewTail(t, path)
+		tail.Expect(
+			pipeline.TailLine{Path: path, Text: "a", Offset: 3},
+			pipeline.TailLine{Path: path, Text: "b", Offset: 5},
+		)
+
+		tail.Change(func() { Append(t, path, "c\npart") })
+		tail.Expect(pipeline.TailLine{Path: path, Text: "c", Offset: 7})
+
+		tail.Change(func() { Append(t, path, "ial\n\n") })
+		tail.Expect(
+			pipeline.TailLine{Path: path, Text: "partial", Offset: 15},
+			pipeline.TailLine{Path: path, Text: "", Offset: 16},
+		)
+	})
+
+	t.Run("starts at the end", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		Append(t, path, "old\n")
+
+		tail := NewTail(t, path, func(o *pipeline.TailOptions) { o.FromEnd = true })
+		tail.Change(func() { Append(t, path, "new\n") })
+		tail.Expect(pipeline.TailLine{Path: path, Text: "new", Offset: 8})
+	})
+
+	t.Run("resumes at an offset", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		Append(t, path, "a\nb\nc\n")
+
+		tail := NewTail(t, path, func(o *pipeline.TailOptions) { o.Offset = 4 })
+		tail.Expect(pipeline.TailLine{Path: path, Text: "c", Offset: 6})
+	})
+
+	t.Run("resumes at the start", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		Append(t, path, "a\n")
+
+		tail := NewTail(t, path, func(o *pipeline.TailOptions) { o.FromEnd, o.Resume = true, true })
+		tail.Expect(pipeline.TailLine{Path: path, Text: "a", Offset: 2})
+	})
+
+	t.Run("waits for the file to be created", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+
+		tail := NewTail(t, path)
+		tail.Change(func() { Append(t, path, "a\n") })
+		tail.Expect(pipeline.TailLine{Path: path, Text: "a", Offset: 2})
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		var (
+			path        = filepath.Join(t.TempDir(), "app.log")
+			ctx, cancel = context.WithCancel(context.Background())
+			flow        = pipeline.FromFileTail(path).WithContext(ctx)
+			done        = make(chan struct{})
+		)
+		go func() {
+			defer close(done)
+			for range flow.Out() {
+				t.Error("expected no output")
+			}
+		}()
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(pipetest.DefaultTimeout):
+			t.Fatal("source did not stop")
+		}
+	})
+
+	t.Run("restarts after truncation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		Append(t, path, "first\n")
+
+		tail := NewTail(t, path)
+		tail.Expect(pipeline.TailLine{Path: path, Text: "first", Offset: 6})
+
+		tail.Change(func() {
+			if err := os.WriteFile(path, []byte("x\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		})
+		tail.Expect(pipeline.TailLine{Path: path, Text: "x", Offset: 2})
+	})
+
+	t.Run("restarts after truncation past the offset", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		Append(t, path, "first\n")
+
+		tail := NewTail(t, path)
+		tail.Expect(pipeline.TailLine{Path: path, Text: "first", Offset: 6})
+
+		tail.Change(func() {
+			if err := os.WriteFile(path, []byte("x\nrewritten\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		})
+		tail.Expect(
+			pipeline.TailLine{Path: path, Text: "x", Offset: 2},
+			pipeline.TailLine{Path: path, Text: "rewritten", Offset: 12},
+		)
+	})
+
+	t.Run("restarts after a rewrite keeping the last line ending in place", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		Append(t, path, "first\n")
+
+		tail := NewTail(t, path)
+		tail.Expect(pipeline.TailLine{Path: path, Text: "first", Offset: 6})
+
+		tail.Change(func() {
+			if err := os.WriteFile(path, []byte("abcde\nxyz\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		})
+		tail.Expect(
+			pipeline.TailLine{Path: path, Text: "abcde", Offset: 6},
+			pipeline.TailLine{Path: path, Text: "xyz", Offset: 10},
+		)
+	})
+
+	t.Run("follows rotation", func(t *testing.T) {
+		var (
+			dir     = t.TempDir()
+			path    = filepath.Join(dir, "app.log")
+			rotated = filepath.Join(dir, "app.log.1")
+		)
+		Append(t, path, "a\n")
+
+		tail := NewTail(t, path)
+		tail.Expect(pipeline.TailLine{Path: path, Text: "a", Offset: 2})
+
+		tail.Change(func() {
+			if err := os.Rename(path, rotated); err != nil {
+				t.Fatal(err)
+			}
+			Append(t, rotated, "b\nlast")
+		})
+		tail.Expect(pipeline.TailLine{Path: rotated, Text: "b", Offset: 4})
+
+		tail.Change(func() { Append(t, path, "new\n") })
+		tail.Expect(
+			pipeline.TailLine{Path: rotated, Text: "last", Offset: 8},
+			pipeline.TailLine{Path: path, Text: "new", Offset: 4},
+		)
+	})
+
+	t.Run("reads the lines appended before rotation", func(t *testing.T) {
+		var (
+			dir     = t.TempDir()
+			path    = filepath.Join(dir, "app.log")
+			rotated = filepath.Join(dir, "app.log.1")
+		)
+		Append(t, path, "a\n")
+
+		tail := NewTail(t, path)
+		tail.Expect(pipeline.TailLine{Path: path, Text: "a", Offset: 2})
+
+		tail.Change(func() {
+			Append(t, path, "b\nlast")
+			if err := os.Rename(path, rotated); err != nil {
+				t.Fatal(err)
+			}
+			Append(t, path, "new\n")
+		})
+		tail.Expect(
+			pipeline.TailLine{Path: rotated, Text: "b", Offset: 4},
+			pipeline.TailLine{Path: rotated, Text: "last", Offset: 8},
+			pipeline.TailLine{Path: path, Text: "new", Offset: 4},
+		)
+	})
+}
@@ -1,10 +1,15 @@
 package pipeline
 
-import "github.com/nisimpson/piper"
+import (
+	"fmt"
+
+	"github.com/nisimpson/piper"
+)
 
 // taker represents a pipeline stage that takes a specified number of items
 // from the input stream and forwards them to the output stream.
 type taker struct {
+	*stage
 	// in is the input channel that receives items from the previous stage
 	in chan any
 	// out is the output channel that sends items to the next stage
@@ -19,6 +24,7 @@ type taker struct {
 // If count is negative, then TakeN is the equivalent of [Passthrough].
 func TakeN(count int) piper.Pipe {
 	pipe := taker{
+		stage: newStage(fmt.Sprintf("take(%d)", count)),
 		in:    make(chan any),
 		out:   make(chan any),
 		count: count,
@@ -44,12 +50,15 @@ func (t taker) Out() <-chan any { return t.out }
 // The output channel is automatically closed when processing is complete.
 func (t taker) start() {
 	defer close(t.out)
+	defer t.finish()
 	count := t.count
 	for i := range t.in {
+		rec := t.receive(i)
 		if count == 0 {
+			t.drop(i, rec) // dropped items are fully processed
 			continue
 		}
-		t.out <- i
+		t.emit(t.out, i, i, rec)
 		count--
 	}
 }
@@ -57,6 +66,7 @@ func (t taker) start() {
 // takeLast represents a pipeline stage that takes the last item
 // from the input stream and forwards them to the output stream.
 type takeLast struct {
+	*stage
 	in    chan any
 	out   chan any
 	count int
@@ -66,6 +76,7 @@ type takeLast struct {
 // and forwards it downstream, discarding the rest.
 func TakeLastN(count int) piper.Pipe {
 	pipe := takeLast{
+		stage: newStage(fmt.Sprintf("take_last(%d)", count)),
 		in:    make(chan any),
 		out:   make(chan any),
 		count: count,
@@ -84,17 +95,26 @@ func (t takeLast) Out() <-chan any { return t.out }
 
 func (t takeLast) start() {
 	defer close(t.out)
-	var last = make([]any, 0)
+	defer t.finish()
+	var (
+		last     = make([]any, 0)
+		receipts = make([]receipt, 0)
+	)
 	for i := range t.in {
 		last = append(last, i)
+		receipts = append(receipts, t.receive(i))
 	}
 	// if the count is less or equal to the length of the slice, then
-	// splice the slice.
+	// splice the slice, dropping the leading items.
 	if t.count <= len(last) {
+		for idx, i := range last[:len(last)-t.count] {
+			t.drop(i, receipts[idx])
+		}
 		last = last[len(last)-t.count:]
+		receipts = receipts[len(receipts)-t.count:]
 	}
 	// send the last items
-	for _, i := range last {
-		t.out <- i
+	for idx, i := range last {
+		t.emit(t.out, i, i, receipts[idx])
 	}
 }
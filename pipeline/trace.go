@@ -0,0 +1,113 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SpanContext identifies a span within a trace, following the W3C Trace Context specification.
+type SpanContext struct {
+	// TraceID identifies the trace the span belongs to.
+	TraceID [16]byte
+	// SpanID identifies the span within the trace.
+	SpanID [8]byte
+	// Sampled reports whether the trace is being recorded.
+	Sampled bool
+}
+
+// IsValid reports whether both the trace and span identifiers are non-zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent formats the span context as a W3C traceparent header value.
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// ErrInvalidTraceParent is returned when parsing a malformed traceparent header value.
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// ParseTraceParent parses a W3C traceparent header value into a [SpanContext].
+func ParseTraceParent(value string) (SpanContext, error) {
+	var (
+		sc    SpanContext
+		parts = strings.Split(strings.TrimSpace(value), "-")
+	)
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, ErrInvalidTraceParent
+	}
+	if parts[0] == "ff" {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, ErrInvalidTraceParent
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	if !sc.IsValid() {
+		return sc, ErrInvalidTraceParent
+	}
+	return sc, nil
+}
+
+// Span is a single operation within a trace, such as the processing of an item by a stage.
+// Its shape mirrors the OpenTelemetry span, so implementations can adapt any tracing library.
+type Span interface {
+	// SpanContext returns the identifiers of the span.
+	SpanContext() SpanContext
+	// SetAttribute sets a key-value attribute on the span.
+	SetAttribute(key string, value any)
+	// RecordError records err as an exception on the span, and marks the span as failed.
+	RecordError(err error)
+	// End completes the span.
+	End()
+}
+
+// SpanOptions configure a span started by a [Tracer].
+type SpanOptions struct {
+	// Links are the span contexts causally related to the new span, without being its parent.
+	Links []SpanContext
+}
+
+// Tracer starts the spans recorded by the stages of a [Flow]. Attach a tracer with [Flow.WithTracer].
+type Tracer interface {
+	// Start creates a span named name as a child of the span in ctx, if any. It returns
+	// the span along with a copy of ctx carrying it.
+	Start(ctx context.Context, name string, opts ...func(*SpanOptions)) (context.Context, Span)
+}
+
+// WithLinks is a [SpanOptions] function that links the span to each of the span contexts.
+func WithLinks(links ...SpanContext) func(*SpanOptions) {
+	return func(so *SpanOptions) {
+		so.Links = append(so.Links, links...)
+	}
+}
+
+// spanContextKey is the context key for the span stored by [ContextWithSpan].
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying the span. It is intended for [Tracer]
+// implementations that do not provide their own context propagation.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the span stored in ctx by [ContextWithSpan], or nil if there is none.
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(spanContextKey{}).(Span)
+	return span
+}
@@ -0,0 +1,302 @@
+package pipeline_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/nisimpson/piper/internal/must"
+	"github.com/nisimpson/piper/pipeline"
+)
+
+type FakeSpan struct {
+	mu     sync.Mutex
+	name   string
+	sc     pipeline.SpanContext
+	parent pipeline.SpanContext
+	links  []pipeline.SpanContext
+	err    error
+	ended  bool
+}
+
+func (s *FakeSpan) SpanContext() pipeline.SpanContext { return s.sc }
+func (s *FakeSpan) SetAttribute(string, any)          {}
+
+func (s *FakeSpan) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *FakeSpan) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+func (s *FakeSpan) Ended() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ended
+}
+
+// FakeTracer records the spans it starts, numbering them sequentially.
+type FakeTracer struct {
+	mu    sync.Mutex
+	spans []*FakeSpan
+}
+
+func (t *FakeTracer) Start(ctx context.Context, name string, opts ...func(*pipeline.SpanOptions)) (context.Context, pipeline.Span) {
+	options := pipeline.SpanOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &FakeSpan{name: name, links: options.Links}
+	id := uint64(len(t.spans) + 1)
+	binary.BigEndian.PutUint64(span.sc.SpanID[:], id)
+	span.sc.Sampled = true
+	if parent := pipeline.SpanFromContext(ctx); parent != nil {
+		span.parent = parent.SpanContext()
+		span.sc.TraceID = span.parent.TraceID
+	} else {
+		binary.BigEndian.PutUint64(span.sc.TraceID[8:], id)
+	}
+	t.spans = append(t.spans, span)
+	return pipeline.ContextWithSpan(ctx, span), span
+}
+
+// Spans returns the recorded spans with the given name.
+func (t *FakeTracer) Spans(name string) []*FakeSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spans := make([]*FakeSpan, 0)
+	for _, span := range t.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+func TestTraceParent(t *testing.T) {
+	t.Parallel()
+
+	const value = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	sc, err := pipeline.ParseTraceParent(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.Sampled || !sc.IsValid() {
+		t.Errorf("got %+v, want a valid sampled span context", sc)
+	}
+	if got := sc.TraceParent(); got != value {
+		t.Errorf("got %q, want %q", got, value)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	} {
+		if _, err := pipeline.ParseTraceParent(invalid); !errors.Is(err, pipeline.ErrInvalidTraceParent) {
+			t.Errorf("parsing %q: got error %v", invalid, err)
+		}
+	}
+}
+
+func TestWithTracer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("traces items through each stage", func(t *testing.T) {
+		var (
+			tracer  = &FakeTracer{}
+			headers = make(chan string, 2)
+			server  = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers <- r.Header.Get("traceparent")
+			}))
+			sink = pipeline.ToSlice[int]()
+		)
+
+		defer server.Close()
+
+		pipeline.FromSlice(1).
+			WithTracer(tracer).
+			Thru(
+				pipeline.Map(func(i int) int { return i * 2 }),
+				pipeline.SendHTTP(http.MethodPost, server.URL),
+				pipeline.Map(func(res *http.Response) int { return res.StatusCode }),
+			).
+			To(sink)
+
+		if got, want := sink.Slice(), []int{http.StatusOK}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+
+		var (
+			maps  = tracer.Spans("map")
+			https = tracer.Spans("http POST /")
+			sinks = tracer.Spans("slice")
+		)
+
+		if len(maps) != 2 || len(https) != 1 || len(sinks) != 1 {
+			t.Fatalf("got %d map, %d http and %d slice spans", len(maps), len(https), len(sinks))
+		}
+		if https[0].parent != maps[0].sc {
+			t.Errorf("http span is not a child of the first map span")
+		}
+		if maps[1].parent != https[0].sc {
+			t.Errorf("second map span is not a child of the http span")
+		}
+		if sinks[0].parent != maps[1].sc {
+			t.Errorf("slice span is not a child of the second map span")
+		}
+		if got, want := <-headers, https[0].sc.TraceParent(); got != want {
+			t.Errorf("got traceparent %q, want %q", got, want)
+		}
+		for _, span := range append(maps, https...) {
+			if !span.Ended() {
+				t.Errorf("span %s was not ended", span.name)
+			}
+		}
+	})
+
+	t.Run("does not leak the trace context into the base request", func(t *testing.T) {
+		var (
+			headers = make(chan string, 2)
+			server  = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				headers <- r.Header.Get("traceparent")
+			}))
+			request = must.Return(http.NewRequest(http.MethodPost, server.URL, nil))
+			send    = func(o *pipeline.HttpPipeOptions) { o.Request = request }
+		)
+
+		defer server.Close()
+
+		Consume[*http.Response](pipeline.FromSlice(1).
+			WithTracer(&FakeTracer{}).
+			Thru(pipeline.SendHTTP(http.MethodPost, server.URL, send)))
+		Consume[*http.Response](pipeline.FromSlice(2).
+			Thru(pipeline.SendHTTP(http.MethodPost, server.URL, send)))
+
+		if got := <-headers; got == "" {
+			t.Error("expected the traced item to carry a traceparent")
+		}
+		if got := <-headers; got != "" {
+			t.Errorf("got traceparent %q for the untraced item, want none", got)
+		}
+		if got := request.Header.Get("traceparent"); got != "" {
+			t.Errorf("got traceparent %q on the base request, want none", got)
+		}
+	})
+
+	t.Run("passes the trace context to mapping functions", func(t *testing.T) {
+		type key struct{}
+		var (
+			tracer      = &FakeTracer{}
+			ctx, cancel = context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+			seen        = make(chan context.Context, 1)
+			mapper      = pipeline.MapContext(ctx, func(ctx context.Context, i int) int {
+				seen <- ctx
+				return i
+			})
+		)
+
+		defer cancel()
+
+		Consume[int](pipeline.FromSlice(1).WithTracer(tracer).Thru(mapper))
+
+		got := <-seen
+		if span, maps := pipeline.SpanFromContext(got), tracer.Spans("map"); len(maps) != 1 || span != maps[0] {
+			t.Errorf("got span %v, want the map span", span)
+		}
+		if got.Value(key{}) != "value" {
+			t.Error("expected the values of the context to be kept")
+		}
+		cancel()
+		if got.Err() == nil {
+			t.Error("expected the context to be done with ctx")
+		}
+	})
+
+	t.Run("links batches to their members", func(t *testing.T) {
+		var (
+			tracer = &FakeTracer{}
+			sink   = pipeline.ToSlice[[]int]()
+		)
+
+		pipeline.FromChannel(numbers(1, 2)).
+			WithTracer(tracer).
+			Thru(pipeline.BatchN[int](2)).
+			To(sink)
+
+		sink.Slice()
+
+		var (
+			sources = tracer.Spans("channel")
+			batches = tracer.Spans("batch(2)")
+		)
+
+		if len(sources) != 2 || len(batches) != 3 {
+			t.Fatalf("got %d source and %d batch spans", len(sources), len(batches))
+		}
+
+		// the first two batch spans trace the members, the last one traces the batch.
+		batch := batches[2]
+		want := []pipeline.SpanContext{batches[0].sc, batches[1].sc}
+		if !reflect.DeepEqual(batch.links, want) {
+			t.Errorf("got links %v, want %v", batch.links, want)
+		}
+		if sinks := tracer.Spans("slice"); len(sinks) != 1 || sinks[0].parent != batch.sc {
+			t.Errorf("slice span is not a child of the batch span")
+		}
+	})
+
+	t.Run("records errors", func(t *testing.T) {
+		var (
+			tracer = &FakeTracer{}
+			action = pipeline.ExecCmd(EchoCommand("", errors.New("an error")))
+		)
+
+		Consume[string](pipeline.FromSlice("a").WithTracer(tracer).Thru(action))
+
+		spans := tracer.Spans("command")
+		if len(spans) != 1 || spans[0].err == nil || !spans[0].Ended() {
+			t.Errorf("expected the command span to record the error")
+		}
+	})
+
+	t.Run("unwraps items for other components", func(t *testing.T) {
+		var (
+			tracer = &FakeTracer{}
+			got    = Consume[int](pipeline.FromSlice(1, 2).
+				WithTracer(tracer).
+				Thru(pipeline.Map(func(i int) int { return i + 1 })))
+		)
+
+		if want := []int{2, 3}; !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+// numbers returns a closed channel holding the items.
+func numbers(items ...int) <-chan int {
+	ch := make(chan int, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+	return ch
+}
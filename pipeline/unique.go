@@ -19,6 +19,7 @@ type UniqueOptions[T any] struct {
 // based on a key function. It maintains internal channels for communication
 // and configuration options for customization.
 type uniquePipe[In any] struct {
+	*stage
 	// options holds the configuration for determining element uniqueness
 	options UniqueOptions[In]
 	// in is the channel for receiving input elements
@@ -38,6 +39,7 @@ func Unique[In any](opts ...func(*UniqueOptions[In])) piper.Pipe {
 		opt(&options)
 	}
 	pipe := uniquePipe[In]{
+		stage:   newStage("unique"),
 		options: options,
 		in:      make(chan any),
 		out:     make(chan any),
@@ -61,15 +63,19 @@ func (u uniquePipe[In]) Out() <-chan any { return u.out }
 // the output channel.
 func (u uniquePipe[In]) start() {
 	defer close(u.out)
+	defer u.finish()
 	unique := make(map[string]struct{})
 	for item := range u.in {
 		var (
-			input = item.(In)
+			rec   = u.receive(item)
+			input = unwrap(item).(In)
 			key   = u.options.KeyFunc(input)
 		)
-		if _, ok := unique[key]; !ok {
-			unique[key] = struct{}{}
-			u.out <- item
+		if _, ok := unique[key]; ok {
+			u.drop(item, rec) // duplicates are dropped, and therefore fully processed
+			continue
 		}
+		unique[key] = struct{}{}
+		u.emit(u.out, item, item, rec)
 	}
 }
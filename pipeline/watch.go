@@ -0,0 +1,302 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nisimpson/piper/internal/must"
+)
+
+// FileOp is the change reported by a [FileEvent].
+type FileOp int
+
+const (
+	// FileCreated reports a new file.
+	FileCreated FileOp = iota + 1
+	// FileModified reports a file whose size or modification time changed since it was reported.
+	FileModified
+	// FileRemoved reports a file removed since it was reported.
+	FileRemoved
+)
+
+// String returns the name of the operation.
+func (op FileOp) String() string {
+	switch op {
+	case FileCreated:
+		return "created"
+	case FileModified:
+		return "modified"
+	case FileRemoved:
+		return "removed"
+	default:
+		return fmt.Sprintf("FileOp(%d)", int(op))
+	}
+}
+
+// FileEvent is a change to a file, sent by [FromDirWatch].
+type FileEvent struct {
+	// Path is the path of the file, joined to the watched directory.
+	Path string
+	// Op is the change to the file.
+	Op FileOp
+	// Size is the size of the file in bytes. Removed files report their last known size.
+	Size int64
+	// ModTime is the modification time of the file. Removed files report their last known time.
+	ModTime time.Time
+}
+
+// DirWatchOptions configure the source created by [FromDirWatch].
+type DirWatchOptions struct {
+	// StablePolls is the number of consecutive polls a file must keep the same size and modification
+	// time before it is reported, so files are not sent while still being written. Defaults to 1;
+	// zero reports files as soon as they are seen.
+	StablePolls int
+	// SkipExisting ignores the files in the directory when the source starts, reporting only the
+	// files created or modified afterwards.
+	SkipExisting bool
+	// ArchiveDir, if set, is the directory where created and modified files are moved once their
+	// events are acknowledged downstream. Events are then sent as a [Message], and files whose events
+	// are negatively acknowledged stay in place. Archived files are not reported as removed. Files
+	// are never overwritten in the archive: a file whose name is already archived stays in place,
+	// and the conflict is passed to HandleError.
+	ArchiveDir string
+	// Clock schedules the polling. Defaults to [SystemClock].
+	Clock Clock
+	// HandleError is called when the directory cannot be read, ending the source, and when a file
+	// cannot be archived, from the goroutine acknowledging its event. Calls are serialized.
+	HandleError func(error)
+}
+
+// FromDirWatch creates a new [Flow] that polls the directory every interval, sending a [FileEvent]
+// for each regular file matching the glob pattern that is created, modified or removed, until the
+// context of the flow is done. The pattern is matched against the base name of each file with
+// [filepath.Match]; an empty pattern matches every file. Subdirectories are not watched.
+//
+// Files are reported once stable, and may be moved to an archive directory once processed; see
+// [DirWatchOptions] for details. Events are sent in the order of their paths for each poll. The
+// interval must be greater than zero, and FromDirWatch panics if the pattern is malformed.
+func FromDirWatch(dir, glob string, interval time.Duration, opts ...func(*DirWatchOptions)) Flow {
+	options := DirWatchOptions{
+		StablePolls: 1,
+		Clock:       SystemClock(),
+		HandleError: must.IgnoreError,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Clock == nil {
+		options.Clock = SystemClock()
+	}
+	if glob == "" {
+		glob = "*"
+	}
+	must.Return(filepath.Match(glob, ""))
+
+	// events may be acknowledged concurrently with polling, and with each other
+	var (
+		mu     sync.Mutex
+		handle = options.HandleError
+	)
+	options.HandleError = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		handle(err)
+	}
+
+	var source *seqSource[any]
+	source = newSeqSource("dirwatch", func(ctx context.Context) iter.Seq[any] {
+		return func(yield func(any) bool) {
+			w := &watcher{
+				dir:      dir,
+				glob:     glob,
+				options:  options,
+				files:    make(map[string]*watchedFile),
+				archived: make(map[string]bool),
+			}
+			if err := w.watch(ctx, interval, yield); err != nil {
+				source.failure("watch failed", err)
+				options.HandleError(err)
+			}
+		}
+	})
+	return From(source)
+}
+
+// watchedFile is the state of a file seen by a [watcher].
+type watchedFile struct {
+	// size and modTime are the size and modification time of the file at the last poll.
+	size    int64
+	modTime time.Time
+	// stable is the number of consecutive polls the file was seen unchanged.
+	stable int
+	// reported is set once an event was sent for the file, and sent holds the size and
+	// modification time it reported.
+	reported bool
+	sent     FileEvent
+}
+
+// watcher polls a directory for changes.
+type watcher struct {
+	dir     string
+	glob    string
+	options DirWatchOptions
+	// files are the files seen at the last poll, by path.
+	files map[string]*watchedFile
+	// mu serializes archiving files with listing the directory, and guards archived, the paths
+	// of the files moved to the archive directory since the directory was last listed.
+	mu       sync.Mutex
+	archived map[string]bool
+}
+
+// watch polls the directory until ctx is done or yield returns false. It returns an error if the
+// directory cannot be read.
+func (w *watcher) watch(ctx context.Context, interval time.Duration, yield func(any) bool) error {
+	if w.options.ArchiveDir != "" {
+		if err := os.MkdirAll(w.options.ArchiveDir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	for first := true; ; first = false {
+		events, err := w.poll(first && w.options.SkipExisting)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if !yield(w.wrap(event)) {
+				return nil
+			}
+		}
+
+		timer := w.options.Clock.NewTimer(interval)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		}
+	}
+}
+
+// poll lists the directory and returns the events of the files that changed since the last poll.
+// If skip is set, the files are recorded as already reported.
+func (w *watcher) poll(skip bool) ([]FileEvent, error) {
+	// files are not archived while the directory is listed and reconciled, so a file is either
+	// archived before, and forgotten here, or after, and forgotten by the next poll. Forgotten
+	// files are not reported as removed, and files created again at their path are reported as new.
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for path := range w.archived {
+		delete(w.files, path)
+	}
+	clear(w.archived)
+
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		events []FileEvent
+		seen   = make(map[string]bool, len(entries))
+	)
+	for _, entry := range entries {
+		if matched, _ := filepath.Match(w.glob, entry.Name()); !matched || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed since the directory was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(w.dir, entry.Name())
+		seen[path] = true
+		event := FileEvent{Path: path, Size: info.Size(), ModTime: info.ModTime()}
+		f, ok := w.files[path]
+		switch {
+		case !ok:
+			f = &watchedFile{size: event.Size, modTime: event.ModTime}
+			w.files[path] = f
+			if skip {
+				f.reported, f.sent = true, event
+				continue
+			}
+		case f.size == event.Size && f.modTime.Equal(event.ModTime):
+			f.stable++
+		default:
+			f.size, f.modTime, f.stable = event.Size, event.ModTime, 0
+		}
+
+		if f.stable < w.options.StablePolls {
+			continue
+		}
+		switch {
+		case !f.reported:
+			event.Op = FileCreated
+		case f.sent.Size != event.Size || !f.sent.ModTime.Equal(event.ModTime):
+			event.Op = FileModified
+		default:
+			continue
+		}
+		f.reported, f.sent = true, event
+		events = append(events, event)
+	}
+
+	var removed []FileEvent
+	for path, f := range w.files {
+		if seen[path] {
+			continue
+		}
+		delete(w.files, path)
+		if f.reported {
+			removed = append(removed, FileEvent{Path: path, Op: FileRemoved, Size: f.sent.Size, ModTime: f.sent.ModTime})
+		}
+	}
+	slices.SortFunc(removed, func(a, b FileEvent) int { return strings.Compare(a.Path, b.Path) })
+	return append(events, removed...), nil
+}
+
+// wrap returns the event as a [Message] archiving its file once acknowledged, if configured.
+func (w *watcher) wrap(event FileEvent) any {
+	if w.options.ArchiveDir == "" || event.Op == FileRemoved {
+		return event
+	}
+	return NewMessage(event, func() { w.archive(event.Path) }, nil)
+}
+
+// archive moves the file to the archive directory. Files already archived under the same name
+// are not overwritten; the file is left in place and the conflict is reported instead.
+func (w *watcher) archive(path string) {
+	if err := w.move(path); err != nil {
+		w.options.HandleError(err)
+	}
+}
+
+// move moves the file to the archive directory, marking it as archived, while the directory is
+// not being listed.
+func (w *watcher) move(path string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	target := filepath.Join(w.options.ArchiveDir, filepath.Base(path))
+	if _, err := os.Lstat(target); err == nil {
+		return &fs.PathError{Op: "archive", Path: target, Err: fs.ErrExist}
+	}
+	if err := os.Rename(path, target); err != nil {
+		return err
+	}
+	w.archived[path] = true
+	return nil
+}
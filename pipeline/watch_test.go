@@ -0,0 +1,290 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+)
+
+// Watch watches a directory with a fake clock, for a test.
+type Watch struct {
+	t     *testing.T
+	clock *pipetest.FakeClock
+	out   <-chan any
+}
+
+func NewWatch(t *testing.T, dir, glob string, opts ...func(*pipeline.DirWatchOptions)) *Watch {
+	var (
+		clock       = pipetest.NewFakeClock(time.Now())
+		ctx, cancel = context.WithCancel(context.Background())
+	)
+	opts = append(opts, func(o *pipeline.DirWatchOptions) { o.Clock = clock })
+	flow := pipeline.FromDirWatch(dir, glob, time.Second, opts...).WithContext(ctx)
+	watch := &Watch{t: t, clock: clock, out: flow.Out()}
+	t.Cleanup(func() {
+		cancel()
+		for range watch.out {
+		}
+	})
+	return watch
+}
+
+// Expect receives the next events, failing the test unless their path, operation and size match
+// want. It returns the items received.
+func (watch *Watch) Expect(want ...pipeline.FileEvent) []any {
+	watch.t.Helper()
+	var items []any
+	for _, w := range want {
+		select {
+		case item := <-watch.out:
+			got, ok := item.(pipeline.FileEvent)
+			if msg, isMsg := item.(pipeline.Message); isMsg {
+				got, ok = msg.Payload.(pipeline.FileEvent)
+			}
+			if !ok || got.Path != w.Path || got.Op != w.Op || got.Size != w.Size {
+				watch.t.Fatalf("got %+v, want %+v", item, w)
+			}
+			items = append(items, item)
+		case <-time.After(pipetest.DefaultTimeout):
+			watch.t.Fatalf("no event within %v, want %+v", pipetest.DefaultTimeout, w)
+		}
+	}
+	return items
+}
+
+// Change waits for the watch to finish polling, changes the directory, then lets the watch poll again.
+func (watch *Watch) Change(change func()) {
+	watch.clock.BlockUntil(1)
+	change()
+	watch.clock.Advance(time.Second)
+}
+
+// Poll waits for the watch to finish polling, then lets it poll again.
+func (watch *Watch) Poll() {
+	watch.Change(func() {})
+}
+
+func TestFromDirWatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports created, modified and removed files", func(t *testing.T) {
+		var (
+			dir = t.TempDir()
+			a   = filepath.Join(dir, "a.csv")
+			b   = filepath.Join(dir, "b.csv")
+		)
+		Append(t, a, "1\n")
+		Append(t, filepath.Join(dir, "notes.txt"), "ignored\n")
+		if err := os.Mkdir(filepath.Join(dir, "sub.csv"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		watch := NewWatch(t, dir, "*.csv", func(o *pipeline.DirWatchOptions) { o.StablePolls = 0 })
+		watch.Expect(pipeline.FileEvent{Path: a, Op: pipeline.FileCreated, Size: 2})
+
+		watch.Change(func() {
+			Append(t, a, "2\n")
+			Append(t, b, "1\n")
+		})
+		watch.Expect(
+			pipeline.FileEvent{Path: a, Op: pipeline.FileModified, Size: 4},
+			pipeline.FileEvent{Path: b, Op: pipeline.FileCreated, Size: 2},
+		)
+
+		watch.Change(func() {
+			if err := os.Remove(a); err != nil {
+				t.Fatal(err)
+			}
+		})
+		watch.Expect(pipeline.FileEvent{Path: a, Op: pipeline.FileRemoved, Size: 4})
+	})
+
+	t.Run("waits for files to be stable", func(t *testing.T) {
+		var (
+			dir = t.TempDir()
+			a   = filepath.Join(dir, "a.csv")
+		)
+		Append(t, a, "1\n")
+
+		watch := NewWatch(t, dir, "", func(o *pipeline.DirWatchOptions) { o.StablePolls = 2 })
+		watch.Change(func() { Append(t, a, "2\n") })
+		watch.Poll()
+		watch.Poll()
+		watch.Expect(pipeline.FileEvent{Path: a, Op: pipeline.FileCreated, Size: 4})
+	})
+
+	t.Run("skips existing files", func(t *testing.T) {
+		var (
+			dir = t.TempDir()
+			a   = filepath.Join(dir, "a.csv")
+			b   = filepath.Join(dir, "b.csv")
+		)
+		Append(t, a, "1\n")
+
+		watch := NewWatch(t, dir, "*.csv", func(o *pipeline.DirWatchOptions) { o.SkipExisting = true })
+		watch.Change(func() { Append(t, b, "1\n") })
+		watch.Poll()
+		watch.Expect(pipeline.FileEvent{Path: b, Op: pipeline.FileCreated, Size: 2})
+	})
+
+	t.Run("archives acknowledged files", func(t *testing.T) {
+		var (
+			dir     = t.TempDir()
+			archive = filepath.Join(t.TempDir(), "done")
+			a       = filepath.Join(dir, "a.csv")
+			b       = filepath.Join(dir, "b.csv")
+		)
+		Append(t, a, "1\n")
+		Append(t, b, "1\n")
+
+		errs := make(chan error, 1)
+		watch := NewWatch(t, dir, "*.csv", func(o *pipeline.DirWatchOptions) {
+			o.StablePolls = 0
+			o.ArchiveDir = archive
+			o.HandleError = func(err error) { errs <- err }
+		})
+		items := watch.Expect(
+			pipeline.FileEvent{Path: a, Op: pipeline.FileCreated, Size: 2},
+			pipeline.FileEvent{Path: b, Op: pipeline.FileCreated, Size: 2},
+		)
+		items[0].(pipeline.Message).Ack()
+		items[1].(pipeline.Message).Nack(errors.New("invalid"))
+
+		if _, err := os.Stat(filepath.Join(archive, "a.csv")); err != nil {
+			t.Errorf("expected a.csv to be archived: %v", err)
+		}
+		if _, err := os.Stat(b); err != nil {
+			t.Errorf("expected b.csv to stay in place: %v", err)
+		}
+
+		// a new file at the path of an archived file is reported as created, not modified
+		watch.Change(func() { Append(t, a, "10\n") })
+		items = watch.Expect(pipeline.FileEvent{Path: a, Op: pipeline.FileCreated, Size: 3})
+
+		// archived files are not overwritten
+		items[0].(pipeline.Message).Ack()
+		select {
+		case err := <-errs:
+			if !errors.Is(err, fs.ErrExist) {
+				t.Errorf("got error %v, want %v", err, fs.ErrExist)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("expected the conflict to be reported")
+		}
+		if got := ReadFiles(t, []string{a, filepath.Join(archive, "a.csv")}); !reflect.DeepEqual(got, []string{"10\n", "1\n"}) {
+			t.Errorf("got contents %q, want both files unchanged", got)
+		}
+	})
+
+	t.Run("serializes the errors of concurrent acknowledgments", func(t *testing.T) {
+		var (
+			dir     = t.TempDir()
+			archive = t.TempDir()
+			names   = []string{"a.csv", "b.csv", "c.csv", "d.csv"}
+			want    []pipeline.FileEvent
+		)
+		for _, name := range names {
+			Append(t, filepath.Join(dir, name), "1\n")
+			Append(t, filepath.Join(archive, name), "0\n")
+			want = append(want, pipeline.FileEvent{Path: filepath.Join(dir, name), Op: pipeline.FileCreated, Size: 2})
+		}
+
+		var (
+			conflicts int
+			done      = make(chan struct{}, len(names))
+		)
+		watch := NewWatch(t, dir, "*.csv", func(o *pipeline.DirWatchOptions) {
+			o.StablePolls = 0
+			o.ArchiveDir = archive
+			// not safe for concurrent use
+			o.HandleError = func(err error) {
+				conflicts++
+				done <- struct{}{}
+			}
+		})
+		for _, item := range watch.Expect(want...) {
+			go item.(pipeline.Message).Ack()
+		}
+		for range names {
+			<-done
+		}
+		if conflicts != len(names) {
+			t.Errorf("got %d conflicts, want %d", conflicts, len(names))
+		}
+	})
+
+	t.Run("does not report files archived while polling", func(t *testing.T) {
+		var (
+			dir     = t.TempDir()
+			archive = t.TempDir()
+			want    []pipeline.FileEvent
+		)
+		for i := range 100 {
+			path := filepath.Join(dir, fmt.Sprintf("%03d.csv", i))
+			Append(t, path, "1\n")
+			want = append(want, pipeline.FileEvent{Path: path, Op: pipeline.FileCreated, Size: 2})
+		}
+
+		watch := NewWatch(t, dir, "*.csv", func(o *pipeline.DirWatchOptions) {
+			o.StablePolls = 0
+			o.ArchiveDir = archive
+		})
+		items := watch.Expect(want...)
+
+		// archive the files while the directory is polled
+		var wg sync.WaitGroup
+		for _, item := range items {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				item.(pipeline.Message).Ack()
+			}()
+		}
+		events := make(chan any)
+		go func() {
+			for item := range watch.out {
+				events <- item
+			}
+		}()
+		polled := make(chan struct{})
+		go func() {
+			defer close(polled)
+			for range 50 {
+				watch.Poll()
+			}
+			wg.Wait()
+		}()
+		for {
+			select {
+			case item := <-events:
+				t.Fatalf("got %+v, want no events", item)
+			case <-polled:
+				return
+			}
+		}
+	})
+
+	t.Run("reports unreadable directories", func(t *testing.T) {
+		var (
+			errs = make(chan error, 1)
+			flow = pipeline.FromDirWatch(filepath.Join(t.TempDir(), "missing"), "", time.Second,
+				func(o *pipeline.DirWatchOptions) { o.HandleError = func(err error) { errs <- err } })
+		)
+		for range flow.Out() {
+			t.Error("expected no output")
+		}
+		if err := <-errs; !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/nisimpson/piper/pipeline"
+	"github.com/nisimpson/piper/pipeline/pipetest"
 	"github.com/nisimpson/piper/throttle"
 	"golang.org/x/time/rate"
 )
@@ -140,3 +141,30 @@ func ExampleLimit_zeroRate() {
 	// Received! 2
 	// Timed out waiting for value
 }
+
+// ExampleLimitOptions demonstrates deterministic rate limiting with a fake clock
+func ExampleLimitOptions() {
+	// Create a limiter that allows 2 items per second, told the time by a fake clock
+	clock := pipetest.NewFakeClock(time.Time{})
+	limiter := rate.NewLimiter(rate.Every(500*time.Millisecond), 1)
+	pipe := throttle.Limit(context.Background(), limiter, func(lo *throttle.LimitOptions) {
+		lo.Clock = clock
+	})
+
+	go func() {
+		in := pipe.In()
+		defer close(in)
+		in <- 1
+		in <- 2
+	}()
+
+	fmt.Println(<-pipe.Out(), "at", clock.Now().Sub(time.Time{}))
+
+	// the second item waits for the clock to advance
+	clock.BlockUntil(1)
+	clock.Advance(500 * time.Millisecond)
+	fmt.Println(<-pipe.Out(), "at", clock.Now().Sub(time.Time{}))
+	// Output:
+	// 1 at 0s
+	// 2 at 500ms
+}
@@ -3,6 +3,10 @@ module github.com/nisimpson/piper/throttle
 go 1.23.2
 
 require (
-	github.com/nisimpson/piper v0.2.0
+	github.com/nisimpson/piper v0.3.0
 	golang.org/x/time v0.9.0
 )
+
+// The required releases are tagged in one series by `make release`. Build against the modules
+// of this repository, so that a checkout builds as a whole.
+replace github.com/nisimpson/piper => ..
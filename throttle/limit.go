@@ -2,11 +2,36 @@ package throttle
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/nisimpson/piper"
 	"golang.org/x/time/rate"
 )
 
+// Clock tells the time and schedules the waits of a rate limiting stage. The clocks of the
+// pipeline package, such as its SystemClock and the fake clock of its pipetest package,
+// implement it.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel receiving the current time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// LimitOptions configure a rate limiting stage.
+type LimitOptions struct {
+	// Clock tells the time the limiter is consulted at, and schedules the waits
+	// between items. Defaults to the wall clock.
+	Clock Clock
+}
+
+// systemClock is the [Clock] reading the wall clock.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
 // limiter represents a pipeline stage that controls the rate at which items
 // flow through the pipeline using a rate.Limiter. It implements the piper.Pipe
 // interface to integrate with the pipeline processing system.
@@ -28,16 +53,28 @@ type limiter struct {
 	// ctx is the context that manages cancellation or deadline checks
 	// for the limiter.
 	ctx context.Context
+
+	// clock tells the time and schedules the waits between items.
+	clock Clock
 }
 
 // Limit creates a new [piper.Pipe] that rate limits the flow of items
-// using the provided [rate.Limiter].
-func Limit(ctx context.Context, limit *rate.Limiter) piper.Pipe {
+// using the provided [rate.Limiter], configured with the provided option functions.
+func Limit(ctx context.Context, limit *rate.Limiter, opts ...func(*LimitOptions)) piper.Pipe {
+	options := LimitOptions{Clock: systemClock{}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Clock == nil {
+		options.Clock = systemClock{}
+	}
+
 	limiter := limiter{
 		limit: limit,
 		in:    make(chan any),
 		out:   make(chan any),
 		ctx:   ctx,
+		clock: options.Clock,
 	}
 
 	go limiter.start()
@@ -67,15 +104,54 @@ func (l limiter) start() {
 	defer close(l.out)
 	for {
 		// Wait for rate limit allowance
-		if err := l.limit.Wait(l.ctx); err != nil {
+		if err := l.wait(); err != nil {
 			return
 		}
 
 		// Process next item if available
-		if data, ok := <-l.in; ok {
-			l.out <- data
-		} else {
+		select {
+		case data, ok := <-l.in:
+			if !ok {
+				return
+			}
+			select {
+			case l.out <- data:
+			case <-l.ctx.Done():
+				return
+			}
+		case <-l.ctx.Done():
 			return
 		}
 	}
 }
+
+// errExceeded is returned when the limiter can never allow an item, such as
+// a limiter with a zero rate once its burst is spent.
+var errExceeded = errors.New("throttle: rate limit exceeded")
+
+// wait blocks until the limiter allows an item, according to the clock. It is the
+// equivalent of [rate.Limiter.Wait], with the time told by the clock.
+func (l limiter) wait() error {
+	if err := l.ctx.Err(); err != nil {
+		return err
+	}
+
+	now := l.clock.Now()
+	r := l.limit.ReserveN(now, 1)
+	if !r.OK() {
+		return errExceeded
+	}
+
+	delay := r.DelayFrom(now)
+	if delay == 0 {
+		return nil
+	}
+
+	select {
+	case <-l.clock.After(delay):
+		return nil
+	case <-l.ctx.Done():
+		r.CancelAt(l.clock.Now())
+		return l.ctx.Err()
+	}
+}
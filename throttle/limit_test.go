@@ -0,0 +1,43 @@
+package throttle_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nisimpson/piper"
+	"github.com/nisimpson/piper/pipeline/pipetest"
+	"github.com/nisimpson/piper/throttle"
+	"golang.org/x/time/rate"
+)
+
+func TestLimit(t *testing.T) {
+	t.Parallel()
+
+	t.Run("conforms", func(t *testing.T) {
+		pipetest.Conformance(t, func(ctx context.Context) piper.Pipe {
+			return throttle.Limit(ctx, rate.NewLimiter(rate.Every(time.Microsecond), 10))
+		}, func(o *pipetest.ConformanceOptions) {
+			o.Cancellable = true
+		})
+	})
+
+	t.Run("stops waiting on cancellation", func(t *testing.T) {
+		var (
+			ctx, cancel = context.WithCancel(context.Background())
+			clock       = pipetest.NewFakeClock(time.Time{})
+			pipe        = throttle.Limit(ctx, rate.NewLimiter(rate.Every(time.Hour), 1), func(lo *throttle.LimitOptions) {
+				lo.Clock = clock
+			})
+			h = pipetest.NewHarness(t, pipe)
+		)
+
+		h.Send(1)
+		h.Expect(1)
+
+		// the second item waits an hour for the limiter
+		clock.BlockUntil(1)
+		cancel()
+		h.Close()
+	})
+}